@@ -10,19 +10,201 @@ import (
 )
 
 type Config struct {
-	Directories       []string      `yaml:"directories"`
-	IgnoreDirectories []string      `yaml:"ignore_directories"`
-	Sandbox           Sandbox       `yaml:"sandbox"`
-	Projects          Projects      `yaml:"projects"`
-	Editor            string        `yaml:"editor"`
-	Dash              DashConfig    `yaml:"dash"`
-	Spawn             SpawnConfig   `yaml:"spawn"`
-	Serve             ServeConfig   `yaml:"serve"`
-	Watcher           WatcherConfig `yaml:"watcher"`
+	Directories       []string            `yaml:"directories"`
+	IgnoreDirectories []string            `yaml:"ignore_directories"`
+	Sandbox           Sandbox             `yaml:"sandbox"`
+	Projects          Projects            `yaml:"projects"`
+	Editor            string              `yaml:"editor"`
+	Dash              DashConfig          `yaml:"dash"`
+	Spawn             SpawnConfig         `yaml:"spawn"`
+	Serve             ServeConfig         `yaml:"serve"`
+	Watcher           WatcherConfig       `yaml:"watcher"`
+	Tmux              TmuxConfig          `yaml:"tmux"`
+	Layouts           map[string]Layout   `yaml:"layouts"`
+	Recording         RecordingConfig     `yaml:"recording"`
+	Resources         ResourcesConfig     `yaml:"resources"`
+	Usage             UsageConfig         `yaml:"usage"`
+	Events            EventsConfig        `yaml:"events"`
+	History           HistoryConfig       `yaml:"history"`
+	Webhooks          []WebhookConfig     `yaml:"webhooks"`
+	Hooks             HooksConfig         `yaml:"hooks"`
+	Notifications     NotificationsConfig `yaml:"notifications"`
+}
+
+// NotificationsConfig picks where tsp serve sends its notifications. With
+// no sinks they go to the paired devices through Expo.
+type NotificationsConfig struct {
+	Sinks []NotificationSinkConfig `yaml:"sinks"`
+}
+
+// NotificationSinkConfig is a notification backend. Type is one of expo,
+// ntfy, gotify, http, desktop or tmux; Categories (done, waiting, error)
+// limits what it receives, everything when empty. URL overrides the
+// endpoint of expo and ntfy and is required by gotify and http.
+type NotificationSinkConfig struct {
+	Name       string   `yaml:"name"`
+	Type       string   `yaml:"type"`
+	Categories []string `yaml:"categories"`
+	URL        string   `yaml:"url"`
+	Topic      string   `yaml:"topic"`          // ntfy
+	Token      string   `yaml:"token" json:"-"` // ntfy access token or gotify app token; "$VAR" reads the environment
+	Format     string   `yaml:"format"`         // http: json (default), slack or discord
+	Command    string   `yaml:"command"`        // desktop: defaults to notify-send
+	Popup      bool     `yaml:"popup"`          // tmux: display-popup instead of display-message
+}
+
+// HooksConfig runs shell commands on events in tsp serve. Each command gets
+// the event in TSP_* environment variables, is killed after TimeoutS, and
+// at most Concurrency run at once; their output goes to ~/.tsp/hooks.log.
+type HooksConfig struct {
+	TimeoutS    int          `yaml:"timeout_s"`
+	Concurrency int          `yaml:"concurrency"`
+	On          []HookConfig `yaml:"on"`
+}
+
+// HookConfig is a command run on events of one type ("*" for every type).
+// To narrows status.changed and ci.status.changed events to those ending in
+// that status, and Sessions to sessions matching one of the globs.
+type HookConfig struct {
+	Event    string   `yaml:"event"`
+	To       string   `yaml:"to"`
+	Sessions []string `yaml:"sessions"`
+	Run      string   `yaml:"run"` // run with sh -c, in the session's directory
+}
+
+// WebhookConfig is an outbound webhook of tsp serve. Events of the listed
+// types (every type when empty) about sessions matching one of the globs
+// (every session when empty) are POSTed to URL as JSON. With a Secret,
+// requests are signed with HMAC-SHA256; a secret of "$VAR" is read from the
+// environment. Failed deliveries are retried MaxAttempts times in all.
+type WebhookConfig struct {
+	Name        string   `yaml:"name"`
+	URL         string   `yaml:"url"`
+	Secret      string   `yaml:"secret" json:"-"` // not served by /api/config
+	Events      []string `yaml:"events"`
+	Sessions    []string `yaml:"sessions"` // e.g. "myapp-*"
+	MaxAttempts int      `yaml:"max_attempts"`
+}
+
+// EventsConfig controls delivery on the event bus of tsp serve. Each
+// subscriber gets its own queue of QueueSize events, delivered in order;
+// Overflow decides what happens when one is full: "drop-oldest" (the
+// default) discards its oldest queued event, "block" makes publishers wait
+// (and can't be used with webhooks or hooks, which may take minutes).
+// Every event is also journaled to ~/.tsp/events/, one file per day, kept
+// for RetentionDays (negative keeps them forever).
+type EventsConfig struct {
+	QueueSize     int    `yaml:"queue_size"`
+	Overflow      string `yaml:"overflow"`
+	RetentionDays int    `yaml:"retention_days"`
+}
+
+// HistoryConfig limits the status history tsp serve keeps in
+// ~/.tsp/history/: a session's file is deleted once it hasn't been written
+// to for RetentionDays (negative keeps it forever), and its oldest
+// transitions are dropped once it grows past MaxFileKB (negative for no
+// limit).
+type HistoryConfig struct {
+	RetentionDays int `yaml:"retention_days"`
+	MaxFileKB     int `yaml:"max_file_kb"`
+}
+
+// ResourcesConfig controls CPU and memory sampling of each pane's process
+// tree (Linux only). A resource.threshold event fires when a session's
+// total crosses a limit; zero limits are off.
+type ResourcesConfig struct {
+	SampleS    int     `yaml:"sample_s"`    // seconds between samples
+	CPUPercent float64 `yaml:"cpu_percent"` // of one core, e.g. 200 for two cores
+	RSSMB      int     `yaml:"rss_mb"`
+}
+
+// UsageConfig controls token usage reporting. Prices are in USD per million
+// tokens, keyed by model name: a key matches every model whose name contains
+// it, the longest match winning. They add to and override the built-in
+// prices.
+type UsageConfig struct {
+	Prices map[string]ModelPrice `yaml:"prices"`
+}
+
+// ModelPrice is what a model costs per million tokens.
+type ModelPrice struct {
+	Input      float64 `yaml:"input" json:"input"`
+	Output     float64 `yaml:"output" json:"output"`
+	CacheWrite float64 `yaml:"cache_write" json:"cacheWrite"`
+	CacheRead  float64 `yaml:"cache_read" json:"cacheRead"`
+}
+
+// RecordingConfig controls continuous pane recording by tsp serve, which
+// pipes pane output to ~/.tsp/recordings/<session>/<pane>.log.
+type RecordingConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	AgentsOnly    bool `yaml:"agents_only"`    // only record agent panes
+	MaxFileMB     int  `yaml:"max_file_mb"`    // rotate a pane's log at this size
+	MaxFiles      int  `yaml:"max_files"`      // rotated logs kept per pane
+	RetentionDays int  `yaml:"retention_days"` // delete logs not written to for this long
+}
+
+// Layout is a named session layout: its windows, the panes in each and which
+// pane runs the agent.
+type Layout struct {
+	Windows []LayoutWindow `yaml:"windows"`
+}
+
+type LayoutWindow struct {
+	Name   string       `yaml:"name"`
+	Layout string       `yaml:"layout"` // tmux layout applied after the splits, e.g. main-vertical
+	Panes  []LayoutPane `yaml:"panes"`
+}
+
+// LayoutPane is one pane of a window. Every pane after the first is split off
+// the pane before it.
+type LayoutPane struct {
+	Command string `yaml:"command"`
+	Split   string `yaml:"split"` // h (side by side, default) or v (stacked)
+	Size    string `yaml:"size"`  // e.g. "30%" or "20" (cells)
+	Agent   bool   `yaml:"agent"` // runs spawn.agent_command when command is empty
+}
+
+// TmuxConfig selects the tmux server tsp talks to. Leaving both socket
+// fields empty uses tmux's own default ($TMUX, or the "default" socket).
+type TmuxConfig struct {
+	SocketName string       `yaml:"socket_name"` // tmux -L
+	SocketPath string       `yaml:"socket_path"` // tmux -S; takes precedence over socket_name
+	Servers    []TmuxServer `yaml:"servers"`     // additional servers monitored by tsp serve
+}
+
+// TmuxServer is an additional tmux server monitored by tsp serve.
+type TmuxServer struct {
+	Name       string `yaml:"name"`
+	SocketName string `yaml:"socket_name"`
+	SocketPath string `yaml:"socket_path"`
 }
 
 type DashConfig struct {
-	RefreshMs     int      `yaml:"refresh_ms"`
+	RefreshMs        int              `yaml:"refresh_ms"`
+	ErrorPatterns    []string         `yaml:"error_patterns"`
+	PromptPattern    string           `yaml:"prompt_pattern"`
+	InputPatterns    []string         `yaml:"input_patterns"`
+	Detectors        []DetectorConfig `yaml:"detectors"` // extra status detectors, checked before the built-in ones
+	StatusThresholds `yaml:",inline"`
+	Repos            map[string]StatusThresholds `yaml:"repos"` // threshold overrides by repo path
+}
+
+// StatusThresholds are how long a pane must be unchanged before it counts as
+// idle or done, and an idle agent as stuck. In a repo override, zero fields
+// keep the global value.
+type StatusThresholds struct {
+	IdleAfterS  int `yaml:"idle_after_s"`
+	DoneAfterS  int `yaml:"done_after_s"`
+	StuckAfterS int `yaml:"stuck_after_s"`
+}
+
+// DetectorConfig defines a status detector for panes running one of the
+// listed processes. Its patterns are used on top of the dash patterns.
+type DetectorConfig struct {
+	Name          string   `yaml:"name"`
+	Processes     []string `yaml:"processes"` // pane process names, e.g. goose
+	Agent         bool     `yaml:"agent"`     // treat matching panes as agents
 	ErrorPatterns []string `yaml:"error_patterns"`
 	PromptPattern string   `yaml:"prompt_pattern"`
 	InputPatterns []string `yaml:"input_patterns"`
@@ -32,6 +214,7 @@ type SpawnConfig struct {
 	WorktreeBase string `yaml:"worktree_base"`
 	AgentCommand string `yaml:"agent_command"`
 	DefaultSetup string `yaml:"default_setup"`
+	Layout       string `yaml:"layout"` // default layout for spawn and wtx-new
 }
 
 type ServeConfig struct {
@@ -147,6 +330,15 @@ func LoadFrom(configPath string) (*Config, error) {
 	if len(cfg.Dash.ErrorPatterns) == 0 {
 		cfg.Dash.ErrorPatterns = []string{"FAIL", "panic:", "Error:"}
 	}
+	if cfg.Dash.IdleAfterS == 0 {
+		cfg.Dash.IdleAfterS = 30
+	}
+	if cfg.Dash.DoneAfterS == 0 {
+		cfg.Dash.DoneAfterS = 60
+	}
+	if cfg.Dash.StuckAfterS == 0 {
+		cfg.Dash.StuckAfterS = 300
+	}
 	if len(cfg.Dash.InputPatterns) == 0 {
 		cfg.Dash.InputPatterns = []string{
 			`^\s*\? `,
@@ -175,6 +367,45 @@ func LoadFrom(configPath string) (*Config, error) {
 		cfg.Serve.RefreshMs = cfg.Dash.RefreshMs
 	}
 
+	// Recording defaults
+	if cfg.Recording.MaxFileMB == 0 {
+		cfg.Recording.MaxFileMB = 10
+	}
+	if cfg.Recording.MaxFiles == 0 {
+		cfg.Recording.MaxFiles = 5
+	}
+	if cfg.Recording.RetentionDays == 0 {
+		cfg.Recording.RetentionDays = 7
+	}
+
+	if cfg.Resources.SampleS == 0 {
+		cfg.Resources.SampleS = 5
+	}
+
+	if cfg.Hooks.TimeoutS == 0 {
+		cfg.Hooks.TimeoutS = 60
+	}
+	if cfg.Hooks.Concurrency == 0 {
+		cfg.Hooks.Concurrency = 4
+	}
+
+	if cfg.Events.QueueSize == 0 {
+		cfg.Events.QueueSize = 256
+	}
+	if cfg.Events.Overflow == "" {
+		cfg.Events.Overflow = "drop-oldest"
+	}
+	if cfg.Events.RetentionDays == 0 {
+		cfg.Events.RetentionDays = 14
+	}
+
+	if cfg.History.RetentionDays == 0 {
+		cfg.History.RetentionDays = 30
+	}
+	if cfg.History.MaxFileKB == 0 {
+		cfg.History.MaxFileKB = 256
+	}
+
 	// Watcher defaults
 	if cfg.Watcher.PollIntervalS == 0 {
 		cfg.Watcher.PollIntervalS = 30
@@ -231,6 +462,11 @@ func defaultConfig() *Config {
 				`Press Enter`,
 				`(?i)type something`,
 			},
+			StatusThresholds: StatusThresholds{
+				IdleAfterS:  30,
+				DoneAfterS:  60,
+				StuckAfterS: 300,
+			},
 		},
 		Spawn: SpawnConfig{
 			WorktreeBase: filepath.Join(homeDir, "work", "code"),
@@ -246,6 +482,15 @@ func defaultConfig() *Config {
 			MaxCIRetries:  3,
 			AutoCleanup:   true,
 		},
+		Recording: RecordingConfig{
+			MaxFileMB:     10,
+			MaxFiles:      5,
+			RetentionDays: 7,
+		},
+		Resources: ResourcesConfig{SampleS: 5},
+		Events:    EventsConfig{QueueSize: 256, Overflow: "drop-oldest", RetentionDays: 14},
+		History:   HistoryConfig{RetentionDays: 30, MaxFileKB: 256},
+		Hooks:     HooksConfig{TimeoutS: 60, Concurrency: 4},
 	}
 }
 
@@ -117,6 +117,131 @@ spawn:
 	}
 }
 
+func TestLoadDetectors(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	content := []byte(`
+dash:
+  detectors:
+    - name: goose
+      processes: [goose]
+      agent: true
+      prompt_pattern: "^\\( O\\)>$"
+      input_patterns:
+        - "Allow\\?"
+`)
+	os.WriteFile(configPath, content, 0644)
+
+	cfg, err := LoadFrom(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Dash.Detectors) != 1 {
+		t.Fatalf("expected 1 detector, got %d", len(cfg.Dash.Detectors))
+	}
+	d := cfg.Dash.Detectors[0]
+	if d.Name != "goose" || !d.Agent || len(d.Processes) != 1 || d.Processes[0] != "goose" {
+		t.Errorf("unexpected detector: %+v", d)
+	}
+	if d.PromptPattern != `^\( O\)>$` || len(d.InputPatterns) != 1 || d.InputPatterns[0] != `Allow\?` {
+		t.Errorf("unexpected detector patterns: %+v", d)
+	}
+	if len(cfg.Dash.InputPatterns) == 0 {
+		t.Error("expected default input patterns alongside detectors")
+	}
+}
+
+func TestLoadStatusThresholds(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	content := []byte(`
+dash:
+  idle_after_s: 20
+  repos:
+    ~/work/slow:
+      done_after_s: 180
+`)
+	os.WriteFile(configPath, content, 0644)
+
+	cfg, err := LoadFrom(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := StatusThresholds{IdleAfterS: 20, DoneAfterS: 60, StuckAfterS: 300}
+	if cfg.Dash.StatusThresholds != want {
+		t.Errorf("thresholds = %+v, want %+v", cfg.Dash.StatusThresholds, want)
+	}
+	if got := cfg.Dash.Repos["~/work/slow"]; got != (StatusThresholds{DoneAfterS: 180}) {
+		t.Errorf("repo thresholds = %+v", got)
+	}
+}
+
+func TestLoadTmuxConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	content := []byte(`
+tmux:
+  socket_name: agents
+  servers:
+    - name: personal
+      socket_path: /tmp/tmux-1000/default
+`)
+	os.WriteFile(configPath, content, 0644)
+
+	cfg, err := LoadFrom(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Tmux.SocketName != "agents" {
+		t.Errorf("expected socket_name agents, got %q", cfg.Tmux.SocketName)
+	}
+	if len(cfg.Tmux.Servers) != 1 || cfg.Tmux.Servers[0].Name != "personal" || cfg.Tmux.Servers[0].SocketPath != "/tmp/tmux-1000/default" {
+		t.Errorf("unexpected servers: %+v", cfg.Tmux.Servers)
+	}
+}
+
+func TestLoadLayouts(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	content := []byte(`
+spawn:
+  layout: fullstack
+layouts:
+  fullstack:
+    windows:
+      - name: main
+        layout: main-vertical
+        panes:
+          - agent: true
+          - command: npm run dev
+            split: h
+            size: 40%
+          - command: npm test -- --watch
+            split: v
+            size: 20
+`)
+	os.WriteFile(configPath, content, 0644)
+
+	cfg, err := LoadFrom(configPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Spawn.Layout != "fullstack" {
+		t.Errorf("expected spawn.layout fullstack, got %q", cfg.Spawn.Layout)
+	}
+	l, ok := cfg.Layouts["fullstack"]
+	if !ok || len(l.Windows) != 1 || len(l.Windows[0].Panes) != 3 {
+		t.Fatalf("unexpected layouts: %+v", cfg.Layouts)
+	}
+	w := l.Windows[0]
+	if w.Name != "main" || w.Layout != "main-vertical" || !w.Panes[0].Agent {
+		t.Errorf("unexpected window: %+v", w)
+	}
+	if w.Panes[1].Size != "40%" || w.Panes[2].Size != "20" || w.Panes[2].Split != "v" {
+		t.Errorf("unexpected panes: %+v", w.Panes)
+	}
+}
+
 func TestDashConfigDefaults(t *testing.T) {
 	dir := t.TempDir()
 	configPath := filepath.Join(dir, "config.yaml")
@@ -23,14 +23,11 @@ func FindAllJSONL(sessionDir string) ([]AgentSession, error) {
 		return nil, os.ErrNotExist
 	}
 
-	homeDir, err := os.UserHomeDir()
+	projectDir, err := projectDir(sessionDir)
 	if err != nil {
 		return nil, err
 	}
 
-	encoded := strings.ReplaceAll(sessionDir, "/", "-")
-	projectDir := filepath.Join(homeDir, ".claude", "projects", encoded)
-
 	entries, err := os.ReadDir(projectDir)
 	if err != nil {
 		return nil, err
@@ -61,6 +58,27 @@ func FindAllJSONL(sessionDir string) ([]AgentSession, error) {
 	return sessions, nil
 }
 
+// projectDir returns the directory Claude Code keeps a working directory's
+// session logs in.
+func projectDir(sessionDir string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	encoded := strings.ReplaceAll(sessionDir, "/", "-")
+	return filepath.Join(homeDir, ".claude", "projects", encoded), nil
+}
+
+// JSONLPath returns the path of the JSONL log of the Claude Code session
+// with the given ID, started in sessionDir. The file may not exist.
+func JSONLPath(sessionDir, id string) string {
+	dir, err := projectDir(sessionDir)
+	if err != nil || sessionDir == "" || id == "" {
+		return ""
+	}
+	return filepath.Join(dir, id+".jsonl")
+}
+
 // FindJSONL finds the most recent Claude Code JSONL file for a session directory.
 func FindJSONL(sessionDir string) (string, error) {
 	sessions, err := FindAllJSONL(sessionDir)
@@ -0,0 +1,81 @@
+package agentlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// follower reads the entries appended to a session log since its last
+// read. Only complete lines are consumed, so an entry that is still being
+// written is picked up on the next read.
+type follower struct {
+	path    string
+	tail    int64 // on the first read, start this many bytes from the end; 0 reads everything
+	offset  int64
+	started bool
+	modTime time.Time // of the log as of the last read
+}
+
+// next returns the new entries. reset reports that the log was truncated or
+// replaced and has been read again from the start, so anything derived
+// from earlier entries is stale.
+func (f *follower) next() (entries []Entry, reset bool, err error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	size := info.Size()
+	f.modTime = info.ModTime()
+
+	skipPartial := false
+	if !f.started {
+		f.started = true
+		if f.tail > 0 && size > f.tail {
+			f.offset = size - f.tail
+			skipPartial = true
+		}
+	}
+	if size < f.offset {
+		f.offset = 0
+		reset = true
+	}
+	if size == f.offset {
+		return nil, reset, nil
+	}
+
+	data := make([]byte, size-f.offset)
+	n, err := file.ReadAt(data, f.offset)
+	if err != nil && err != io.EOF {
+		return nil, reset, err
+	}
+	data = data[:n]
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return nil, reset, nil
+	}
+	data = data[:end+1]
+	f.offset += int64(len(data))
+	if skipPartial {
+		// The tail starts mid-line.
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var e Entry
+		if len(line) == 0 || json.Unmarshal(line, &e) != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, reset, nil
+}
@@ -0,0 +1,164 @@
+package agentlog
+
+import (
+	"strings"
+	"time"
+)
+
+// initialTail is how much of an existing log a StatusTracker reads when it
+// starts following it. The agent's current state is always near the end.
+const initialTail = 1 << 20
+
+// State is an agent's status as derived from its session log.
+type State struct {
+	Status  string // active, done, waiting, error or idle; "" if the log doesn't say yet
+	Pending string // name of the latest unanswered tool call, if any
+	Prompt  string // the question asked, when waiting on AskUserQuestion
+
+	WrittenAt time.Time // when the log was last written; zero if unknown
+}
+
+// StatusTracker follows a Claude Code session log and derives the agent's
+// status from its entries:
+//
+//   - an assistant turn that ended with end_turn means done
+//   - an unanswered AskUserQuestion means waiting
+//   - other unanswered tool calls, or a new user prompt, mean active
+//   - an API error message means error
+//   - an interrupted request means idle
+type StatusTracker struct {
+	log     follower
+	state   State
+	pending map[string]ContentBlock // unanswered tool_use blocks by ID
+	order   []string                // pending IDs, oldest first
+}
+
+// NewStatusTracker creates a tracker for the log at path. Nothing is read
+// until Update is called.
+func NewStatusTracker(path string) *StatusTracker {
+	return &StatusTracker{
+		log:     follower{path: path, tail: initialTail},
+		pending: make(map[string]ContentBlock),
+	}
+}
+
+// Path returns the log the tracker follows.
+func (t *StatusTracker) Path() string { return t.log.path }
+
+// Update reads the entries appended since the last call and returns the
+// resulting state. Only complete lines are consumed, so an entry that is
+// still being written is picked up on the next call.
+func (t *StatusTracker) Update() (State, error) {
+	entries, reset, err := t.log.next()
+	if reset {
+		t.reset()
+	}
+	if err != nil {
+		return t.state, err
+	}
+	t.Apply(entries)
+	t.state.WrittenAt = t.log.modTime
+	return t.state, nil
+}
+
+// State returns the state as of the last Update or Apply.
+func (t *StatusTracker) State() State { return t.state }
+
+func (t *StatusTracker) reset() {
+	t.state = State{}
+	t.pending = make(map[string]ContentBlock)
+	t.order = nil
+}
+
+// Apply updates the state with log entries, oldest first.
+func (t *StatusTracker) Apply(entries []Entry) {
+	for _, e := range entries {
+		if e.Message == nil || e.IsSidechain || isNoise(e) {
+			continue
+		}
+		switch e.Type {
+		case "assistant":
+			t.applyAssistant(e)
+		case "user":
+			t.applyUser(e)
+		}
+	}
+	t.state.Pending, t.state.Prompt = "", ""
+	if n := len(t.order); n > 0 {
+		latest := t.pending[t.order[n-1]]
+		t.state.Pending = latest.Name
+		for _, id := range t.order {
+			if b := t.pending[id]; b.Name == "AskUserQuestion" {
+				t.state.Status = "waiting"
+				if q := parseAskUserQuestion(b).Questions; len(q) > 0 {
+					t.state.Prompt = q[0].Question
+				}
+				return
+			}
+		}
+		if t.state.Status != "error" && t.state.Status != "idle" {
+			t.state.Status = "active"
+		}
+	}
+}
+
+func (t *StatusTracker) applyAssistant(e Entry) {
+	if e.IsAPIErrorMessage {
+		t.state.Status = "error"
+		return
+	}
+	if e.Message.Model == "<synthetic>" {
+		return
+	}
+	for _, b := range extractBlocks(e.Message.Content) {
+		if b.Type == "tool_use" && b.ID != "" {
+			if _, ok := t.pending[b.ID]; !ok {
+				t.order = append(t.order, b.ID)
+			}
+			t.pending[b.ID] = b
+		}
+	}
+	switch e.Message.StopReason {
+	case "end_turn", "stop_sequence":
+		t.state.Status = "done"
+	default:
+		t.state.Status = "active"
+	}
+}
+
+func (t *StatusTracker) applyUser(e Entry) {
+	answered := false
+	for _, b := range extractBlocks(e.Message.Content) {
+		if b.Type == "tool_result" {
+			t.resolve(b.ToolUseID)
+			answered = true
+		}
+	}
+	text := extractUserText(e.Message.Content)
+	switch {
+	case strings.HasPrefix(text, "[Request interrupted by user"):
+		// Interrupting abandons the tool calls in flight.
+		t.pending = make(map[string]ContentBlock)
+		t.order = nil
+		t.state.Status = "idle"
+	case answered:
+		t.state.Status = "active"
+	case e.IsMeta || text == "" || isSystemReminder(text):
+	default:
+		// A new prompt: the agent is working on it.
+		t.state.Status = "active"
+	}
+}
+
+func (t *StatusTracker) resolve(id string) {
+	if _, ok := t.pending[id]; !ok {
+		return
+	}
+	delete(t.pending, id)
+	for i, p := range t.order {
+		if p == id {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package agentlog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	logPrompt     = `{"type":"user","message":{"role":"user","content":"fix the tests"}}`
+	logToolUse    = `{"type":"assistant","message":{"role":"assistant","model":"claude-x","stop_reason":"tool_use","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]}}`
+	logToolResult = `{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}`
+	logEndTurn    = `{"type":"assistant","message":{"role":"assistant","model":"claude-x","stop_reason":"end_turn","content":[{"type":"text","text":"All green."}]}}`
+	logAsk        = `{"type":"assistant","message":{"role":"assistant","model":"claude-x","stop_reason":"tool_use","content":[{"type":"tool_use","id":"q1","name":"AskUserQuestion","input":{"questions":[{"question":"Which database?","options":[{"label":"Postgres"}]}]}}]}}`
+	logAnswer     = `{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"q1","content":"Postgres"}]}}`
+	logInterrupt  = `{"type":"user","message":{"role":"user","content":[{"type":"text","text":"[Request interrupted by user for tool use]"}]}}`
+	logAPIError   = `{"type":"assistant","isApiErrorMessage":true,"message":{"role":"assistant","model":"<synthetic>","content":[{"type":"text","text":"API Error: 529"}]}}`
+	logSidechain  = `{"type":"assistant","isSidechain":true,"message":{"role":"assistant","model":"claude-x","stop_reason":"end_turn","content":[{"type":"text","text":"subagent done"}]}}`
+)
+
+func parseEntries(t *testing.T, lines ...string) []Entry {
+	t.Helper()
+	var entries []Entry
+	for _, l := range lines {
+		var e Entry
+		if err := json.Unmarshal([]byte(l), &e); err != nil {
+			t.Fatalf("bad fixture %s: %v", l, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestStatusTrackerApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    string
+		pending string
+		prompt  string
+	}{
+		{"prompt", []string{logPrompt}, "active", "", ""},
+		{"tool running", []string{logPrompt, logToolUse}, "active", "Bash", ""},
+		{"tool answered", []string{logPrompt, logToolUse, logToolResult}, "active", "", ""},
+		{"end turn", []string{logPrompt, logToolUse, logToolResult, logEndTurn}, "done", "", ""},
+		{"question", []string{logPrompt, logAsk}, "waiting", "AskUserQuestion", "Which database?"},
+		{"question answered", []string{logPrompt, logAsk, logAnswer}, "active", "", ""},
+		{"interrupted", []string{logPrompt, logToolUse, logInterrupt}, "idle", "", ""},
+		{"api error", []string{logPrompt, logAPIError}, "error", "", ""},
+		{"sidechain ignored", []string{logPrompt, logToolUse, logSidechain}, "active", "Bash", ""},
+		{"new prompt after done", []string{logEndTurn, logPrompt}, "active", "", ""},
+	}
+	for _, tt := range tests {
+		tr := NewStatusTracker("")
+		tr.Apply(parseEntries(t, tt.lines...))
+		got := tr.State()
+		if got.Status != tt.want || got.Pending != tt.pending || got.Prompt != tt.prompt {
+			t.Errorf("%s: state = %+v, want {%s %s %s}", tt.name, got, tt.want, tt.pending, tt.prompt)
+		}
+	}
+}
+
+func TestStatusTrackerUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	tr := NewStatusTracker(path)
+	if _, err := tr.Update(); err == nil {
+		t.Fatal("expected error for missing log")
+	}
+
+	os.WriteFile(path, []byte(logPrompt+"\n"+logToolUse+"\n"), 0644)
+	if st, _ := tr.Update(); st.Status != "active" || st.Pending != "Bash" {
+		t.Fatalf("state = %+v, want active with Bash pending", st)
+	}
+
+	// A half-written entry is left for the next update.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(logToolResult + "\n" + logEndTurn[:20])
+	if st, _ := tr.Update(); st.Status != "active" || st.Pending != "" {
+		t.Fatalf("state = %+v, want active with nothing pending", st)
+	}
+	f.WriteString(logEndTurn[20:] + "\n")
+	f.Close()
+	if st, _ := tr.Update(); st.Status != "done" {
+		t.Fatalf("state = %+v, want done", st)
+	}
+}
@@ -4,22 +4,35 @@ import "encoding/json"
 
 // Entry represents a single JSONL line from a Claude Code session log.
 type Entry struct {
-	Type      string          `json:"type"`
-	SessionID string          `json:"sessionId"`
-	Timestamp string          `json:"timestamp"`
-	Message   *Message        `json:"message"`
-	CWD       string          `json:"cwd"`
-	Version   string          `json:"version"`
-	IsMeta    bool            `json:"isMeta"`
-	Raw       json.RawMessage `json:"-"`
+	Type              string          `json:"type"`
+	SessionID         string          `json:"sessionId"`
+	RequestID         string          `json:"requestId"`
+	Timestamp         string          `json:"timestamp"`
+	Message           *Message        `json:"message"`
+	CWD               string          `json:"cwd"`
+	Version           string          `json:"version"`
+	IsMeta            bool            `json:"isMeta"`
+	IsSidechain       bool            `json:"isSidechain"`       // subagent traffic
+	IsAPIErrorMessage bool            `json:"isApiErrorMessage"` // synthetic message reporting an API error
+	Raw               json.RawMessage `json:"-"`
 }
 
 // Message is the message field within a JSONL entry.
 type Message struct {
+	ID         string          `json:"id"`
 	Role       string          `json:"role"`
 	Content    json.RawMessage `json:"content"`
 	Model      string          `json:"model"`
-	StopReason string          `json:"stopReason"`
+	StopReason string          `json:"stop_reason"`
+	Usage      *Usage          `json:"usage"`
+}
+
+// Usage is the token usage reported with an assistant message.
+type Usage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
 }
 
 // ContentBlock represents a block within an assistant message's content array.
@@ -0,0 +1,116 @@
+package agentlog
+
+import (
+	"maps"
+	"time"
+)
+
+// Tokens counts the tokens used by one or more requests.
+type Tokens struct {
+	Input      int64 `json:"input"`
+	Output     int64 `json:"output"`
+	CacheWrite int64 `json:"cacheWrite"`
+	CacheRead  int64 `json:"cacheRead"`
+}
+
+// Add returns the sum of two counts.
+func (t Tokens) Add(o Tokens) Tokens {
+	return Tokens{
+		Input:      t.Input + o.Input,
+		Output:     t.Output + o.Output,
+		CacheWrite: t.CacheWrite + o.CacheWrite,
+		CacheRead:  t.CacheRead + o.CacheRead,
+	}
+}
+
+func (t Tokens) sub(o Tokens) Tokens {
+	return t.Add(Tokens{-o.Input, -o.Output, -o.CacheWrite, -o.CacheRead})
+}
+
+// counted is a message's usage as already added to the totals.
+type counted struct {
+	model  string
+	tokens Tokens
+}
+
+// UsageTracker adds up the token usage in a Claude Code session log, per
+// model. Claude Code writes an entry per content block of a response, each
+// repeating the response's usage, so every message is counted once, with
+// the usage of its latest entry.
+type UsageTracker struct {
+	log     follower
+	since   time.Time
+	byModel map[string]Tokens
+	seen    map[string]counted // by message ID
+}
+
+// NewUsageTracker creates a tracker for the log at path that counts the
+// entries timestamped at or after since, or all of them for a zero since.
+// Nothing is read until Update is called; the first Update reads the whole
+// log.
+func NewUsageTracker(path string, since time.Time) *UsageTracker {
+	t := &UsageTracker{log: follower{path: path}, since: since}
+	t.reset()
+	return t
+}
+
+func (t *UsageTracker) reset() {
+	t.byModel = make(map[string]Tokens)
+	t.seen = make(map[string]counted)
+}
+
+// Path returns the log the tracker follows.
+func (t *UsageTracker) Path() string { return t.log.path }
+
+// Update reads the entries appended since the last call and returns the
+// totals per model.
+func (t *UsageTracker) Update() (map[string]Tokens, error) {
+	entries, reset, err := t.log.next()
+	if reset {
+		t.reset()
+	}
+	if err != nil {
+		return t.ByModel(), err
+	}
+	t.Apply(entries)
+	return t.ByModel(), nil
+}
+
+// ByModel returns the totals per model as of the last Update or Apply.
+func (t *UsageTracker) ByModel() map[string]Tokens {
+	return maps.Clone(t.byModel)
+}
+
+// Apply adds the usage of log entries, oldest first.
+func (t *UsageTracker) Apply(entries []Entry) {
+	for _, e := range entries {
+		m := e.Message
+		if e.Type != "assistant" || m == nil || m.Usage == nil || m.Model == "<synthetic>" {
+			continue
+		}
+		if !t.since.IsZero() {
+			// A resumed session's log starts with the usage of its earlier runs.
+			ts, err := time.Parse(time.RFC3339, e.Timestamp)
+			if err != nil || ts.Before(t.since) {
+				continue
+			}
+		}
+		tokens := Tokens{
+			Input:      m.Usage.InputTokens,
+			Output:     m.Usage.OutputTokens,
+			CacheWrite: m.Usage.CacheCreationInputTokens,
+			CacheRead:  m.Usage.CacheReadInputTokens,
+		}
+		id := m.ID
+		if id == "" {
+			id = e.RequestID
+		}
+		if prev, ok := t.seen[id]; ok && id != "" {
+			t.byModel[prev.model] = t.byModel[prev.model].sub(prev.tokens)
+		}
+		if id != "" {
+			t.seen[id] = counted{model: m.Model, tokens: tokens}
+		}
+		t.byModel[m.Model] = t.byModel[m.Model].Add(tokens)
+	}
+}
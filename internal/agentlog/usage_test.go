@@ -0,0 +1,65 @@
+package agentlog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	logUsageText = `{"type":"assistant","requestId":"r1","message":{"id":"m1","role":"assistant","model":"claude-x","content":[{"type":"text","text":"Looking."}],"usage":{"input_tokens":10,"output_tokens":5,"cache_creation_input_tokens":100,"cache_read_input_tokens":1000}}}`
+	logUsageTool = `{"type":"assistant","requestId":"r1","message":{"id":"m1","role":"assistant","model":"claude-x","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{}}],"usage":{"input_tokens":10,"output_tokens":40,"cache_creation_input_tokens":100,"cache_read_input_tokens":1000}}}`
+	logUsageNext = `{"type":"assistant","requestId":"r2","message":{"id":"m2","role":"assistant","model":"claude-y","content":[{"type":"text","text":"Done."}],"usage":{"input_tokens":3,"output_tokens":7,"cache_creation_input_tokens":0,"cache_read_input_tokens":1100}}}`
+)
+
+func TestUsageTrackerApply(t *testing.T) {
+	tr := NewUsageTracker("", time.Time{})
+	// Both entries of m1 carry its usage; only the latest counts.
+	tr.Apply(parseEntries(t, logPrompt, logUsageText, logUsageTool, logUsageNext, logAPIError))
+
+	got := tr.ByModel()
+	if want := (Tokens{Input: 10, Output: 40, CacheWrite: 100, CacheRead: 1000}); got["claude-x"] != want {
+		t.Errorf("claude-x = %+v, want %+v", got["claude-x"], want)
+	}
+	if want := (Tokens{Input: 3, Output: 7, CacheRead: 1100}); got["claude-y"] != want {
+		t.Errorf("claude-y = %+v, want %+v", got["claude-y"], want)
+	}
+	if _, ok := got["<synthetic>"]; ok {
+		t.Error("synthetic messages should not count")
+	}
+}
+
+func TestUsageTrackerUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	os.WriteFile(path, []byte(logPrompt+"\n"+logUsageText+"\n"), 0644)
+	tr := NewUsageTracker(path, time.Time{})
+	got, err := tr.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["claude-x"].Output != 5 {
+		t.Errorf("output = %d, want 5", got["claude-x"].Output)
+	}
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(logUsageTool + "\n" + logUsageNext + "\n")
+	f.Close()
+	got, _ = tr.Update()
+	total := got["claude-x"].Add(got["claude-y"])
+	if want := (Tokens{Input: 13, Output: 47, CacheWrite: 100, CacheRead: 2100}); total != want {
+		t.Errorf("total = %+v, want %+v", total, want)
+	}
+}
+
+func TestUsageTrackerSince(t *testing.T) {
+	old := `{"type":"assistant","timestamp":"2025-06-01T10:00:00.000Z","message":{"id":"m1","model":"claude-x","usage":{"input_tokens":100,"output_tokens":50}}}`
+	recent := `{"type":"assistant","timestamp":"2025-06-02T10:00:00.000Z","message":{"id":"m2","model":"claude-x","usage":{"input_tokens":3,"output_tokens":7}}}`
+	undated := `{"type":"assistant","message":{"id":"m3","model":"claude-x","usage":{"input_tokens":1000,"output_tokens":1000}}}`
+
+	tr := NewUsageTracker("", time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC))
+	tr.Apply(parseEntries(t, old, recent, undated))
+	if got, want := tr.ByModel()["claude-x"], (Tokens{Input: 3, Output: 7}); got != want {
+		t.Errorf("claude-x = %+v, want %+v", got, want)
+	}
+}
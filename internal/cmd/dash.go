@@ -13,6 +13,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/x/ansi"
 	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
 	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 	"github.com/spf13/cobra"
 )
@@ -23,14 +24,20 @@ var dashCmd = &cobra.Command{
 	Long: `Unified dashboard for all your tmux sessions.
 
 Live preview with activity detection, diff viewer, PR/CI actions, and cleanup.
-Press ? inside the dashboard for the full key binding reference.`,
+Press ? inside the dashboard for the full key binding reference.
+
+--label shows only the sessions matching a label selector (see tsp label);
+--group groups sessions by the value of a label key, e.g. --group sprint.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if !tmuxpkg.IsInsideTmux() {
 			fmt.Fprintf(os.Stderr, "Error: dash must be run inside a tmux session\n")
 			os.Exit(1)
 		}
 
-		sessions, err := getTmuxSessions()
+		// One list-panes -a query covers every session's panes (in all
+		// windows) and cwd.
+		allPanes, err := tmuxpkg.ListAllPanes()
+		sessions, panesBySession := tmuxpkg.GroupPanesBySession(allPanes)
 		if err != nil || len(sessions) == 0 {
 			fmt.Println("No tmux sessions found")
 			return
@@ -50,20 +57,42 @@ Press ? inside the dashboard for the full key binding reference.`,
 			}
 		}
 
+		// Keep only the sessions matching --label, grouped by --group.
+		labelSel, _ := cmd.Flags().GetString("label")
+		groupBy, _ := cmd.Flags().GetString("group")
+		labels := service.NewLabelStore(service.LabelsPath()).All()
+		sessions = filterAndGroupSessions(sessions, labels, labelSel, groupBy)
+		if len(sessions) == 0 {
+			fmt.Printf("No tmux sessions match %s\n", labelSel)
+			return
+		}
+
 		m := dashModel{
 			sessions:      make([]dashSession, len(sessions)),
 			cfg:           cfg,
 			lastRefreshed: time.Now(),
+			groupBy:       groupBy,
+		}
+		// Resource usage needs /proc; elsewhere it isn't shown.
+		if sampler := service.NewResourceSampler(); sampler.Sample(time.Now()) == nil {
+			m.resources, m.lastSample = sampler, time.Now()
 		}
+		m.usage = service.NewUsageCache(service.NewPriceTable(cfg.Usage))
+		m.settings = service.NewSettingsStore(service.SettingsPath(), cfg.Dash)
 		for i, s := range sessions {
-			content := capturePaneContent(s, 0)
+			panes := panesBySession[s]
 			ds := dashSession{
 				name:        s,
 				status:      "active",
 				lastChanged: time.Now(),
 				prevContent: "",
-				paneContent: content,
+				panes:       panes,
+				labels:      labels[s],
 			}
+			ds.paneContent = captureDashPane(panes[0].PaneID)
+			ds.capturedPane = panes[0].PaneID
+			ds.capturedAt = time.Now()
+			cwd := panes[0].Cwd
 			// Check worktree first
 			if wt, ok := wtMap[s]; ok {
 				ds.isWorktree = true
@@ -73,13 +102,12 @@ Press ? inside the dashboard for the full key binding reference.`,
 				ds.gitPath = wt.Path
 			} else {
 				// For non-worktree sessions, detect git info from pane cwd
-				gitPath, branch := detectSessionGitInfo(s)
+				gitPath, branch := detectGitInfo(cwd)
 				if gitPath != "" {
 					ds.isGitRepo = true
 					ds.gitPath = gitPath
 					ds.branch = branch
 					// Check if this session is actually inside a git worktree
-					cwd := tmuxpkg.GetPaneCwd(s)
 					if cwd != "" {
 						gitDirOut, err1 := exec.Command("git", "-C", cwd, "rev-parse", "--git-dir").Output()
 						commonDirOut, err2 := exec.Command("git", "-C", cwd, "rev-parse", "--git-common-dir").Output()
@@ -117,6 +145,11 @@ Press ? inside the dashboard for the full key binding reference.`,
 	},
 }
 
+func init() {
+	dashCmd.Flags().String("label", "", "Only show sessions matching a label selector, e.g. sprint:42")
+	dashCmd.Flags().String("group", "", "Group sessions by the value of a label key, e.g. repo")
+}
+
 // dashSession merges live monitoring data with worktree/diff/PR data.
 type dashSession struct {
 	// Identity
@@ -133,6 +166,21 @@ type dashSession struct {
 	prevContent string
 	paneContent string
 
+	// Pane snapshot from the last list-panes -a query, and which pane the
+	// preview content was captured from and when.
+	panes        []tmuxpkg.PaneInfo
+	capturedPane string
+	capturedAt   time.Time
+
+	// CPU and memory of the session's process trees (Linux only)
+	resources service.ResourceUsage
+
+	labels []string
+
+	// Tokens and cost of the session's agents, refreshed while selected
+	usage   *service.UsageReport
+	usageAt time.Time
+
 	// Diff data (loaded lazily on first 'd' press)
 	filesChanged int
 	insertions   int
@@ -178,6 +226,11 @@ type dashModel struct {
 	mode          dashMode
 	statusMsg     string
 	textInput     textinput.Model
+	resources     *service.ResourceSampler // nil without /proc
+	lastSample    time.Time
+	usage         *service.UsageCache
+	settings      *service.SettingsStore // for session budgets
+	groupBy       string                 // label key sessions are grouped by (--group)
 }
 
 type dashTickMsg time.Time
@@ -218,21 +271,54 @@ func (m dashModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Only refresh pane content in live view
 		if m.view == dashViewLive {
 			now := time.Now()
+			allPanes, _ := tmuxpkg.ListAllPanes()
+			_, panesBySession := tmuxpkg.GroupPanesBySession(allPanes)
+			sampled := false
+			if m.resources != nil && now.Sub(m.lastSample) >= time.Duration(m.cfg.Resources.SampleS)*time.Second {
+				sampled = m.resources.Sample(now) == nil
+				m.lastSample = now
+			}
 			for i := range m.sessions {
 				s := &m.sessions[i]
+				if panes, ok := panesBySession[s.name]; ok {
+					s.panes = panes
+				}
+				if sampled {
+					s.resources = service.ResourceUsage{}
+					for _, p := range s.panes {
+						s.resources = s.resources.Add(m.resources.Tree(p.PID))
+					}
+				}
 				pane := 0
 				if i == m.cursor {
 					pane = m.previewPane
 				}
-				newContent := capturePaneContent(s.name, pane)
+				newContent := s.paneContent
+				if target, ok := s.paneAt(pane); ok {
+					// Skip the capture when the window has had no output since
+					// the last one — status inference sees it as unchanged.
+					if target.PaneID != s.capturedPane || paneNeedsCapture(target.Activity, s.capturedAt) {
+						newContent = captureDashPane(target.PaneID)
+						s.capturedPane = target.PaneID
+						s.capturedAt = now
+					}
+				} else {
+					newContent = capturePaneContent(s.name, pane)
+				}
 				s.prevContent = s.paneContent
 				if newContent != s.paneContent {
 					s.lastChanged = now
 				}
 				s.paneContent = newContent
+				if i == m.cursor && now.Sub(s.usageAt) >= dashUsageInterval {
+					m.updateUsage(s)
+					s.usageAt = now
+				}
 				s.status = inferStatus(
 					s.prevContent, s.paneContent, s.lastChanged, now,
 					m.cfg.Dash.ErrorPatterns, m.cfg.Dash.PromptPattern,
+					time.Duration(m.cfg.Dash.IdleAfterS)*time.Second,
+					time.Duration(m.cfg.Dash.DoneAfterS)*time.Second,
 				)
 			}
 			m.lastRefreshed = now
@@ -276,8 +362,7 @@ func (m dashModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case tea.KeyEnter:
 				prompt := strings.TrimSpace(m.textInput.Value())
 				if prompt != "" && m.cursor < len(m.sessions) {
-					target := fmt.Sprintf("%s:0.1", m.sessions[m.cursor].name)
-					tmuxpkg.SendKeys(target, prompt)
+					tmuxpkg.SendKeys(m.sessions[m.cursor].agentTarget(), prompt)
 					m.statusMsg = "Prompt sent to agent"
 					m.mode = dashStatusMessage
 				} else {
@@ -390,6 +475,23 @@ func (m *dashModel) moveCursor(delta int) {
 	m.previewPane = 0
 }
 
+// dashUsageInterval is how often the selected session's token usage is
+// re-read.
+const dashUsageInterval = 5 * time.Second
+
+// updateUsage re-reads the token usage of the Claude Code logs of the
+// session's agent panes, since the session was created.
+func (m *dashModel) updateUsage(s *dashSession) {
+	if len(s.panes) == 0 {
+		return
+	}
+	r := m.usage.Report(service.PaneUsageLogs(s.panes), s.panes[0].SessionCreated)
+	if b, ok := m.settings.Budget(s.name); ok {
+		r.BudgetUSD = b.USD
+	}
+	s.usage = &r
+}
+
 func (m *dashModel) loadDiffIfNeeded() {
 	if m.cursor >= len(m.sessions) {
 		return
@@ -586,8 +688,7 @@ func (m *dashModel) fixCI() {
 	if len(prompt) > 4000 {
 		prompt = prompt[:4000] + "\n\n[truncated]"
 	}
-	target := fmt.Sprintf("%s:0.1", s.name)
-	tmuxpkg.SendKeys(target, prompt)
+	tmuxpkg.PasteText(s.agentTarget(), prompt)
 	m.statusMsg = "CI failure logs sent to agent"
 	m.mode = dashStatusMessage
 }
@@ -621,8 +722,7 @@ func (m *dashModel) addressReviewComments() {
 	}
 	formatted := formatPRComments(comments)
 	prompt := fmt.Sprintf("Please address these PR review comments:\n\n%s", formatted)
-	target := fmt.Sprintf("%s:0.1", s.name)
-	tmuxpkg.SendKeys(target, prompt)
+	tmuxpkg.PasteText(s.agentTarget(), prompt)
 	m.statusMsg = fmt.Sprintf("Review comments sent to agent (%d comments)", len(comments))
 	m.mode = dashStatusMessage
 }
@@ -764,10 +864,24 @@ func (m dashModel) View() string {
 
 	// Left panel: session list
 	now := time.Now()
-	var sessionLines []string
-	start, end := visibleSessionRange(len(m.sessions), m.cursor, panelContentHeight)
-	for i := start; i < end; i++ {
-		s := m.sessions[i]
+	// With --group, each group starts with a header row.
+	var rows []string
+	cursorRow := 0
+	group := "\x00"
+	for i, s := range m.sessions {
+		if m.groupBy != "" {
+			if g := service.LabelGroup(s.labels, m.groupBy); g != group {
+				group = g
+				header := m.groupBy + ":" + g
+				if g == "" {
+					header = "no " + m.groupBy
+				}
+				rows = append(rows, lipgloss.NewStyle().Foreground(lipgloss.Color("245")).Bold(true).Render(ansi.Truncate(" "+header, leftWidth-1, "…")))
+			}
+		}
+		if i == m.cursor {
+			cursorRow = len(rows)
+		}
 		icon := statusIcon(s.status)
 		timeSince := formatTimeSince(s.lastChanged, now)
 
@@ -800,13 +914,15 @@ func (m dashModel) View() string {
 				Bold(true).
 				Foreground(lipgloss.Color("212")).
 				Background(lipgloss.Color("236"))
-			sessionLines = append(sessionLines, style.Render(fmt.Sprintf("▸%s", line)))
+			rows = append(rows, style.Render(fmt.Sprintf("▸%s", line)))
 		} else {
-			sessionLines = append(sessionLines, lipgloss.NewStyle().
+			rows = append(rows, lipgloss.NewStyle().
 				Foreground(dashStatusColor(s.status)).
 				Render(fmt.Sprintf(" %s", line)))
 		}
 	}
+	start, end := visibleSessionRange(len(rows), cursorRow, panelContentHeight)
+	sessionLines := rows[start:end]
 
 	leftPanel := panelStyle.Copy().
 		Width(leftWidth).
@@ -896,6 +1012,26 @@ func (m dashModel) viewDetailPanel(width, height int) string {
 		dim.Render(formatTimeSince(s.lastChanged, time.Now())),
 	))
 
+	if m.resources != nil {
+		usage := val
+		if overResourceLimit(s.resources, m.cfg.Resources) {
+			usage = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s", dim.Render(" Usage:"), usage.Render(formatResources(s.resources))))
+	}
+	if s.usage != nil && len(s.usage.Logs) > 0 {
+		cost := val
+		if s.usage.BudgetUSD > 0 && s.usage.CostUSD >= s.usage.BudgetUSD {
+			cost = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s", dim.Render("Tokens:"), val.Render(formatTokens(s.usage.Tokens))))
+		lines = append(lines, fmt.Sprintf("  %s %s", dim.Render("  Cost:"), cost.Render(formatCost(*s.usage))))
+	}
+
+	if len(s.labels) > 0 {
+		lines = append(lines, fmt.Sprintf("  %s %s", dim.Render("Labels:"), val.Render(strings.Join(s.labels, " "))))
+	}
+
 	// Git info
 	if s.isGitRepo {
 		lines = append(lines, fmt.Sprintf("  %s %s", dim.Render("Branch:"), val.Render(s.branch)))
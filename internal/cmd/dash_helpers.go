@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
 	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 )
 
@@ -20,8 +24,10 @@ type sessionInfo struct {
 	currentPane int
 }
 
-// inferStatus determines session status from pane content changes.
-func inferStatus(prev, current string, lastChanged, now time.Time, errorPatterns []string, promptPattern string) string {
+// inferStatus determines session status from pane content changes. Content
+// unchanged for idleAfter is idle, and for doneAfter with a prompt showing is
+// done.
+func inferStatus(prev, current string, lastChanged, now time.Time, errorPatterns []string, promptPattern string, idleAfter, doneAfter time.Duration) string {
 	// Check for error patterns first (highest priority)
 	for _, pattern := range errorPatterns {
 		if strings.Contains(current, pattern) {
@@ -37,7 +43,7 @@ func inferStatus(prev, current string, lastChanged, now time.Time, errorPatterns
 	elapsed := now.Sub(lastChanged)
 
 	// Check for shell prompt (done state)
-	if elapsed > 60*time.Second && promptPattern != "" {
+	if elapsed > doneAfter && promptPattern != "" {
 		if re, err := regexp.Compile(promptPattern); err == nil {
 			lines := strings.Split(strings.TrimRight(current, "\n"), "\n")
 			if len(lines) > 0 {
@@ -49,8 +55,8 @@ func inferStatus(prev, current string, lastChanged, now time.Time, errorPatterns
 		}
 	}
 
-	// Unchanged for >30s → idle
-	if elapsed > 30*time.Second {
+	// Unchanged for longer than idleAfter → idle
+	if elapsed > idleAfter {
 		return "idle"
 	}
 
@@ -72,6 +78,75 @@ func statusIcon(status string) string {
 	}
 }
 
+// formatResources renders a session's resource usage for the dash.
+func formatResources(u service.ResourceUsage) string {
+	return fmt.Sprintf("%.0f%% CPU  %s  %d children", u.CPUPercent, formatBytes(u.RSSBytes), u.Children)
+}
+
+// formatBytes renders a byte count in MB, or GB above 1 GB.
+func formatBytes(n int64) string {
+	if n >= 1<<30 {
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	}
+	return fmt.Sprintf("%d MB", n>>20)
+}
+
+// overResourceLimit reports whether usage is over a limit in the resources
+// config.
+func overResourceLimit(u service.ResourceUsage, cfg config.ResourcesConfig) bool {
+	return (cfg.CPUPercent > 0 && u.CPUPercent > cfg.CPUPercent) ||
+		(cfg.RSSMB > 0 && u.RSSBytes > int64(cfg.RSSMB)<<20)
+}
+
+// formatTokens renders token counts, e.g. "12.3k in  4.1k out  1.2M cached".
+// Cached counts both cache writes and reads.
+func formatTokens(t agentlog.Tokens) string {
+	return fmt.Sprintf("%s in  %s out  %s cached", formatCount(t.Input), formatCount(t.Output), formatCount(t.CacheWrite+t.CacheRead))
+}
+
+// formatCount renders a count with a k or M suffix above a thousand.
+func formatCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// formatCost renders an estimated cost, with the budget when there is one.
+func formatCost(r service.UsageReport) string {
+	if r.BudgetUSD > 0 {
+		return fmt.Sprintf("$%.2f of $%.2f", r.CostUSD, r.BudgetUSD)
+	}
+	return fmt.Sprintf("$%.2f", r.CostUSD)
+}
+
+// filterAndGroupSessions keeps the sessions whose labels match selector
+// and, with groupBy, orders them by the value of that label key: groups
+// alphabetically, sessions without the label last, and the original order
+// within each group.
+func filterAndGroupSessions(names []string, labels map[string][]string, selector, groupBy string) []string {
+	var out []string
+	for _, name := range names {
+		if service.MatchLabels(labels[name], selector) {
+			out = append(out, name)
+		}
+	}
+	if groupBy != "" {
+		sort.SliceStable(out, func(i, j int) bool {
+			gi, gj := service.LabelGroup(labels[out[i]], groupBy), service.LabelGroup(labels[out[j]], groupBy)
+			if gi == "" || gj == "" {
+				return gj == "" && gi != ""
+			}
+			return gi < gj
+		})
+	}
+	return out
+}
+
 func formatTimeSince(since, now time.Time) string {
 	d := now.Sub(since)
 	switch {
@@ -84,10 +159,9 @@ func formatTimeSince(since, now time.Time) string {
 	}
 }
 
-// detectSessionGitInfo checks if a session's working directory is inside a git repo.
+// detectGitInfo checks if a directory is inside a git repo.
 // Returns the git toplevel path and current branch name, or empty strings if not a git repo.
-func detectSessionGitInfo(sessionName string) (gitPath, branch string) {
-	cwd := tmuxpkg.GetPaneCwd(sessionName)
+func detectGitInfo(cwd string) (gitPath, branch string) {
 	if cwd == "" {
 		return "", ""
 	}
@@ -108,3 +182,46 @@ func detectSessionGitInfo(sessionName string) (gitPath, branch string) {
 	branch = strings.TrimSpace(string(branchOut))
 	return gitPath, branch
 }
+
+// paneAt returns the n-th pane of the session's snapshot, wrapping around so
+// tab-cycling past the last pane returns to the first.
+func (s dashSession) paneAt(n int) (tmuxpkg.PaneInfo, bool) {
+	if len(s.panes) == 0 {
+		return tmuxpkg.PaneInfo{}, false
+	}
+	return s.panes[n%len(s.panes)], true
+}
+
+// agentTarget returns the tmux target for the session's agent: the first pane
+// running an agent CLI in any window, else the second pane of the first
+// window (where spawn starts the agent), else the session itself.
+func (s dashSession) agentTarget() string {
+	for _, p := range s.panes {
+		if service.PaneTypeFromProcess(p.Command) == "agent" {
+			return p.PaneID
+		}
+	}
+	if len(s.panes) > 1 && s.panes[1].WindowIndex == s.panes[0].WindowIndex {
+		return s.panes[1].PaneID
+	}
+	return s.name
+}
+
+// paneNeedsCapture reports whether a pane may have new output since it was
+// last captured. tmux records window activity with second resolution, so any
+// activity in the same second as the capture counts as new.
+func paneNeedsCapture(activity, capturedAt time.Time) bool {
+	if capturedAt.IsZero() {
+		return true
+	}
+	return !activity.Before(capturedAt.Truncate(time.Second))
+}
+
+// captureDashPane captures a pane by its stable tmux pane ID.
+func captureDashPane(paneID string) string {
+	out, err := tmuxpkg.Command(tmuxpkg.BuildCapturePaneArgs(paneID)...).Output()
+	if err != nil {
+		return fmt.Sprintf("(unable to capture: %v)", err)
+	}
+	return string(out)
+}
@@ -1,8 +1,14 @@
 package cmd
 
 import (
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 )
 
 func TestInferStatus(t *testing.T) {
@@ -56,7 +62,7 @@ func TestInferStatus(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := inferStatus(tt.prev, tt.current, tt.lastChanged, now, patterns, promptPattern)
+			got := inferStatus(tt.prev, tt.current, tt.lastChanged, now, patterns, promptPattern, 30*time.Second, 60*time.Second)
 			if got != tt.wantStatus {
 				t.Errorf("inferStatus() = %q, want %q", got, tt.wantStatus)
 			}
@@ -104,3 +110,93 @@ func TestFormatTimeSince(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatResources(t *testing.T) {
+	u := service.ResourceUsage{CPUPercent: 152.4, RSSBytes: 3 << 29, Children: 4}
+	if got, want := formatResources(u), "152% CPU  1.5 GB  4 children"; got != want {
+		t.Errorf("formatResources = %q, want %q", got, want)
+	}
+	if got := formatBytes(340 << 20); got != "340 MB" {
+		t.Errorf("formatBytes = %q, want 340 MB", got)
+	}
+	cfg := config.ResourcesConfig{CPUPercent: 100}
+	if !overResourceLimit(u, cfg) {
+		t.Error("expected 152% CPU to be over a 100% limit")
+	}
+	if overResourceLimit(u, config.ResourcesConfig{}) {
+		t.Error("zero limits should be off")
+	}
+}
+
+func TestPaneNeedsCapture(t *testing.T) {
+	captured := time.Unix(1700000000, 500_000_000)
+	if !paneNeedsCapture(time.Unix(1700000000, 0), captured) {
+		t.Error("activity in the same second as the capture should need a capture")
+	}
+	if paneNeedsCapture(time.Unix(1699999999, 0), captured) {
+		t.Error("activity before the capture should not need a capture")
+	}
+	if !paneNeedsCapture(time.Unix(1699999999, 0), time.Time{}) {
+		t.Error("a pane never captured should need a capture")
+	}
+}
+
+func TestDashSessionPaneAtWraps(t *testing.T) {
+	s := dashSession{panes: []tmuxpkg.PaneInfo{{PaneID: "%1"}, {PaneID: "%2"}}}
+	if p, ok := s.paneAt(3); !ok || p.PaneID != "%2" {
+		t.Errorf("paneAt(3) = %+v, %v; want %%2", p, ok)
+	}
+	if _, ok := (dashSession{}).paneAt(0); ok {
+		t.Error("expected no pane for empty snapshot")
+	}
+}
+
+func TestDashSessionAgentTarget(t *testing.T) {
+	s := dashSession{name: "work", panes: []tmuxpkg.PaneInfo{
+		{WindowIndex: 0, PaneID: "%1", Command: "nvim"},
+		{WindowIndex: 0, PaneID: "%2", Command: "zsh"},
+		{WindowIndex: 1, PaneID: "%3", Command: "claude"},
+	}}
+	if got := s.agentTarget(); got != "%3" {
+		t.Errorf("agentTarget() = %q, want agent pane in window 1 (%%3)", got)
+	}
+	s.panes[2].Command = "zsh"
+	if got := s.agentTarget(); got != "%2" {
+		t.Errorf("agentTarget() = %q, want fallback to second pane (%%2)", got)
+	}
+	if got := (dashSession{name: "solo"}).agentTarget(); got != "solo" {
+		t.Errorf("agentTarget() = %q, want session name", got)
+	}
+}
+
+func TestFormatUsage(t *testing.T) {
+	tokens := agentlog.Tokens{Input: 950, Output: 12_340, CacheWrite: 400_000, CacheRead: 1_100_000}
+	if got, want := formatTokens(tokens), "950 in  12.3k out  1.5M cached"; got != want {
+		t.Errorf("formatTokens = %q, want %q", got, want)
+	}
+	if got, want := formatCost(service.UsageReport{CostUSD: 1.234}), "$1.23"; got != want {
+		t.Errorf("formatCost = %q, want %q", got, want)
+	}
+	if got, want := formatCost(service.UsageReport{CostUSD: 6, BudgetUSD: 5}), "$6.00 of $5.00"; got != want {
+		t.Errorf("formatCost with budget = %q, want %q", got, want)
+	}
+}
+
+func TestFilterAndGroupSessions(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e"}
+	labels := map[string][]string{
+		"a": {"repo:web", "sprint:42"},
+		"b": {"repo:api"},
+		"c": {"sprint:42"},
+		"e": {"repo:api", "sprint:42"},
+	}
+	if got, want := filterAndGroupSessions(names, labels, "", "repo"), []string{"b", "e", "a", "c", "d"}; !slices.Equal(got, want) {
+		t.Errorf("grouped by repo = %v, want %v", got, want)
+	}
+	if got, want := filterAndGroupSessions(names, labels, "sprint:42", "repo"), []string{"e", "a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("sprint:42 grouped by repo = %v, want %v", got, want)
+	}
+	if got := filterAndGroupSessions(names, labels, "", ""); !slices.Equal(got, names) {
+		t.Errorf("ungrouped = %v, want the original order", got)
+	}
+}
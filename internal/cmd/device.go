@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -363,7 +364,7 @@ func runDeviceNotify(cmd *cobra.Command, args []string) {
 		})
 	}
 
-	if err := push.Send(messages); err != nil {
+	if err := push.Send(context.Background(), messages); err != nil {
 		fmt.Fprintf(os.Stderr, "Push failed: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Inspect the event journal",
+	Long: `While tsp serve runs, every event (status changes, CI results, fix
+prompts, cleanups, ...) is appended to ~/.tsp/events/, one file per day, with
+a sequence number and timestamp. GET /api/events?since=<seq> serves the same
+journal to clients catching up.`,
+}
+
+var eventsTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Print the latest events",
+	Long: `Print the latest journaled events, oldest first, and with --follow keep
+printing new ones as tsp serve writes them.
+
+Examples:
+  tsp events tail
+  tsp events tail --type ci.status.changed --session myapp-feat-auth
+  tsp events tail -n 100 -f`,
+	Args: cobra.NoArgs,
+	Run:  runEventsTail,
+}
+
+func init() {
+	eventsTailCmd.Flags().StringSlice("type", nil, "Only events of this type (repeatable, or comma-separated)")
+	eventsTailCmd.Flags().String("session", "", "Only events about this session (or sessions matching a glob)")
+	eventsTailCmd.Flags().IntP("lines", "n", 20, "Number of events to print")
+	eventsTailCmd.Flags().BoolP("follow", "f", false, "Keep printing new events")
+	eventsCmd.AddCommand(eventsTailCmd)
+}
+
+func runEventsTail(cmd *cobra.Command, args []string) {
+	types, _ := cmd.Flags().GetStringSlice("type")
+	session, _ := cmd.Flags().GetString("session")
+	lines, _ := cmd.Flags().GetInt("lines")
+	follow, _ := cmd.Flags().GetBool("follow")
+	filter := service.EventFilter{Types: types, Session: session}
+
+	cfg, _ := config.Load()
+	journal := service.NewJournal(service.EventsDir(), cfg.Events.RetentionDays, nil)
+	last := journal.LastSeq()
+	if lines > 0 {
+		entries, err := journal.Tail(lines, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading events: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			printJournalEntry(e)
+			last = max(last, e.Seq)
+		}
+	}
+	if !follow {
+		return
+	}
+	for {
+		time.Sleep(500 * time.Millisecond)
+		entries, err := journal.Entries(last, service.EventFilter{}, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading events: %v\n", err)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			last = e.Seq
+			if filter.Match(e.Type, e.Session) {
+				printJournalEntry(e)
+			}
+		}
+	}
+}
+
+func printJournalEntry(e service.JournalEntry) {
+	fmt.Printf("%6d  %s  %-18s %-24s %s\n", e.Seq, e.Time.Local().Format("2006-01-02 15:04:05"), e.Type, e.Session, e.Data)
+}
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
 	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 	"github.com/spf13/cobra"
 )
@@ -20,7 +22,7 @@ For each branch:
 1. Creates the branch from current branch if it doesn't exist
 2. Creates worktree under ~/work/code/<repo-name>-<branch>
 3. Detects and runs the appropriate package manager (yarn, npm, pnpm, bun)
-4. Creates tmux session with neovim (left) and claude (right)`,
+4. Creates tmux session with neovim (left) and claude (right), or the --layout you pick`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		branches := args
@@ -43,6 +45,7 @@ For each branch:
 		}
 
 		repoName := filepath.Base(repoRoot)
+		layout := layoutFromFlag(cmd)
 
 		for _, branch := range branches {
 			fmt.Printf("Processing branch: %s\n", branch)
@@ -80,7 +83,10 @@ For each branch:
 
 			sessionName := tmuxpkg.SanitizeSessionName(fmt.Sprintf("%s-%s", repoName, branch))
 			fmt.Printf("Creating tmux session '%s' with neovim and claude...\n", sessionName)
-			createGitWorktreeSession(sessionName, worktreePath)
+			if err := createGitWorktreeSession(sessionName, worktreePath, layout); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: Failed to create tmux session '%s': %v. Skipping.\n", sessionName, err)
+				continue
+			}
 
 			fmt.Printf("Tmux session '%s' created successfully.\n", sessionName)
 		}
@@ -188,7 +194,31 @@ func runPackageManager(pm, path string) error {
 	return cmd.Run()
 }
 
-func createGitWorktreeSession(sessionName, path string) {
+// createGitWorktreeSession replaces any session of the same name with a new
+// one laid out as described.
+func createGitWorktreeSession(sessionName, path string, layout tmuxpkg.Layout) error {
 	tmuxpkg.KillSession(sessionName)
-	tmuxpkg.CreateTwoPaneSession(sessionName, path, "nvim", "claude --dangerously-skip-permissions")
+	_, _, err := tmuxpkg.CreateLayoutSession(sessionName, path, layout)
+	return err
+}
+
+// layoutFromFlag resolves the command's --layout flag against config,
+// exiting on an unknown or invalid layout.
+func layoutFromFlag(cmd *cobra.Command) tmuxpkg.Layout {
+	name, _ := cmd.Flags().GetString("layout")
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	layout, err := service.ResolveLayout(cfg, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return layout
+}
+
+func init() {
+	wtxNewCmd.Flags().String("layout", "", "Session layout from config (default: spawn.layout, else nvim + agent)")
 }
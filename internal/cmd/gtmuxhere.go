@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 	"github.com/spf13/cobra"
@@ -14,9 +15,11 @@ var wtxHereCmd = &cobra.Command{
 	Short: "Create tmux session in current directory",
 	Long: `Create a tmux session in the current directory with the naming convention: ${repo_name}-${branch}
 
-The session will have two panes:
+By default the session will have two panes:
 - Left pane: neovim
-- Right pane: claude`,
+- Right pane: claude
+
+Use --layout to pick a layout from config instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if !isGitRepo() {
 			fmt.Fprintf(os.Stderr, "Error: Not in a git repository\n")
@@ -41,9 +44,20 @@ The session will have two panes:
 
 		fmt.Printf("Creating tmux session '%s' in current directory...\n", sessionName)
 
-		createGitWorktreeSession(sessionName, currentDir)
+		if err := createGitWorktreeSession(sessionName, currentDir, layoutFromFlag(cmd)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to create tmux session '%s': %v\n", sessionName, err)
+			os.Exit(1)
+		}
 
 		fmt.Printf("Tmux session '%s' created successfully.\n", sessionName)
-		fmt.Printf("Attach with: tmux attach-session -t '%s'\n", sessionName)
+		socketFlags := strings.Join(tmuxpkg.DefaultServer().Args(), " ")
+		if socketFlags != "" {
+			socketFlags += " "
+		}
+		fmt.Printf("Attach with: tmux %sattach-session -t '%s'\n", socketFlags, sessionName)
 	},
-}
\ No newline at end of file
+}
+
+func init() {
+	wtxHereCmd.Flags().String("layout", "", "Session layout from config (default: spawn.layout, else nvim + agent)")
+}
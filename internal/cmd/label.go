@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+	"github.com/spf13/cobra"
+)
+
+var labelCmd = &cobra.Command{
+	Use:   "label",
+	Short: "Label sessions and act on them by label",
+	Long: `Attach persistent labels to sessions, such as repo:api, sprint:42 or
+owner:alice, and act on every session matching a label at once. Labels are
+kept in ~/.tsp/labels.json; tsp spawn adds spawned, repo:<repo> and
+layout:<layout> automatically.
+
+A selector with a ':' matches that exact label, one without matches every
+label with that key (sprint matches sprint:42). Comma-separated selectors
+must all match.
+
+Examples:
+  tsp label add myapp-feat-auth sprint:42 owner:alice
+  tsp label rm myapp-feat-auth owner
+  tsp label ls sprint:42
+  tsp label send repo:api "rebase on main and rerun the tests"
+  tsp label kill sprint:41 --cleanup`,
+}
+
+var labelAddCmd = &cobra.Command{
+	Use:   "add <session> <label>...",
+	Short: "Add labels to a session (replacing labels with the same key)",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		store := service.NewLabelStore(service.LabelsPath())
+		if err := store.Add(args[0], args[1:]...); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %s\n", args[0], strings.Join(store.Labels(args[0]), " "))
+	},
+}
+
+var labelRmCmd = &cobra.Command{
+	Use:   "rm <session> [label...]",
+	Short: "Remove labels (or every label with a key) from a session",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if !all && len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Error: give labels to remove, or --all")
+			os.Exit(1)
+		}
+		store := service.NewLabelStore(service.LabelsPath())
+		var err error
+		if all {
+			err = store.Set(args[0], nil)
+		} else {
+			err = store.Remove(args[0], args[1:]...)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %s\n", args[0], strings.Join(store.Labels(args[0]), " "))
+	},
+}
+
+var labelLsCmd = &cobra.Command{
+	Use:   "ls [selector]",
+	Short: "List sessions with their labels",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		running, _ := getTmuxSessions()
+		all := service.NewLabelStore(service.LabelsPath()).All()
+		names := slices.Clone(running)
+		for name := range all {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SESSION\tLABELS")
+		fmt.Fprintln(w, "-------\t------")
+		for _, name := range names {
+			if !service.MatchLabels(all[name], args...) {
+				continue
+			}
+			shown := name
+			if !slices.Contains(running, name) {
+				shown += " (not running)"
+			}
+			fmt.Fprintf(w, "%s\t%s\n", shown, strings.Join(all[name], " "))
+		}
+		w.Flush()
+	},
+}
+
+var labelKillCmd = &cobra.Command{
+	Use:   "kill <selector>",
+	Short: "Kill every session matching a label",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cleanup, _ := cmd.Flags().GetBool("cleanup")
+		yes, _ := cmd.Flags().GetBool("yes")
+		sessions := labelledSessions(args[0])
+		if len(sessions) == 0 {
+			fmt.Printf("No running sessions match %s\n", args[0])
+			return
+		}
+		if !yes && !confirm(fmt.Sprintf("Kill %s?", strings.Join(sessions, ", "))) {
+			return
+		}
+		cfg, _ := config.Load()
+		labels := service.NewLabelStore(service.LabelsPath())
+		settings := service.NewSettingsStore(service.SettingsPath(), cfg.Dash)
+		servers := monitoredServers(cfg)
+		for _, name := range sessions {
+			var git service.GitInfo
+			if cleanup {
+				git = service.DetectSessionGitInfoFull(name)
+			}
+			err := service.KillSession(tmuxpkg.DefaultServer(), name, cleanup && git.IsWorktree, git.WorktreePath, git.Branch, git.GitPath)
+			if err == nil {
+				err = service.ForgetSession(labels, settings, tmuxpkg.DefaultServer(), name, servers)
+			}
+			reportBulk(name, err)
+		}
+	},
+}
+
+var labelSendCmd = &cobra.Command{
+	Use:   "send <selector> <text>",
+	Short: "Send text to the agent of every session matching a label",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runOnAgents(args[0], func(pane string) error {
+			return service.SendToPane(tmuxpkg.DefaultServer(), pane, args[1])
+		})
+	},
+}
+
+var labelInterruptCmd = &cobra.Command{
+	Use:   "interrupt <selector>",
+	Short: "Interrupt (Escape) the agent of every session matching a label",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runOnAgents(args[0], func(pane string) error {
+			return tmuxpkg.SendRawKey(pane, "Escape")
+		})
+	},
+}
+
+func init() {
+	labelRmCmd.Flags().Bool("all", false, "Remove every label of the session")
+	labelKillCmd.Flags().Bool("cleanup", false, "Also remove worktrees and their branches")
+	labelKillCmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+	labelCmd.AddCommand(labelAddCmd)
+	labelCmd.AddCommand(labelRmCmd)
+	labelCmd.AddCommand(labelLsCmd)
+	labelCmd.AddCommand(labelKillCmd)
+	labelCmd.AddCommand(labelSendCmd)
+	labelCmd.AddCommand(labelInterruptCmd)
+}
+
+// labelledSessions returns the running sessions matching a label selector.
+func labelledSessions(selector string) []string {
+	if strings.Trim(selector, ", ") == "" {
+		fmt.Fprintln(os.Stderr, "Error: empty label selector")
+		os.Exit(1)
+	}
+	running, err := getTmuxSessions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting tmux sessions: %v\n", err)
+		os.Exit(1)
+	}
+	all := service.NewLabelStore(service.LabelsPath()).All()
+	var out []string
+	for _, name := range running {
+		if labels := all[name]; len(labels) > 0 && service.MatchLabels(labels, selector) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// monitoredServers returns the tmux servers tsp serve monitors: the default
+// one and those listed under tmux.servers.
+func monitoredServers(cfg *config.Config) []tmuxpkg.Server {
+	servers := []tmuxpkg.Server{tmuxpkg.DefaultServer()}
+	for _, ts := range cfg.Tmux.Servers {
+		servers = append(servers, tmuxpkg.Server{Name: ts.Name, SocketName: ts.SocketName, SocketPath: ts.SocketPath})
+	}
+	return servers
+}
+
+// runOnAgents runs fn on the agent pane of every running session matching
+// a label selector.
+func runOnAgents(selector string, fn func(pane string) error) {
+	sessions := labelledSessions(selector)
+	if len(sessions) == 0 {
+		fmt.Printf("No running sessions match %s\n", selector)
+		return
+	}
+	allPanes, _ := tmuxpkg.ListAllPanes()
+	_, panesBySession := tmuxpkg.GroupPanesBySession(allPanes)
+	for _, name := range sessions {
+		target := dashSession{name: name, panes: panesBySession[name]}.agentTarget()
+		reportBulk(name, fn(target))
+	}
+}
+
+func reportBulk(session string, err error) {
+	if err != nil {
+		fmt.Printf("  ✗ %s: %v\n", session, err)
+	} else {
+		fmt.Printf("  ✓ %s\n", session)
+	}
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
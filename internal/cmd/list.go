@@ -3,12 +3,12 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
 	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 	"github.com/spf13/cobra"
 )
@@ -29,9 +29,25 @@ var listCmd = &cobra.Command{
 			return
 		}
 
+		labelSel, _ := cmd.Flags().GetString("label")
+		groupBy, _ := cmd.Flags().GetString("group")
+		labels := service.NewLabelStore(service.LabelsPath()).All()
+		sessions = filterAndGroupSessions(sessions, labels, labelSel, groupBy)
+		if len(sessions) == 0 {
+			fmt.Printf("No tmux sessions match %s\n", labelSel)
+			return
+		}
+
 		items := make([]list.Item, len(sessions))
 		for i, session := range sessions {
-			items[i] = sessionItem{name: session}
+			item := sessionItem{name: session, labels: labels[session]}
+			if groupBy != "" {
+				item.group = service.LabelGroup(item.labels, groupBy)
+				if item.group == "" {
+					item.group = "no " + groupBy
+				}
+			}
+			items[i] = item
 		}
 
 		delegate := list.NewDefaultDelegate()
@@ -58,13 +74,29 @@ var listCmd = &cobra.Command{
 	},
 }
 
+func init() {
+	listCmd.Flags().String("label", "", "Only list sessions matching a label selector, e.g. sprint:42")
+	listCmd.Flags().String("group", "", "Group sessions by the value of a label key, e.g. repo")
+}
+
 type sessionItem struct {
-	name string
+	name   string
+	labels []string
+	group  string // with --group, the value of the grouping label
 }
 
-func (i sessionItem) Title() string       { return i.name }
+func (i sessionItem) Title() string {
+	title := i.name
+	if i.group != "" {
+		title = i.group + " › " + title
+	}
+	if len(i.labels) > 0 {
+		title += "  [" + strings.Join(i.labels, " ") + "]"
+	}
+	return title
+}
 func (i sessionItem) Description() string { return "" }
-func (i sessionItem) FilterValue() string { return i.name }
+func (i sessionItem) FilterValue() string { return i.name + " " + strings.Join(i.labels, " ") }
 
 type sessionModel struct {
 	list     list.Model
@@ -103,7 +135,7 @@ func (m sessionModel) View() string {
 }
 
 func getTmuxSessions() ([]string, error) {
-	cmd := exec.Command("tmux", "list-sessions", "-F", "#{session_name}")
+	cmd := tmuxpkg.Command("list-sessions", "-F", "#{session_name}")
 	output, err := cmd.Output()
 	if err != nil {
 		if strings.Contains(err.Error(), "no server running") {
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -24,9 +23,23 @@ Without arguments, opens an interactive TUI:
 - Enter to jump to a session
 - q/Esc to quit
 
-With a session name, prints a one-shot capture and exits.`,
+With a session name, prints a one-shot capture and exits.
+
+With a session name and --scrollback, pages through the pane's history
+(j/k: line, space/b: page, g/G: top/bottom). Following new output resumes
+at the bottom.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		if scrollback, _ := cmd.Flags().GetBool("scrollback"); scrollback {
+			if len(args) == 0 {
+				fmt.Fprintf(os.Stderr, "Error: --scrollback requires a session name\n")
+				os.Exit(1)
+			}
+			pane, _ := cmd.Flags().GetInt("pane")
+			runPeekScrollback(args[0], pane)
+			return
+		}
+
 		if len(args) == 1 {
 			// Direct mode: capture and print
 			content := capturePaneContent(args[0], 0)
@@ -68,6 +81,11 @@ With a session name, prints a one-shot capture and exits.`,
 	},
 }
 
+func init() {
+	peekCmd.Flags().Bool("scrollback", false, "Page through a session pane's history")
+	peekCmd.Flags().Int("pane", 0, "Pane to page through with --scrollback, counted across windows")
+}
+
 type tickMsg time.Time
 
 type peekModel struct {
@@ -226,15 +244,19 @@ func (m peekModel) View() string {
 	return fmt.Sprintf("%s\n%s", title, layout)
 }
 
+// capturePaneContent captures the n-th pane of a session, counting panes
+// across all windows and wrapping around past the last one.
 func capturePaneContent(session string, pane int) string {
-	target := fmt.Sprintf("%s:0.%d", session, pane)
-	cmd := exec.Command("tmux", "capture-pane", "-t", target, "-p", "-e")
-	output, err := cmd.Output()
+	panes, err := tmuxpkg.ListSessionPanes(session)
+	if err != nil {
+		return fmt.Sprintf("(unable to capture: %v)", err)
+	}
+	if len(panes) == 0 {
+		return "(no panes)"
+	}
+	target := panes[pane%len(panes)].PaneID
+	output, err := tmuxpkg.Command(tmuxpkg.BuildCapturePaneArgs(target)...).Output()
 	if err != nil {
-		// If pane doesn't exist, reset to pane 0
-		if pane > 0 {
-			return capturePaneContent(session, 0)
-		}
 		return fmt.Sprintf("(unable to capture: %v)", err)
 	}
 	return string(output)
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+// runPeekScrollback opens the scrollback pager on the n-th pane of a session,
+// counting panes across all windows like capturePaneContent.
+func runPeekScrollback(session string, pane int) {
+	panes, err := tmuxpkg.ListSessionPanes(session)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing panes: %v\n", err)
+		os.Exit(1)
+	}
+	if len(panes) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: session %s has no panes\n", session)
+		os.Exit(1)
+	}
+	if pane < 0 || pane >= len(panes) {
+		fmt.Fprintf(os.Stderr, "Error: session %s has panes 0 to %d, not %d\n", session, len(panes)-1, pane)
+		os.Exit(1)
+	}
+	target := panes[pane].PaneID
+
+	m := newScrollbackModel(fmt.Sprintf("%s pane %d", session, pane), target)
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+type scrollbackTickMsg time.Time
+
+// scrollbackModel pages through a pane's scrollback. Lines are numbered from
+// the oldest line tmux holds, so the view stays put while new output arrives
+// (until the history is full and tmux starts trimming it); at the bottom it
+// follows the output instead.
+type scrollbackModel struct {
+	label  string
+	fetch  func(from, count int) (*tmuxpkg.History, error)
+	top    int  // number of the first line shown
+	follow bool // stick to the newest output
+	hist   *tmuxpkg.History
+	err    error
+	width  int
+	height int
+}
+
+func newScrollbackModel(label, paneID string) scrollbackModel {
+	return scrollbackModel{
+		label:  label,
+		follow: true,
+		fetch: func(from, count int) (*tmuxpkg.History, error) {
+			return tmuxpkg.CaptureHistory(paneID, from, count, true)
+		},
+	}
+}
+
+func (m scrollbackModel) Init() tea.Cmd {
+	return scrollbackTickCmd()
+}
+
+func scrollbackTickCmd() tea.Cmd {
+	return tea.Tick(500*time.Millisecond, func(t time.Time) tea.Msg {
+		return scrollbackTickMsg(t)
+	})
+}
+
+// pageSize is the number of lines that fit between the title and status bar.
+func (m scrollbackModel) pageSize() int {
+	return max(m.height-2, 1)
+}
+
+// total is the number of lines in the pane as of the last fetch.
+func (m scrollbackModel) total() int {
+	if m.hist == nil {
+		return 0
+	}
+	return m.hist.Total
+}
+
+// refresh fetches the page starting at top, or the last page when following.
+func (m scrollbackModel) refresh() scrollbackModel {
+	from := m.top
+	if m.follow {
+		from = -1
+	}
+	hist, err := m.fetch(from, m.pageSize())
+	m.err = err
+	if err != nil {
+		return m
+	}
+	m.hist = hist
+	m.top = hist.From
+	return m
+}
+
+// scroll moves the view by delta lines and refetches. Reaching the bottom
+// switches back to following new output.
+func (m scrollbackModel) scroll(delta int) scrollbackModel {
+	last := max(m.total()-m.pageSize(), 0)
+	m.top = min(max(m.top+delta, 0), last)
+	m.follow = m.top >= last
+	return m.refresh()
+}
+
+func (m scrollbackModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m.refresh(), nil
+
+	case scrollbackTickMsg:
+		return m.refresh(), scrollbackTickCmd()
+
+	case tea.KeyMsg:
+		page := m.pageSize()
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return m, tea.Quit
+		case "j", "down":
+			return m.scroll(1), nil
+		case "k", "up":
+			return m.scroll(-1), nil
+		case "pgdown", " ", "f", "ctrl+f":
+			return m.scroll(page), nil
+		case "pgup", "b", "ctrl+b":
+			return m.scroll(-page), nil
+		case "g", "home":
+			return m.scroll(-m.total()), nil
+		case "G", "end":
+			return m.scroll(m.total()), nil
+		}
+	}
+	return m, nil
+}
+
+func (m scrollbackModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return "Loading..."
+	}
+
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("212")).
+		Render(ansi.Truncate("  Scrollback "+m.label+" — jk: line | space/b: page | g/G: top/bottom | q: quit", m.width, ""))
+
+	lines := make([]string, m.pageSize())
+	if m.hist != nil {
+		for i, line := range m.hist.Lines {
+			if i >= len(lines) {
+				break
+			}
+			lines[i] = ansi.Truncate(line, m.width, "") + "\x1b[0m"
+		}
+	}
+
+	var status string
+	switch {
+	case m.err != nil:
+		status = fmt.Sprintf("error: %v", m.err)
+	case m.hist == nil:
+		status = "no content"
+	default:
+		end := m.hist.From + len(m.hist.Lines)
+		status = fmt.Sprintf("lines %d-%d of %d", m.hist.From+1, end, m.hist.Total)
+		if m.follow {
+			status += " (following)"
+		} else if m.hist.Drifting {
+			status += " (history full: view moves with new output)"
+		}
+	}
+	status = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Render(status)
+
+	return title + "\n" + strings.Join(lines, "\n") + "\n" + status
+}
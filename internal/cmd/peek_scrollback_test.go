@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"strconv"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+// fakeScrollback serves a pane whose lines are their own numbers.
+type fakeScrollback struct {
+	total int
+}
+
+func (f *fakeScrollback) fetch(from, count int) (*tmuxpkg.History, error) {
+	if from < 0 {
+		from = max(f.total-count, 0)
+	}
+	h := &tmuxpkg.History{From: from, Total: f.total}
+	for i := from; i < min(from+count, f.total); i++ {
+		h.Lines = append(h.Lines, strconv.Itoa(i))
+	}
+	return h, nil
+}
+
+func newTestScrollbackModel(f *fakeScrollback) scrollbackModel {
+	m := scrollbackModel{label: "test", follow: true, fetch: f.fetch}
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 12})
+	return next.(scrollbackModel)
+}
+
+func TestScrollbackModel_StartsAtBottom(t *testing.T) {
+	m := newTestScrollbackModel(&fakeScrollback{total: 100})
+	if m.top != 90 || !m.follow {
+		t.Errorf("top = %d, follow = %v, want 90, true", m.top, m.follow)
+	}
+}
+
+func TestScrollbackModel_ScrollUpStopsFollowing(t *testing.T) {
+	f := &fakeScrollback{total: 100}
+	m := newTestScrollbackModel(f)
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'b'}})
+	m = next.(scrollbackModel)
+	if m.top != 80 || m.follow {
+		t.Fatalf("after page up: top = %d, follow = %v, want 80, false", m.top, m.follow)
+	}
+
+	// New output must not move the view.
+	f.total = 150
+	next, _ = m.Update(scrollbackTickMsg{})
+	m = next.(scrollbackModel)
+	if m.top != 80 || m.hist.Lines[0] != "80" {
+		t.Errorf("after new output: top = %d, first line = %q, want 80", m.top, m.hist.Lines[0])
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'G'}})
+	m = next.(scrollbackModel)
+	if m.top != 140 || !m.follow {
+		t.Errorf("after G: top = %d, follow = %v, want 140, true", m.top, m.follow)
+	}
+}
+
+func TestScrollbackModel_ClampsAtTop(t *testing.T) {
+	m := newTestScrollbackModel(&fakeScrollback{total: 100})
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'g'}})
+	m = next.(scrollbackModel)
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	m = next.(scrollbackModel)
+	if m.top != 0 || m.follow {
+		t.Errorf("top = %d, follow = %v, want 0, false", m.top, m.follow)
+	}
+}
+
+func TestScrollbackModel_QuitOnQ(t *testing.T) {
+	m := newTestScrollbackModel(&fakeScrollback{total: 10})
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'q'}})
+	if cmd == nil {
+		t.Fatal("expected quit command, got nil")
+	}
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/matteo-hertel/tmux-super-powers/internal/recording"
+	"github.com/spf13/cobra"
+)
+
+// recordPaneCmd is the writer end of a pane recording. tsp serve starts it
+// through tmux pipe-pane with the pane's output on stdin.
+var recordPaneCmd = &cobra.Command{
+	Use:    "record-pane <file>",
+	Short:  "Append stdin to a rotating pane recording",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		maxBytes, _ := cmd.Flags().GetInt64("max-bytes")
+		maxFiles, _ := cmd.Flags().GetInt("max-files")
+
+		w, err := recording.OpenWriter(args[0], maxBytes, maxFiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening recording: %v\n", err)
+			os.Exit(1)
+		}
+		defer w.Close()
+		io.Copy(w, os.Stdin)
+	},
+}
+
+func init() {
+	recordPaneCmd.Flags().Int64("max-bytes", 10<<20, "Rotate the log at this size")
+	recordPaneCmd.Flags().Int("max-files", 5, "Rotated logs to keep")
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 	"github.com/spf13/cobra"
 )
 
@@ -11,6 +13,9 @@ var rootCmd = &cobra.Command{
 	Use:   "tsp",
 	Short: "tmux super powers - Enhanced tmux functionality",
 	Long:  `tmux-super-powers (tsp) provides enhanced functionality for tmux users including session management, quick directory access, and sandbox project creation.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		applyTmuxSocket(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		versionFlag, _ := cmd.Flags().GetBool("version")
 		if versionFlag {
@@ -25,6 +30,20 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
+// applyTmuxSocket points every tmux invocation at the server selected by
+// --socket, falling back to tmux.socket_path / tmux.socket_name from config.
+func applyTmuxSocket(cmd *cobra.Command) {
+	if socket, _ := cmd.Flags().GetString("socket"); socket != "" {
+		tmuxpkg.SetDefaultServer(tmuxpkg.ParseSocket(socket))
+		return
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	tmuxpkg.SetDefaultServer(tmuxpkg.Server{SocketName: cfg.Tmux.SocketName, SocketPath: cfg.Tmux.SocketPath})
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(txrmCmd)
@@ -46,7 +65,15 @@ func init() {
 	rootCmd.AddCommand(cleanupCmd)
 	rootCmd.AddCommand(deviceCmd)
 	rootCmd.AddCommand(duckCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(timelineCmd)
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(labelCmd)
+	rootCmd.AddCommand(eventsCmd)
+	rootCmd.AddCommand(webhookCmd)
+	rootCmd.AddCommand(recordPaneCmd)
 
 	// Add version flag
 	rootCmd.Flags().BoolP("version", "v", false, "Show version information")
+	rootCmd.PersistentFlags().String("socket", "", "tmux socket name (-L) or path (-S); overrides tmux.socket_name/socket_path")
 }
\ No newline at end of file
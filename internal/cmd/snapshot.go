@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and restore tmux sessions",
+	Long: `Save every tmux session (windows, panes, working directories, layouts,
+running commands and Claude session IDs) to ~/.tsp/snapshots/, and rebuild
+them after a reboot or tmux crash.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save [name]",
+	Short: "Snapshot all sessions on the tmux server",
+	Long: `Save all sessions on the tmux server to ~/.tsp/snapshots/<name>.json.
+The name defaults to the current date and time.
+
+Examples:
+  tsp snapshot save
+  tsp snapshot save before-upgrade`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runSnapshotSave,
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore [name]",
+	Short: "Recreate sessions from a snapshot",
+	Long: `Recreate the sessions in a snapshot (the newest one by default). Sessions
+that already exist are skipped. Claude agents are relaunched with
+` + "`claude --resume <id>`" + ` when their session ID was saved, and with
+` + "`claude --continue`" + ` otherwise.
+
+Examples:
+  tsp snapshot restore
+  tsp snapshot restore before-upgrade --session myapp-feat-auth`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runSnapshotRestore,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved snapshots",
+	Run:   runSnapshotList,
+}
+
+func init() {
+	snapshotRestoreCmd.Flags().StringSlice("session", nil, "Only restore these sessions (repeatable)")
+	snapshotCmd.AddCommand(snapshotSaveCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+}
+
+func runSnapshotSave(cmd *cobra.Command, args []string) {
+	snap, err := service.TakeSnapshot(tmuxpkg.DefaultServer())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading tmux sessions: %v\n", err)
+		os.Exit(1)
+	}
+	if len(snap.Sessions) == 0 {
+		fmt.Println("No tmux sessions to save")
+		return
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+	path, err := service.SaveSnapshot(service.SnapshotDir(), snap, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving snapshot: %v\n", err)
+		os.Exit(1)
+	}
+
+	agents := 0
+	for _, s := range snap.Sessions {
+		for _, w := range s.Windows {
+			for _, p := range w.Panes {
+				if p.AgentSessionID != "" {
+					agents++
+				}
+			}
+		}
+	}
+	fmt.Printf("Saved %d sessions (%d resumable agents) to %s\n", len(snap.Sessions), agents, path)
+}
+
+func runSnapshotRestore(cmd *cobra.Command, args []string) {
+	only, _ := cmd.Flags().GetStringSlice("session")
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	snap, err := service.LoadSnapshot(service.SnapshotDir(), name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading snapshot: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restoring snapshot from %s...\n\n", snap.CreatedAt.Local().Format("2006-01-02 15:04"))
+	restored := 0
+	for _, r := range service.RestoreSnapshot(snap, cfg, only) {
+		switch r.Status {
+		case "restored":
+			restored++
+			if r.Resumed > 0 {
+				fmt.Printf("  ✓ %s (%d agents resumed)\n", r.Session, r.Resumed)
+			} else {
+				fmt.Printf("  ✓ %s\n", r.Session)
+			}
+		case "exists":
+			fmt.Printf("  - %s already exists, skipped\n", r.Session)
+		default:
+			fmt.Printf("  ✗ %s: %s\n", r.Session, r.Error)
+		}
+	}
+	fmt.Printf("\nRestored %d sessions.\n", restored)
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) {
+	dir := service.SnapshotDir()
+	names, err := service.ListSnapshots(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading snapshots: %v\n", err)
+		os.Exit(1)
+	}
+	if len(names) == 0 {
+		fmt.Println("No snapshots")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tSESSIONS")
+	fmt.Fprintln(w, "----\t-------\t--------")
+	for _, name := range names {
+		snap, err := service.LoadSnapshot(dir, name)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t(unreadable)\n", name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\n", name, snap.CreatedAt.Local().Format("2006-01-02 15:04"), len(snap.Sessions))
+	}
+	w.Flush()
+}
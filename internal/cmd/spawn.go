@@ -9,6 +9,7 @@ import (
 
 	"github.com/matteo-hertel/tmux-super-powers/config"
 	"github.com/matteo-hertel/tmux-super-powers/internal/pathutil"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
 	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 	"github.com/spf13/cobra"
 )
@@ -22,13 +23,16 @@ Each task gets:
 1. A branch auto-named from the task description (spawn/fix-auth-bug)
 2. A git worktree
 3. Dependencies installed
-4. A tmux session with nvim (left) + claude (right)
+4. A tmux session with nvim (left) + claude (right), or the --layout you pick
 5. The task prompt sent to claude automatically
 
 Examples:
   tsp spawn "fix the auth bug" "add dark mode" "refactor db layer"
   tsp spawn --file tasks.txt
   tsp spawn --base main --dash "implement user avatars"
+  tsp spawn --layout fullstack "add the billing page"
+  tsp spawn --budget 5 --budget-pause "migrate the tests"
+  tsp spawn --label sprint:42 --label owner:alice "add exports"
   tsp spawn --dry-run "test task"`,
 	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -38,6 +42,16 @@ Examples:
 		setup, _ := cmd.Flags().GetString("setup")
 		noInstall, _ := cmd.Flags().GetBool("no-install")
 		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		layoutName, _ := cmd.Flags().GetString("layout")
+		budgetUSD, _ := cmd.Flags().GetFloat64("budget")
+		budgetPause, _ := cmd.Flags().GetBool("budget-pause")
+		extraLabels, _ := cmd.Flags().GetStringArray("label")
+		for _, l := range extraLabels {
+			if err := service.ValidateLabel(l); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
 		if !isGitRepo() {
 			fmt.Fprintf(os.Stderr, "Error: not a git repository\n")
@@ -80,10 +94,18 @@ Examples:
 
 		cfg, _ := config.Load()
 		worktreeBase := pathutil.ExpandPath(cfg.Spawn.WorktreeBase)
-		agentCmd := cfg.Spawn.AgentCommand
 		if setup == "" {
 			setup = cfg.Spawn.DefaultSetup
 		}
+		layout, err := service.ResolveLayout(cfg, layoutName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !layout.HasAgent() {
+			fmt.Fprintf(os.Stderr, "Error: layout has no agent pane to send tasks to\n")
+			os.Exit(1)
+		}
 
 		fmt.Printf("Spawning %d agents from branch %s...\n\n", len(tasks), baseBranch)
 
@@ -99,6 +121,9 @@ Examples:
 				fmt.Printf("      branch:    %s\n", branch)
 				fmt.Printf("      worktree:  %s\n", worktreePath)
 				fmt.Printf("      session:   %s\n", sessionName)
+				if budgetUSD > 0 {
+					fmt.Printf("      budget:    $%.2f\n", budgetUSD)
+				}
 				fmt.Printf("      prompt:    %s\n\n", task)
 				continue
 			}
@@ -157,12 +182,29 @@ Examples:
 			if tmuxpkg.SessionExists(sessionName) {
 				tmuxpkg.KillSession(sessionName)
 			}
-			tmuxpkg.CreateTwoPaneSession(sessionName, worktreePath, "nvim", agentCmd)
+			_, agentPane, err := tmuxpkg.CreateLayoutSession(sessionName, worktreePath, layout)
+			if err != nil {
+				fmt.Printf("      ✗ session creation failed: %v\n", err)
+				continue
+			}
 			fmt.Printf("      ✓ session created\n")
 
+			labels := append(service.SpawnLabels(repoRoot, layoutName), extraLabels...)
+			if err := service.NewLabelStore(service.LabelsPath()).Add(sessionName, labels...); err != nil {
+				fmt.Printf("      ⚠ labels not saved: %v\n", err)
+			}
+
+			if budgetUSD > 0 {
+				budget := service.Budget{USD: budgetUSD, Pause: budgetPause}
+				if err := service.NewSettingsStore(service.SettingsPath(), cfg.Dash).SetBudget(sessionName, budget); err != nil {
+					fmt.Printf("      ⚠ budget not saved: %v\n", err)
+				} else {
+					fmt.Printf("      ✓ budget $%.2f\n", budgetUSD)
+				}
+			}
+
 			// Send task prompt to claude pane
-			target := fmt.Sprintf("%s:0.1", sessionName)
-			tmuxpkg.SendKeys(target, task)
+			tmuxpkg.SendKeys(agentPane, task)
 			fmt.Printf("      ✓ prompt sent to agent\n\n")
 		}
 
@@ -192,4 +234,8 @@ func init() {
 	spawnCmd.Flags().String("setup", "", "Command to run in each worktree after install")
 	spawnCmd.Flags().Bool("no-install", false, "Skip dependency installation")
 	spawnCmd.Flags().Bool("dry-run", false, "Show what would be created without doing it")
+	spawnCmd.Flags().StringArray("label", nil, "Label to add to each session, e.g. sprint:42 (repeatable)")
+	spawnCmd.Flags().Float64("budget", 0, "Budget per session in USD; tsp serve reports sessions that exceed it")
+	spawnCmd.Flags().Bool("budget-pause", false, "Interrupt the agent when its session exceeds --budget")
+	spawnCmd.Flags().String("layout", "", "Session layout from config (default: spawn.layout, else nvim + agent)")
 }
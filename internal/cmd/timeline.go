@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var timelineCmd = &cobra.Command{
+	Use:   "timeline <session>",
+	Short: "Show a session's status history",
+	Long: `Show every status change of a session, as recorded by tsp serve in
+~/.tsp/history/, followed by the total time spent in each status.
+
+Examples:
+  tsp timeline myapp-feat-auth
+  tsp timeline myapp-feat-auth --last 20
+  tsp timeline myapp-feat-auth --server work`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTimeline,
+}
+
+func init() {
+	timelineCmd.Flags().Int("last", 0, "Only list the last N transitions (totals still cover everything)")
+	timelineCmd.Flags().String("server", "", "Name of the tmux server the session ran on, from tmux.servers (default: the --socket server)")
+}
+
+func runTimeline(cmd *cobra.Command, args []string) {
+	name := args[0]
+	server, _ := cmd.Flags().GetString("server")
+	transitions, err := service.NewStatusHistory(service.HistoryDir(), config.HistoryConfig{}, nil).Load(server, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+		os.Exit(1)
+	}
+	if len(transitions) == 0 {
+		fmt.Printf("No history for %s (history is recorded while tsp serve runs)\n", name)
+		return
+	}
+	tl := service.BuildTimeline(name, transitions, time.Now())
+
+	entries := tl.Entries
+	if last, _ := cmd.Flags().GetInt("last"); last > 0 && last < len(entries) {
+		entries = entries[len(entries)-last:]
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSTATUS\tFOR")
+	fmt.Fprintln(w, "----\t------\t---")
+	for _, e := range entries {
+		status, dur := statusIcon(e.To)+" "+e.To, formatDuration(time.Duration(e.DurationMs)*time.Millisecond)
+		if e.To == "" {
+			status, dur = "(gone)", "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), status, dur)
+	}
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tTOTAL\tTIMES")
+	fmt.Fprintln(w, "------\t-----\t-----")
+	for _, status := range timelineStatuses(tl) {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", status, formatDuration(time.Duration(tl.TotalsMs[status])*time.Millisecond), tl.Counts[status])
+	}
+	w.Flush()
+}
+
+// timelineStatuses returns the statuses in a timeline, longest total first.
+func timelineStatuses(tl service.Timeline) []string {
+	var out []string
+	for status := range tl.TotalsMs {
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if tl.TotalsMs[out[i]] != tl.TotalsMs[out[j]] {
+			return tl.TotalsMs[out[i]] > tl.TotalsMs[out[j]]
+		}
+		return out[i] < out[j]
+	})
+	return out
+}
+
+// formatDuration renders a duration to the second, e.g. "1h02m" or "45s".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
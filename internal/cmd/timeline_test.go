@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+)
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{45 * time.Second, "45s"},
+		{2*time.Minute + 5*time.Second, "2m05s"},
+		{time.Hour + 2*time.Minute + 40*time.Second, "1h02m"},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("formatDuration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestTimelineStatuses(t *testing.T) {
+	tl := service.Timeline{TotalsMs: map[string]int64{"idle": 10, "active": 50, "done": 10}}
+	got := timelineStatuses(tl)
+	want := []string{"active", "done", "idle"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("timelineStatuses = %v, want %v", got, want)
+		}
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 	"github.com/spf13/cobra"
 )
 
@@ -151,6 +151,6 @@ func (d removalDelegate) Render(w io.Writer, m list.Model, index int, item list.
 }
 
 func killSession(session string) {
-	cmd := exec.Command("tmux", "kill-session", "-t", session)
+	cmd := tmuxpkg.Command("kill-session", "-t", session)
 	cmd.Run()
 }
\ No newline at end of file
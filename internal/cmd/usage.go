@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage [session...]",
+	Short: "Report token usage and estimated cost per session",
+	Long: `Report the tokens used by the Claude Code agents of each tmux session
+(or only the sessions given), with their estimated cost. Usage is read from
+the Claude Code logs in the session's pane directories written since the
+session was created. Prices come from usage.prices in the config, on top of
+built-in defaults.
+
+Examples:
+  tsp usage
+  tsp usage myapp-feat-auth --logs`,
+	Run: runUsage,
+}
+
+func init() {
+	usageCmd.Flags().Bool("logs", false, "Break each session down by Claude Code session log")
+}
+
+func runUsage(cmd *cobra.Command, args []string) {
+	showLogs, _ := cmd.Flags().GetBool("logs")
+	cfg, _ := config.Load()
+
+	allPanes, err := tmuxpkg.ListAllPanes()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing tmux panes: %v\n", err)
+		os.Exit(1)
+	}
+	names, panesBySession := tmuxpkg.GroupPanesBySession(allPanes)
+	if len(args) > 0 {
+		names = args
+	}
+
+	cache := service.NewUsageCache(service.NewPriceTable(cfg.Usage))
+	settings := service.NewSettingsStore(service.SettingsPath(), cfg.Dash)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tINPUT\tOUTPUT\tCACHE W\tCACHE R\tCOST\tBUDGET")
+	fmt.Fprintln(w, "-------\t-----\t------\t-------\t-------\t----\t------")
+	var total service.UsageReport
+	for _, name := range names {
+		panes, ok := panesBySession[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "No tmux session %q\n", name)
+			continue
+		}
+		r := cache.Report(service.PaneUsageLogs(panes), panes[0].SessionCreated)
+		budget := "-"
+		if b, ok := settings.Budget(name); ok {
+			budget = fmt.Sprintf("$%.2f", b.USD)
+			if r.CostUSD >= b.USD {
+				budget += " (exceeded)"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, usageColumns(r.Tokens, r.CostUSD), budget)
+		if showLogs {
+			logs := r.Logs
+			sort.Slice(logs, func(i, j int) bool { return logs[i].CostUSD > logs[j].CostUSD })
+			for _, l := range logs {
+				fmt.Fprintf(w, "  %s %s\t%s\t\n", l.ID, strings.Join(l.Models, ","), usageColumns(l.Tokens, l.CostUSD))
+			}
+		}
+		total.Tokens = total.Tokens.Add(r.Tokens)
+		total.CostUSD += r.CostUSD
+	}
+	fmt.Fprintf(w, "TOTAL\t%s\t\n", usageColumns(total.Tokens, total.CostUSD))
+	w.Flush()
+}
+
+// usageColumns renders token counts and a cost as tab-separated columns.
+func usageColumns(t agentlog.Tokens, cost float64) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t$%.2f",
+		formatCount(t.Input), formatCount(t.Output), formatCount(t.CacheWrite), formatCount(t.CacheRead), cost)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Manage outbound webhooks",
+	Long: `Webhooks are configured under webhooks: in ~/.tsp/config.yaml. tsp serve
+POSTs every matching event to them as JSON, signed with the webhook's secret
+in the X-Tsp-Signature header, and retries with backoff. Deliveries that keep
+failing are appended to ~/.tsp/webhooks/dead-letter.jsonl.`,
+}
+
+var webhookTestCmd = &cobra.Command{
+	Use:   "test <name>",
+	Short: "Send a sample event to a webhook",
+	Long: `Send a sample event to a configured webhook, once and regardless of its
+filters, and report the result.
+
+Examples:
+  tsp webhook test team-chat
+  tsp webhook test team-chat --type agent.waiting`,
+	Args: cobra.ExactArgs(1),
+	Run:  runWebhookTest,
+}
+
+func init() {
+	webhookTestCmd.Flags().String("type", "ci.status.changed", "Event type of the sample")
+	webhookTestCmd.Flags().String("session", "tsp-webhook-test", "Session of the sample")
+	webhookCmd.AddCommand(webhookTestCmd)
+}
+
+func runWebhookTest(cmd *cobra.Command, args []string) {
+	typ, _ := cmd.Flags().GetString("type")
+	session, _ := cmd.Flags().GetString("session")
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	var hook *service.Webhook
+	for _, wc := range cfg.Webhooks {
+		if wc.Name == args[0] {
+			if hook, err = service.NewWebhook(wc, service.WebhookDeadLetterPath(), nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if hook == nil {
+		fmt.Fprintf(os.Stderr, "No webhook named %q in the config\n", args[0])
+		os.Exit(1)
+	}
+	event := sampleEvent(typ, session)
+	if event == nil {
+		fmt.Fprintf(os.Stderr, "No sample for event type %q\n", typ)
+		os.Exit(1)
+	}
+
+	env, err := service.NewWebhookEnvelope(hook.Name(), event, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := hook.Send(context.Background(), env); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ %s: %v\n", hook.Name(), err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ %s accepted %s (delivery %s)\n", hook.Name(), typ, env.ID)
+}
+
+// sampleEvent returns an example event of a type, or nil for an unknown
+// type.
+func sampleEvent(typ, session string) service.Event {
+	now := time.Now()
+	for _, e := range []service.Event{
+		service.SessionCreatedEvent{Name: session, Status: "active", At: now},
+		service.SessionRemovedEvent{Name: session, Status: "done", At: now},
+		service.StatusChangedEvent{Session: session, From: "active", To: "done", At: now},
+		service.AgentStuckEvent{Session: session, PaneID: "%1", IdleDuration: 5 * time.Minute},
+		service.AgentCrashedEvent{Session: session, PaneID: "%1", PrevProcess: "claude"},
+		service.AgentWaitingEvent{Session: session, PaneID: "%1", Prompt: "Do you want to proceed? (y/n)"},
+		service.ResourceThresholdEvent{Session: session, Resource: "cpu", Value: 450, Limit: 400},
+		service.BudgetExceededEvent{Session: session, CostUSD: 5.12, BudgetUSD: 5},
+		service.PRDetectedEvent{Session: session, PRNumber: 42, URL: "https://github.com/example/repo/pull/42"},
+		service.CIStatusChangedEvent{Session: session, PRNumber: 42, From: "pending", To: "fail"},
+		service.ReviewsChangedEvent{Session: session, PRNumber: 42, Count: 2, PrevCount: 0},
+		service.PRMergedEvent{Session: session, PRNumber: 42},
+		service.FixAttemptedEvent{Session: session, FixType: "ci", Attempt: 1, MaxAttempts: 3},
+		service.CleanupCompletedEvent{Session: session, WorktreePath: "/tmp/worktree", Branch: "feat/example"},
+	} {
+		if e.EventType() == typ {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// claudeTasksDir is the directory Claude Code keeps open per session, as
+// ~/.claude/tasks/<session-uuid>/.
+const claudeTasksDir = ".claude/tasks/"
+
+// OpenFiles returns the paths of the files a process has open, from
+// /proc/<pid>/fd when the table was read from /proc and from lsof
+// otherwise.
+func (t *Table) OpenFiles(pid int) ([]string, error) {
+	if t.root == "" {
+		return lsofFiles(pid)
+	}
+	dir := filepath.Join(t.root, strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if target, err := os.Readlink(filepath.Join(dir, e.Name())); err == nil {
+			files = append(files, target)
+		}
+	}
+	return files, nil
+}
+
+// lsofFiles lists a process's open files with lsof, whose -Fn output has
+// one "n<path>" line per file.
+func lsofFiles(pid int) ([]string, error) {
+	out, err := exec.Command("lsof", "-p", strconv.Itoa(pid), "-Fn").Output()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name, ok := strings.CutPrefix(line, "n"); ok {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// ClaudeSessionID returns the Claude Code session a process is running,
+// from the ~/.claude/tasks/<session-uuid>/ directory it keeps open, or ""
+// if it has none open.
+func (t *Table) ClaudeSessionID(pid int) string {
+	files, err := t.OpenFiles(pid)
+	if err != nil {
+		return ""
+	}
+	return claudeSessionID(files)
+}
+
+func claudeSessionID(files []string) string {
+	for _, f := range files {
+		idx := strings.Index(f, claudeTasksDir)
+		if idx < 0 {
+			continue
+		}
+		id, _, _ := strings.Cut(f[idx+len(claudeTasksDir):], "/")
+		if id = strings.TrimSpace(id); len(id) > 8 { // sanity check: UUID-like
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,200 @@
+// Package proc inspects running processes: their tree, names, CPU and
+// memory, and open files. On Linux it reads /proc directly; elsewhere it
+// falls back to ps and lsof.
+package proc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultRoot is where the proc filesystem is mounted.
+const DefaultRoot = "/proc"
+
+// commLen is the longest name the kernel keeps for a process (TASK_COMM_LEN
+// less the terminating NUL); longer names are truncated.
+const commLen = 15
+
+// Process is one process in a Table.
+type Process struct {
+	PID   int
+	PPID  int
+	Comm  string // executable name, without its directory
+	Ticks uint64 // user + system CPU time in clock ticks (/proc only)
+	RSS   int64  // resident set size in pages (/proc only)
+}
+
+// Table is a point-in-time snapshot of every process on the machine.
+type Table struct {
+	root     string // proc filesystem the table was read from; "" for ps
+	procs    map[int]Process
+	children map[int][]int
+}
+
+func newTable(root string) *Table {
+	return &Table{root: root, procs: make(map[int]Process), children: make(map[int][]int)}
+}
+
+func (t *Table) add(p Process) {
+	t.procs[p.PID] = p
+	t.children[p.PPID] = append(t.children[p.PPID], p.PID)
+}
+
+// Load snapshots every process, from /proc when it is available and with a
+// single ps call otherwise.
+func Load() (*Table, error) {
+	if t, err := Read(DefaultRoot); err == nil {
+		return t, nil
+	}
+	out, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,comm=").Output()
+	if err != nil {
+		return nil, err
+	}
+	return ParsePS(string(out)), nil
+}
+
+// Read snapshots every process from the proc filesystem at root.
+func Read(root string) (*Table, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	t := newTable(root)
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, e.Name(), "stat"))
+		if err != nil {
+			continue // exited since ReadDir
+		}
+		p, ok := ParseStat(string(data))
+		if !ok {
+			continue
+		}
+		if len(p.Comm) == commLen {
+			p.Comm = fullName(root, p.PID, p.Comm)
+		}
+		t.add(p)
+	}
+	if len(t.procs) == 0 {
+		return nil, fmt.Errorf("no processes in %s", root)
+	}
+	return t, nil
+}
+
+// fullName recovers a name the kernel truncated from the process's command
+// line, when its first argument starts with the truncated name.
+func fullName(root string, pid int, comm string) string {
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return comm
+	}
+	arg0, _, _ := bytes.Cut(data, []byte{0})
+	if name := filepath.Base(string(arg0)); strings.HasPrefix(name, comm) {
+		return name
+	}
+	return comm
+}
+
+// ParseStat parses /proc/<pid>/stat. The command name is in parentheses
+// and may itself contain spaces and parentheses, so fields are counted from
+// the last ')'.
+func ParseStat(data string) (Process, bool) {
+	open := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return Process{}, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(data[:open]))
+	if err != nil {
+		return Process{}, false
+	}
+	// fields[0] is field 3 (state) in proc(5).
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 22 {
+		return Process{}, false
+	}
+	ppid, err1 := strconv.Atoi(fields[1])
+	utime, err2 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err3 := strconv.ParseUint(fields[12], 10, 64)
+	rss, err4 := strconv.ParseInt(fields[21], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return Process{}, false
+	}
+	return Process{PID: pid, PPID: ppid, Comm: data[open+1 : end], Ticks: utime + stime, RSS: rss}, true
+}
+
+// ParsePS parses the output of `ps -A -o pid=,ppid=,comm=`. comm may
+// contain spaces (macOS reports the full executable path), so it is
+// everything after ppid.
+func ParsePS(out string) *Table {
+	t := newTable("")
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		t.add(Process{PID: pid, PPID: ppid, Comm: filepath.Base(strings.Join(fields[2:], " "))})
+	}
+	return t
+}
+
+// Len returns the number of processes in the table.
+func (t *Table) Len() int { return len(t.procs) }
+
+// PIDs returns the PID of every process in the table, in no particular
+// order.
+func (t *Table) PIDs() []int {
+	pids := make([]int, 0, len(t.procs))
+	for pid := range t.procs {
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// Get returns a process by PID.
+func (t *Table) Get(pid int) (Process, bool) {
+	p, ok := t.procs[pid]
+	return p, ok
+}
+
+// Children returns the direct children of a process.
+func (t *Table) Children(pid int) []int {
+	return t.children[pid]
+}
+
+// Descendants returns every descendant of a process, nearest first.
+func (t *Table) Descendants(pid int) []int {
+	var out []int
+	queue := slices.Clone(t.children[pid])
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		out = append(out, p)
+		queue = append(queue, t.children[p]...)
+	}
+	return out
+}
+
+// Find returns the nearest descendant of pid (at any depth) that match
+// accepts.
+func (t *Table) Find(pid int, match func(Process) bool) (Process, bool) {
+	for _, d := range t.Descendants(pid) {
+		if p := t.procs[d]; match(p) {
+			return p, true
+		}
+	}
+	return Process{}, false
+}
@@ -0,0 +1,124 @@
+package proc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// statLine builds a /proc/<pid>/stat line with the fields Read uses.
+func statLine(pid, ppid int, comm string, utime, stime, rss int) string {
+	return fmt.Sprintf("%d (%s) S %d 1 1 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 1 0 12345 1000000 %d 18446744073709551615",
+		pid, comm, ppid, utime, stime, rss)
+}
+
+// writeProc creates a fake proc filesystem entry for a process.
+func writeProc(t *testing.T, root string, pid int, stat, cmdline string) string {
+	t.Helper()
+	dir := filepath.Join(root, fmt.Sprint(pid))
+	if err := os.MkdirAll(filepath.Join(dir, "fd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644)
+	os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0644)
+	return dir
+}
+
+func TestParseStat(t *testing.T) {
+	p, ok := ParseStat(statLine(42, 7, "go test (x) y", 150, 50, 300))
+	if !ok {
+		t.Fatal("ParseStat failed")
+	}
+	want := Process{PID: 42, PPID: 7, Comm: "go test (x) y", Ticks: 200, RSS: 300}
+	if p != want {
+		t.Errorf("ParseStat = %+v, want %+v", p, want)
+	}
+	if _, ok := ParseStat("42 (truncated"); ok {
+		t.Error("expected a malformed line to fail")
+	}
+}
+
+const samplePS = `    1     0 launchd
+  100     1 zsh
+  101   100 claude
+  200     1 -bash
+  201   200 node
+  300     1 2.1.71
+  400     1 /Applications/My App.app/Contents/MacOS/aider
+`
+
+func TestParsePS(t *testing.T) {
+	procs := ParsePS(samplePS)
+	if procs.Len() != 7 {
+		t.Fatalf("expected 7 processes, got %d", procs.Len())
+	}
+	if p, _ := procs.Get(400); p.Comm != "aider" {
+		t.Errorf("expected path comm to be reduced to base name, got %q", p.Comm)
+	}
+	if got := len(procs.Children(1)); got != 4 {
+		t.Errorf("expected 4 children of pid 1, got %d", got)
+	}
+}
+
+func TestRead(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "self"), 0755) // non-PID entries are skipped
+	writeProc(t, root, 1, statLine(1, 0, "init", 0, 0, 10), "/sbin/init\x00")
+	writeProc(t, root, 100, statLine(100, 1, "zsh", 0, 0, 10), "-zsh\x00")
+	writeProc(t, root, 101, statLine(101, 100, "npm exec claude", 0, 0, 10), "npm\x00exec\x00claude\x00")
+	writeProc(t, root, 102, statLine(102, 101, "typescript-lang", 0, 0, 10), "/usr/bin/typescript-language-server\x00--stdio\x00")
+	writeProc(t, root, 103, statLine(103, 102, "claude", 0, 0, 10), "claude\x00")
+
+	procs, err := Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if procs.Len() != 5 {
+		t.Fatalf("expected 5 processes, got %d", procs.Len())
+	}
+	if p, _ := procs.Get(102); p.Comm != "typescript-language-server" {
+		t.Errorf("truncated comm = %q, want it completed from cmdline", p.Comm)
+	}
+	if p, _ := procs.Get(101); p.Comm != "npm exec claude" {
+		t.Errorf("comm = %q, want it kept when cmdline doesn't extend it", p.Comm)
+	}
+	if got, want := procs.Descendants(100), []int{101, 102, 103}; !slices.Equal(got, want) {
+		t.Errorf("Descendants(100) = %v, want %v", got, want)
+	}
+	p, ok := procs.Find(100, func(p Process) bool { return p.Comm == "claude" })
+	if !ok || p.PID != 103 {
+		t.Errorf("Find = %+v, %v, want the claude grandchild", p, ok)
+	}
+	if _, ok := procs.Find(103, func(Process) bool { return true }); ok {
+		t.Error("Find should not match the process itself")
+	}
+
+	if _, err := Read(t.TempDir()); err == nil {
+		t.Error("expected an empty proc filesystem to fail")
+	}
+}
+
+func TestClaudeSessionID(t *testing.T) {
+	root := t.TempDir()
+	dir := writeProc(t, root, 42, statLine(42, 1, "claude", 0, 0, 10), "claude\x00")
+	os.Symlink("/dev/null", filepath.Join(dir, "fd", "0"))
+	os.Symlink("/home/me/.claude/tasks/6a1f0c3e-1111-4222-8333-944455556666", filepath.Join(dir, "fd", "7"))
+
+	procs, err := Read(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := procs.ClaudeSessionID(42), "6a1f0c3e-1111-4222-8333-944455556666"; got != want {
+		t.Errorf("ClaudeSessionID = %q, want %q", got, want)
+	}
+	if got := procs.ClaudeSessionID(43); got != "" {
+		t.Errorf("ClaudeSessionID of a missing process = %q, want empty", got)
+	}
+
+	files := []string{"/tmp/x", "/home/me/.claude/tasks/abc", "/home/me/.claude/tasks/6a1f0c3e-aaaa/lock"}
+	if got := claudeSessionID(files); got != "6a1f0c3e-aaaa" {
+		t.Errorf("claudeSessionID = %q, want the UUID-like directory", got)
+	}
+}
@@ -0,0 +1,278 @@
+package recording
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Segment is one file of a recording.
+type Segment struct {
+	Path  string
+	Start int64 // stream offset of the segment's first byte
+	Size  int64
+}
+
+// Writer appends to a recording, rotating its current file at maxBytes and
+// keeping at most maxFiles rotated segments.
+//
+// A recording is addressed by the path of its current file (e.g.
+// ~/.tsp/recordings/myapp/%12-1700000000.log). On rotation that file is renamed to
+// <path>.<offset>, where offset is the position of its first byte in the
+// pane's whole output stream, so offsets stay stable across rotation and the
+// oldest segments can be dropped without renumbering the rest.
+type Writer struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	f        *os.File
+	start    int64
+	size     int64
+}
+
+// OpenWriter opens the recording at path for appending, continuing an
+// existing recording if there is one. maxBytes <= 0 disables rotation.
+func OpenWriter(path string, maxBytes int64, maxFiles int) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	w := &Writer{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	rotated, err := rotatedSegments(path)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(rotated); n > 0 {
+		w.start = rotated[n-1].Start + rotated[n-1].Size
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f = f
+	w.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating as often as needed so no file exceeds maxBytes.
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.maxBytes > 0 && w.size >= w.maxBytes {
+			if err := w.rotate(); err != nil {
+				return written, err
+			}
+		}
+		chunk := p
+		if w.maxBytes > 0 && int64(len(chunk)) > w.maxBytes-w.size {
+			chunk = chunk[:w.maxBytes-w.size]
+		}
+		n, err := w.f.Write(chunk)
+		written += n
+		w.size += int64(n)
+		p = p[n:]
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// rotate renames the current file to <path>.<start>, starts a new one and
+// deletes the oldest segments beyond maxFiles.
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(w.path, fmt.Sprintf("%s.%d", w.path, w.start)); err != nil {
+		return err
+	}
+	w.start += w.size
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	rotated, err := rotatedSegments(w.path)
+	if err != nil {
+		return nil
+	}
+	for len(rotated) > w.maxFiles {
+		os.Remove(rotated[0].Path)
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
+
+// rotatedSegments returns the rotated segments of the recording at path,
+// oldest first.
+func rotatedSegments(path string) ([]Segment, error) {
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	prefix := filepath.Base(path) + "."
+	var segs []Segment
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), prefix) {
+			continue
+		}
+		start, err := strconv.ParseInt(strings.TrimPrefix(e.Name(), prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		segs = append(segs, Segment{Path: filepath.Join(filepath.Dir(path), e.Name()), Start: start, Size: info.Size()})
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].Start < segs[j].Start })
+	return segs, nil
+}
+
+// Segments returns every segment of the recording at path in stream order,
+// ending with the current file. Returns os.ErrNotExist if there is no
+// recording.
+func Segments(path string) ([]Segment, error) {
+	segs, err := rotatedSegments(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := Segment{Path: path}
+	if n := len(segs); n > 0 {
+		cur.Start = segs[n-1].Start + segs[n-1].Size
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if len(segs) == 0 {
+			return nil, err
+		}
+	} else {
+		cur.Size = info.Size()
+	}
+	return append(segs, cur), nil
+}
+
+// Bounds returns the stream offsets of the first retained byte and the end of
+// the recording.
+func Bounds(segs []Segment) (start, end int64) {
+	if len(segs) == 0 {
+		return 0, 0
+	}
+	last := segs[len(segs)-1]
+	return segs[0].Start, last.Start + last.Size
+}
+
+// ReadRange reads up to length bytes of the recording at path starting at the
+// stream offset. An offset before the oldest retained byte is moved up to it.
+// Returns the data and the stream offset it starts at.
+func ReadRange(path string, offset, length int64) ([]byte, int64, error) {
+	segs, err := Segments(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	start, end := Bounds(segs)
+	if offset < start {
+		offset = start
+	}
+	if offset >= end || length <= 0 {
+		return nil, offset, nil
+	}
+	if offset+length > end {
+		length = end - offset
+	}
+
+	buf := make([]byte, 0, length)
+	pos := offset
+	for _, seg := range segs {
+		if int64(len(buf)) >= length {
+			break
+		}
+		if pos >= seg.Start+seg.Size {
+			continue
+		}
+		f, err := os.Open(seg.Path)
+		if err != nil {
+			return nil, offset, err
+		}
+		want := length - int64(len(buf))
+		if rest := seg.Start + seg.Size - pos; want > rest {
+			want = rest
+		}
+		chunk := make([]byte, want)
+		n, err := f.ReadAt(chunk, pos-seg.Start)
+		f.Close()
+		if err != nil && err != io.EOF {
+			return nil, offset, err
+		}
+		buf = append(buf, chunk[:n]...)
+		pos += int64(n)
+		if int64(n) < want {
+			break
+		}
+	}
+	return buf, offset, nil
+}
+
+// Prune deletes recording files under root that have not been written to
+// since maxAge ago, and removes directories left empty. The current files in
+// live are kept however old: their panes are still piped, so record-pane
+// may append to them at any time.
+func Prune(root string, maxAge time.Duration, live map[string]bool) error {
+	cutoff := time.Now().Add(-maxAge)
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, d.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		kept := 0
+		for _, f := range files {
+			path := filepath.Join(dir, f.Name())
+			info, err := f.Info()
+			if err == nil && info.ModTime().Before(cutoff) && !live[path] {
+				os.Remove(path)
+				continue
+			}
+			kept++
+		}
+		if kept == 0 {
+			os.Remove(dir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app", "%1.log")
+	w, err := OpenWriter(path, 10, 2)
+	if err != nil {
+		t.Fatalf("OpenWriter: %v", err)
+	}
+	// 35 bytes at 10 per file: three rotated segments and a 5 byte current
+	// file, with the oldest segment dropped to keep two.
+	w.Write([]byte("0123456789abcdefghij"))
+	w.Write([]byte("ABCDEFGHIJklmno"))
+	w.Close()
+
+	segs, err := Segments(path)
+	if err != nil {
+		t.Fatalf("Segments: %v", err)
+	}
+	if len(segs) != 3 {
+		t.Fatalf("expected 3 segments, got %+v", segs)
+	}
+	if segs[0].Start != 10 || segs[1].Start != 20 || segs[2].Start != 30 || segs[2].Path != path {
+		t.Errorf("unexpected segments: %+v", segs)
+	}
+	if start, end := Bounds(segs); start != 10 || end != 35 {
+		t.Errorf("Bounds = %d, %d, want 10, 35", start, end)
+	}
+
+	// Reopening continues at the same stream offset.
+	w, _ = OpenWriter(path, 10, 2)
+	w.Write([]byte("p"))
+	w.Close()
+	segs, _ = Segments(path)
+	if _, end := Bounds(segs); end != 36 {
+		t.Errorf("end after reopen = %d, want 36", end)
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "%1.log")
+	w, _ := OpenWriter(path, 4, 10)
+	w.Write([]byte("abcdefghij"))
+	w.Close()
+
+	tests := []struct {
+		offset, length int64
+		want           string
+		wantFrom       int64
+	}{
+		{0, 10, "abcdefghij", 0},
+		{2, 5, "cdefg", 2},
+		{8, 100, "ij", 8},
+		{-5, 3, "abc", 0},
+		{10, 5, "", 10},
+	}
+	for _, tt := range tests {
+		data, from, err := ReadRange(path, tt.offset, tt.length)
+		if err != nil {
+			t.Fatalf("ReadRange(%d, %d): %v", tt.offset, tt.length, err)
+		}
+		if string(data) != tt.want || from != tt.wantFrom {
+			t.Errorf("ReadRange(%d, %d) = %q at %d, want %q at %d", tt.offset, tt.length, data, from, tt.want, tt.wantFrom)
+		}
+	}
+
+	if _, _, err := ReadRange(filepath.Join(t.TempDir(), "missing.log"), 0, 10); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error for missing recording, got %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	root := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for _, f := range []string{"stale/%1.log", "stale/%1.log.0", "live/%2.log", "live/%2.log.0", "idle/%3.log", "idle/%3.log.0"} {
+		path := filepath.Join(root, f)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		os.WriteFile(path, []byte("x"), 0600)
+		if !strings.HasPrefix(f, "live/") || strings.HasSuffix(f, ".0") {
+			os.Chtimes(path, old, old)
+		}
+	}
+
+	// %3 is still piped but has been quiet for longer than maxAge.
+	idle := filepath.Join(root, "idle", "%3.log")
+	if err := Prune(root, 24*time.Hour, map[string]bool{idle: true}); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "stale")); !os.IsNotExist(err) {
+		t.Error("expected stale session directory to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(root, "live", "%2.log")); err != nil {
+		t.Error("expected recent log to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(root, "live", "%2.log.0")); !os.IsNotExist(err) {
+		t.Error("expected old rotated segment to be removed")
+	}
+	if _, err := os.Stat(idle); err != nil {
+		t.Error("expected the log of a piped pane to be kept")
+	}
+	if _, err := os.Stat(idle + ".0"); !os.IsNotExist(err) {
+		t.Error("expected the old rotated segment of a piped pane to be removed")
+	}
+}
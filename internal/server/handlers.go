@@ -1,11 +1,14 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -13,20 +16,23 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
 	"github.com/matteo-hertel/tmux-super-powers/internal/device"
+	"github.com/matteo-hertel/tmux-super-powers/internal/recording"
 	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 )
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	tmuxOK := service.TmuxRunning()
+	tmuxOK := service.TmuxRunning(s.monitor.Servers()...)
 	ghOK := service.GhAvailable()
 	status := http.StatusOK
 	if !tmuxOK {
 		status = http.StatusServiceUnavailable
 	}
 	writeJSON(w, status, map[string]interface{}{
-		"tmux": tmuxOK,
-		"gh":   ghOK,
-		"time": time.Now().Format(time.RFC3339),
+		"tmux":   tmuxOK,
+		"gh":     ghOK,
+		"time":   time.Now().Format(time.RFC3339),
+		"events": s.bus.Stats(),
 	})
 }
 
@@ -35,21 +41,41 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
-	if !service.TmuxRunning() {
+	if !service.TmuxRunning(s.monitor.Servers()...) {
 		writeError(w, http.StatusServiceUnavailable, "tmux is not running")
 		return
 	}
-	sessions := s.monitor.Snapshot()
+	sessions := s.labelled(s.monitor.Snapshot(), r.URL.Query()["label"])
 	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": sessions})
 }
 
+// labelled refreshes the sessions' labels from the store, so changes show
+// before the monitor's next poll, and keeps those matching every selector.
+func (s *Server) labelled(sessions []service.Session, selectors []string) []service.Session {
+	if s.labels == nil {
+		return sessions
+	}
+	all := s.labels.All()
+	out := make([]service.Session, 0, len(sessions))
+	for _, session := range sessions {
+		session.Labels = all[session.Name]
+		if service.MatchLabels(session.Labels, selectors...) {
+			out = append(out, session)
+		}
+	}
+	return out
+}
+
 func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
 	}
+	if s.labels != nil {
+		session.Labels = s.labels.Labels(session.Name)
+	}
 	// Enrich with PR data on demand
 	if session.IsGitRepo && session.Branch != "" {
 		service.EnrichWithPRData(session)
@@ -70,6 +96,7 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		Dir      string `json:"dir"`
 		LeftCmd  string `json:"leftCmd"`
 		RightCmd string `json:"rightCmd"`
+		Layout   string `json:"layout,omitempty"` // named layout from config; overrides leftCmd/rightCmd
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
@@ -79,7 +106,17 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "name and dir are required")
 		return
 	}
-	if err := service.CreateSession(req.Name, req.Dir, req.LeftCmd, req.RightCmd); err != nil {
+	if req.Layout != "" {
+		layout, err := service.ResolveLayout(s.cfg, req.Layout)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := service.CreateLayoutSession(req.Name, req.Dir, layout); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+	} else if err := service.CreateSession(req.Name, req.Dir, req.LeftCmd, req.RightCmd); err != nil {
 		writeError(w, http.StatusConflict, err.Error())
 		return
 	}
@@ -88,7 +125,7 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
@@ -98,22 +135,25 @@ func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&req) // optional body
 
-	err := service.KillSession(name, req.CleanupWorktree && session.IsWorktree, session.WorktreePath, session.Branch, session.GitPath)
+	err := service.KillSession(session.Server, name, req.CleanupWorktree && session.IsWorktree, session.WorktreePath, session.Branch, session.GitPath)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.forgetSession(session.Server, name)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
 func (s *Server) handleSendToPane(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
 	}
 	var req struct {
+		PaneID      string `json:"paneId,omitempty"` // stable tmux pane ID, e.g. "%12"
+		Window      *int   `json:"window,omitempty"` // window index for pane; defaults to the first window
 		Pane        int    `json:"pane"`
 		Text        string `json:"text"`
 		FreeText    bool   `json:"freeText,omitempty"`
@@ -127,25 +167,475 @@ func (s *Server) handleSendToPane(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "text is required")
 		return
 	}
+	var pane *service.Pane
+	if req.PaneID != "" {
+		pane = session.FindPane(req.PaneID)
+	} else if req.Window != nil {
+		pane = session.FindPaneAt(*req.Window, req.Pane)
+	} else if len(session.Windows) > 0 {
+		pane = session.FindPaneAt(session.Windows[0].Index, req.Pane)
+	}
+	if pane == nil {
+		writeError(w, http.StatusNotFound, "pane not found")
+		return
+	}
 	var err error
 	if req.FreeText {
-		err = service.AnswerPaneFreeText(name, req.Pane, req.OptionCount, req.Text)
+		err = service.AnswerPaneFreeText(session.Server, pane.ID, req.OptionCount, req.Text)
 	} else {
-		err = service.SendToPane(name, req.Pane, req.Text)
+		err = service.SendToPane(session.Server, pane.ID, req.Text)
 	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.monitor.Wake(session.Server.Name, session.Name)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
 }
 
+// sessionRepo returns the path that repo overrides for a session apply to:
+// its git repo, or its directory outside of one.
+func sessionRepo(session *service.Session) string {
+	if session.GitPath != "" {
+		return session.GitPath
+	}
+	return session.Dir
+}
+
+// settingsResponse describes a session's status thresholds: the overrides set
+// for the session and its repo, and the thresholds in effect.
+func (s *Server) settingsResponse(session *service.Session) map[string]interface{} {
+	repo := sessionRepo(session)
+	return map[string]interface{}{
+		"session":       session.Name,
+		"repo":          repo,
+		"overrides":     s.settings.Session(session.Name),
+		"repoOverrides": s.settings.Repo(repo),
+		"effective":     s.settings.Thresholds(session.Name, repo).Seconds(),
+	}
+}
+
+func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	session := s.findSession(r, ParseSessionName(r))
+	if session == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.settingsResponse(session))
+}
+
+// settingsPatch is the body of PATCH /api/sessions/{name}/settings. Omitted
+// fields are left alone and 0 clears an override. Scope "repo" sets the
+// overrides for the session's repo instead of the session.
+type settingsPatch struct {
+	Scope       string `json:"scope,omitempty"`
+	IdleAfterS  *int   `json:"idleAfterS,omitempty"`
+	DoneAfterS  *int   `json:"doneAfterS,omitempty"`
+	StuckAfterS *int   `json:"stuckAfterS,omitempty"`
+}
+
+// apply returns cur with the patched fields set.
+func (p settingsPatch) apply(cur service.StatusSettings) (service.StatusSettings, error) {
+	for _, f := range []struct {
+		v   *int
+		dst *int
+	}{
+		{p.IdleAfterS, &cur.IdleAfterS},
+		{p.DoneAfterS, &cur.DoneAfterS},
+		{p.StuckAfterS, &cur.StuckAfterS},
+	} {
+		if f.v == nil {
+			continue
+		}
+		if *f.v < 0 {
+			return cur, fmt.Errorf("thresholds must not be negative")
+		}
+		*f.dst = *f.v
+	}
+	return cur, nil
+}
+
+func (s *Server) handlePatchSettings(w http.ResponseWriter, r *http.Request) {
+	session := s.findSession(r, ParseSessionName(r))
+	if session == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	var req settingsPatch
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	var err error
+	switch req.Scope {
+	case "", "session":
+		var next service.StatusSettings
+		if next, err = req.apply(s.settings.Session(session.Name)); err == nil {
+			err = s.settings.SetSession(session.Name, next)
+		}
+	case "repo":
+		repo := sessionRepo(session)
+		if repo == "" {
+			writeError(w, http.StatusBadRequest, "session has no repo or directory")
+			return
+		}
+		var next service.StatusSettings
+		if next, err = req.apply(s.settings.Repo(repo)); err == nil {
+			err = s.settings.SetRepo(repo, next)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "scope must be session or repo")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.monitor.Wake(session.Server.Name, session.Name)
+	writeJSON(w, http.StatusOK, s.settingsResponse(session))
+}
+
+// handleGetTimeline returns a session's status history with the time spent
+// in each status. History outlives the session, so sessions that are gone
+// can still be looked up; ?server= picks the tmux server they ran on,
+// otherwise the one a running session is on, or the default server.
+func (s *Server) handleGetTimeline(w http.ResponseWriter, r *http.Request) {
+	name := ParseSessionName(r)
+	session := s.findSession(r, name)
+	server := r.URL.Query().Get("server")
+	if server == "" && session != nil {
+		server = session.Server.Name
+	}
+	transitions, err := s.history.Load(server, name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(transitions) == 0 && session == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, service.BuildTimeline(name, transitions, time.Now()))
+}
+
+// handleListEvents returns journaled events, oldest first: those after the
+// ?since= sequence number, or the latest ones without it. ?type= (repeated
+// or comma-separated) and ?session= (a name or glob) filter them. lastSeq is the newest
+// entry in the journal, to resume from with since.
+func (s *Server) handleListEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 500
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = min(n, 5000)
+	}
+	filter := eventFilter(r)
+
+	var events []service.JournalEntry
+	var err error
+	if v := q.Get("since"); v != "" {
+		since, perr := strconv.ParseUint(v, 10, 64)
+		if perr != nil {
+			writeError(w, http.StatusBadRequest, "invalid since")
+			return
+		}
+		events, err = s.journal.Entries(since, filter, limit)
+	} else {
+		events, err = s.journal.Tail(limit, filter)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"events":  events,
+		"lastSeq": s.journal.LastSeq(),
+	})
+}
+
+// sseHeartbeat is how often an idle event stream sends a comment, so
+// proxies don't time it out.
+const sseHeartbeat = 15 * time.Second
+
+// handleEventStream streams events as Server-Sent Events as they are
+// journaled: each has the journal sequence number as its id, the event
+// type as its name, and the journal entry as its data. A client that
+// reconnects with Last-Event-ID (or ?since=) first gets the events it
+// missed. ?types= and ?session= filter like GET /api/events.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	// Before subscribing: whatever is written in between shows up as a gap
+	// and is caught up from the journal.
+	last := s.journal.LastSeq()
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("since")
+	}
+	if resume != "" {
+		since, err := strconv.ParseUint(resume, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		last = since
+	}
+	filter := eventFilter(r)
+
+	ch := s.journal.Subscribe()
+	defer s.journal.Unsubscribe(ch)
+
+	// The stream outlives the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	send := func(e service.JournalEntry) error {
+		last = e.Seq
+		if !filter.Match(e.Type, e.Session) {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+		return err
+	}
+	catchUp := func() error {
+		entries, err := s.journal.Entries(last, service.EventFilter{}, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := send(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := catchUp(); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			switch {
+			case e.Seq <= last:
+				continue
+			case e.Seq > last+1:
+				err = catchUp() // entries were missed
+			default:
+				err = send(e)
+			}
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// eventFilter reads the ?type= (or ?types=) and ?session= event filters of
+// a request.
+func eventFilter(r *http.Request) service.EventFilter {
+	var f service.EventFilter
+	for _, v := range append(r.URL.Query()["type"], r.URL.Query()["types"]...) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				f.Types = append(f.Types, t)
+			}
+		}
+	}
+	f.Session = r.URL.Query().Get("session")
+	return f
+}
+
+// handleGetLabels returns a session's labels.
+func (s *Server) handleGetLabels(w http.ResponseWriter, r *http.Request) {
+	session := s.findSession(r, ParseSessionName(r))
+	if session == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, labelsResponse{Session: session.Name, Labels: s.labels.Labels(session.Name)})
+}
+
+// handlePutLabels replaces a session's labels.
+func (s *Server) handlePutLabels(w http.ResponseWriter, r *http.Request) {
+	session := s.findSession(r, ParseSessionName(r))
+	if session == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	var req struct {
+		Labels []string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if err := s.labels.Set(session.Name, req.Labels); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, labelsResponse{Session: session.Name, Labels: s.labels.Labels(session.Name)})
+}
+
+// handlePatchLabels adds and removes labels of a session.
+func (s *Server) handlePatchLabels(w http.ResponseWriter, r *http.Request) {
+	session := s.findSession(r, ParseSessionName(r))
+	if session == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	var req struct {
+		Add    []string `json:"add,omitempty"`
+		Remove []string `json:"remove,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if len(req.Remove) > 0 {
+		if err := s.labels.Remove(session.Name, req.Remove...); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if len(req.Add) > 0 {
+		if err := s.labels.Add(session.Name, req.Add...); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, labelsResponse{Session: session.Name, Labels: s.labels.Labels(session.Name)})
+}
+
+type labelsResponse struct {
+	Session string   `json:"session"`
+	Labels  []string `json:"labels"`
+}
+
+// bulkResult is the outcome of a bulk action on one session.
+type bulkResult struct {
+	Session string `json:"session"`
+	Status  string `json:"status"` // ok or error
+	Error   string `json:"error,omitempty"`
+}
+
+// handleBulkAction runs an action on every session matching a label
+// selector: kill (optionally cleaning up worktrees), send text to the agent
+// pane, interrupt the agents, or add/remove labels.
+func (s *Server) handleBulkAction(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Label           string   `json:"label"`
+		Action          string   `json:"action"`
+		Text            string   `json:"text,omitempty"`
+		Labels          []string `json:"labels,omitempty"` // for label-add and label-remove
+		CleanupWorktree bool     `json:"cleanupWorktree,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if strings.Trim(req.Label, ", ") == "" {
+		writeError(w, http.StatusBadRequest, "label selector is required")
+		return
+	}
+	var run func(session *service.Session) error
+	switch req.Action {
+	case "kill":
+		run = func(session *service.Session) error {
+			if err := service.KillSession(session.Server, session.Name, req.CleanupWorktree && session.IsWorktree, session.WorktreePath, session.Branch, session.GitPath); err != nil {
+				return err
+			}
+			s.forgetSession(session.Server, session.Name)
+			return nil
+		}
+	case "send":
+		if req.Text == "" {
+			writeError(w, http.StatusBadRequest, "text is required")
+			return
+		}
+		run = func(session *service.Session) error {
+			pane := session.AgentPane()
+			if pane == nil {
+				return fmt.Errorf("no agent pane")
+			}
+			return service.SendToPane(session.Server, pane.ID, req.Text)
+		}
+	case "interrupt":
+		run = func(session *service.Session) error {
+			pane := session.AgentPane()
+			if pane == nil {
+				return fmt.Errorf("no agent pane")
+			}
+			return session.Server.SendRawKey(pane.ID, "Escape")
+		}
+	case "label-add", "label-remove":
+		if len(req.Labels) == 0 {
+			writeError(w, http.StatusBadRequest, "labels are required")
+			return
+		}
+		run = func(session *service.Session) error {
+			if req.Action == "label-add" {
+				return s.labels.Add(session.Name, req.Labels...)
+			}
+			return s.labels.Remove(session.Name, req.Labels...)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "action must be kill, send, interrupt, label-add or label-remove")
+		return
+	}
+
+	results := []bulkResult{}
+	for _, session := range s.labelled(s.monitor.Snapshot(), []string{req.Label}) {
+		res := bulkResult{Session: session.Name, Status: "ok"}
+		if err := run(&session); err != nil {
+			res.Status, res.Error = "error", err.Error()
+		} else {
+			s.monitor.Wake(session.Server.Name, session.Name)
+		}
+		results = append(results, res)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
 func (s *Server) handleSpawn(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Tasks     []string `json:"tasks"`
 		Base      string   `json:"base"`
 		Dir       string   `json:"dir"`
 		NoInstall bool     `json:"noInstall"`
+		Layout    string   `json:"layout,omitempty"`
+		BudgetUSD float64  `json:"budgetUsd,omitempty"`
+		Pause     bool     `json:"pauseOnBudget,omitempty"`
+		Labels    []string `json:"labels,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON body")
@@ -155,16 +645,44 @@ func (s *Server) handleSpawn(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "tasks array is required")
 		return
 	}
-	results, err := service.SpawnAgents(req.Tasks, req.Base, req.NoInstall, s.cfg, req.Dir)
+	if req.BudgetUSD < 0 {
+		writeError(w, http.StatusBadRequest, "budgetUsd must not be negative")
+		return
+	}
+	for _, l := range req.Labels {
+		if err := service.ValidateLabel(l); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	results, err := service.SpawnAgents(req.Tasks, req.Base, req.NoInstall, s.cfg, req.Dir, req.Layout)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	// Auto-track spawned sessions for lifecycle automation
+	for _, res := range results {
+		if res.Status == "ok" {
+			labels := append(service.SpawnLabels(res.GitPath, req.Layout), req.Labels...)
+			if err := s.labels.Add(res.Session, labels...); err != nil {
+				log.Printf("spawn: labels for %s: %v", res.Session, err)
+			}
+		}
+	}
+	if req.BudgetUSD > 0 {
+		for _, res := range results {
+			if res.Status == "ok" {
+				if err := s.settings.SetBudget(res.Session, service.Budget{USD: req.BudgetUSD, Pause: req.Pause}); err != nil {
+					log.Printf("spawn: budget for %s: %v", res.Session, err)
+				}
+			}
+		}
+	}
+	// Auto-track spawned sessions for lifecycle automation. Spawn creates
+	// them on the default server.
 	if s.watcher != nil {
-		for _, r := range results {
-			if r.Status == "ok" {
-				s.watcher.Track(r.Session, r.Branch, r.WorktreePath, r.GitPath)
+		for _, res := range results {
+			if res.Status == "ok" {
+				s.watcher.Track(tmuxpkg.DefaultServer().Name, res.Session, res.Branch, res.WorktreePath, res.GitPath)
 			}
 		}
 	}
@@ -174,7 +692,7 @@ func (s *Server) handleSpawn(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleGetPR(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
@@ -197,7 +715,7 @@ func (s *Server) handleGetPR(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleCreatePR(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
@@ -215,12 +733,13 @@ func (s *Server) handleCreatePR(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.monitor.Wake(session.Server.Name, session.Name)
 	writeJSON(w, http.StatusCreated, map[string]string{"url": url})
 }
 
 func (s *Server) handleFixCI(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
@@ -243,24 +762,23 @@ func (s *Server) handleFixCI(w http.ResponseWriter, r *http.Request) {
 	if len(prompt) > 4000 {
 		prompt = prompt[:4000] + "\n\n[truncated]"
 	}
-	// Send to agent pane (pane 1 by default for worktree sessions)
-	agentPane := 1
-	for _, p := range session.Panes {
-		if p.Type == "agent" {
-			agentPane = p.Index
-			break
-		}
+	// Send to the agent pane (pane 1 of the first window if none is detected)
+	agentPane := session.AgentPane()
+	if agentPane == nil {
+		writeError(w, http.StatusBadRequest, "no agent pane found")
+		return
 	}
-	if err := service.SendToPane(name, agentPane, prompt); err != nil {
+	if err := service.SendToPane(session.Server, agentPane.ID, prompt); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.monitor.Wake(session.Server.Name, session.Name)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "fix-ci prompt sent"})
 }
 
 func (s *Server) handleFixReviews(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
@@ -286,23 +804,22 @@ func (s *Server) handleFixReviews(w http.ResponseWriter, r *http.Request) {
 	formatted := service.FormatPRComments(comments)
 	prompt := "Please address these PR review comments:\n\n" + formatted
 
-	agentPane := 1
-	for _, p := range session.Panes {
-		if p.Type == "agent" {
-			agentPane = p.Index
-			break
-		}
+	agentPane := session.AgentPane()
+	if agentPane == nil {
+		writeError(w, http.StatusBadRequest, "no agent pane found")
+		return
 	}
-	if err := service.SendToPane(name, agentPane, prompt); err != nil {
+	if err := service.SendToPane(session.Server, agentPane.ID, prompt); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.monitor.Wake(session.Server.Name, session.Name)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "review comments sent"})
 }
 
 func (s *Server) handleMerge(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
@@ -320,6 +837,7 @@ func (s *Server) handleMerge(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.monitor.Wake(session.Server.Name, session.Name)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "merged"})
 }
 
@@ -429,6 +947,12 @@ func (s *Server) handleRegisterPushToken(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "registered"})
 }
 
+// testPushTimeout bounds a test notification, so slow sinks are reported
+// as failed before the server's write timeout cuts the response off.
+const testPushTimeout = 8 * time.Second
+
+// handleTestPush sends a test notification through the configured sinks
+// that take its category and reports how each one did.
 func (s *Server) handleTestPush(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Title    string `json:"title"`
@@ -450,40 +974,45 @@ func (s *Server) handleTestPush(w http.ResponseWriter, r *http.Request) {
 		req.Category = "done"
 	}
 
-	tokens := s.deviceStore.PushTokens()
-	if len(tokens) == 0 {
-		writeError(w, http.StatusBadRequest, "no push tokens registered — open the app first")
+	// Finish inside the server's write timeout.
+	ctx, cancel := context.WithTimeout(r.Context(), testPushTimeout)
+	defer cancel()
+	results, err := s.notifier.Test(ctx, service.Notification{
+		Category: req.Category,
+		Title:    req.Title,
+		Body:     req.Body,
+		Priority: "high",
+		Data:     map[string]string{"type": "test"},
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	push := service.NewPushClient()
-	var messages []service.PushMessage
-	for _, token := range tokens {
-		messages = append(messages, service.PushMessage{
-			To:         token,
-			Title:      req.Title,
-			Body:       req.Body,
-			Sound:      "default",
-			Priority:   "high",
-			CategoryID: req.Category,
-			Data: map[string]string{
-				"type": "test",
-			},
-		})
+	var failed []string
+	for _, res := range results {
+		if res.Error != "" {
+			failed = append(failed, res.Sink+": "+res.Error)
+		}
 	}
-
-	if err := push.Send(messages); err != nil {
-		writeError(w, http.StatusInternalServerError, fmt.Sprintf("push failed: %v", err))
+	if len(failed) == len(results) {
+		writeError(w, http.StatusInternalServerError, "notification failed: "+strings.Join(failed, "; "))
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"status":     "sent",
-		"recipients": len(tokens),
+		"status": "sent",
+		"sinks":  results,
 	})
 }
 
+// handleWebSocket streams the full session list on every monitor refresh
+// (protocol v1), or serves protocol v2 when asked for with ?v=2 or the
+// tsp.v2 subprotocol (see ws.go).
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("v") == "2" || slices.Contains(websocket.Subprotocols(r), wsSubprotocol) {
+		s.handleWebSocketV2(w, r)
+		return
+	}
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
@@ -593,7 +1122,7 @@ func (s *Server) handleCreateProject(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleGetAgentLog(w http.ResponseWriter, r *http.Request) {
 	name := ParseSessionName(r)
-	session := s.monitor.FindSession(name)
+	session := s.findSession(r, name)
 	if session == nil {
 		writeError(w, http.StatusNotFound, "session not found")
 		return
@@ -606,22 +1135,29 @@ func (s *Server) handleGetAgentLog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Resolve the agent session ID from the pane.
-	// ?pane=N selects a specific agent pane; otherwise use the first agent pane.
-	// The pane's AgentSessionID is pre-resolved by the monitor via lsof.
+	// ?pane=%ID or ?pane=N selects a specific agent pane; otherwise use the
+	// first agent pane. The pane's AgentSessionID is pre-resolved by the
+	// monitor from the agent's open files.
 	agentSessionID := ""
+	requestedID := ""
 	requestedPane := -1
-	if paneStr := r.URL.Query().Get("pane"); paneStr != "" {
+	if paneStr := r.URL.Query().Get("pane"); strings.HasPrefix(paneStr, "%") {
+		requestedID = paneStr
+	} else if paneStr != "" {
 		if v, err := strconv.Atoi(paneStr); err == nil {
 			requestedPane = v
 		}
 	}
 	for _, p := range session.Panes {
 		if p.Type == "agent" {
+			if requestedID != "" && p.ID != requestedID {
+				continue
+			}
 			if requestedPane >= 0 && p.Index != requestedPane {
 				continue
 			}
-			if paneCwd := service.GetAgentPaneCwd(session.Name, p.Index); paneCwd != "" {
-				dir = paneCwd
+			if p.Cwd != "" {
+				dir = p.Cwd
 			}
 			agentSessionID = p.AgentSessionID
 			break
@@ -718,3 +1254,194 @@ func (s *Server) handleGetAgentLog(w http.ResponseWriter, r *http.Request) {
 		Sessions:   allSessions,
 	})
 }
+
+// Recording byte range limits: the default tail size and the largest range
+// served in one response.
+const (
+	defaultRecordingRange = 64 << 10
+	maxRecordingRange     = 1 << 20
+)
+
+// handleGetRecording serves a byte range of a pane's recording. Offsets are
+// positions in the pane's whole output stream and stay valid across log
+// rotation. The range comes from a Range header ("bytes=a-b", "bytes=a-",
+// "bytes=-n") or ?offset=&length=; by default the last 64KB is returned.
+// X-Recording-Start and X-Recording-End give the retained stream bounds.
+func (s *Server) handleGetRecording(w http.ResponseWriter, r *http.Request) {
+	name := ParseSessionName(r)
+	session := s.findSession(r, name)
+	if session == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	pane := findPane(session, r.PathValue("pane"))
+	if pane == nil {
+		writeError(w, http.StatusNotFound, "pane not found")
+		return
+	}
+
+	path := service.RecordingPath(service.RecordingsDir(), session.Server, session.Name, session.Created, pane.ID)
+	segs, err := recording.Segments(path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "no recording for this pane")
+		return
+	}
+	start, end := recording.Bounds(segs)
+
+	offset, length := int64(-1), int64(defaultRecordingRange)
+	if h := r.Header.Get("Range"); h != "" {
+		var ok bool
+		if offset, length, ok = parseByteRange(h, end); !ok {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "invalid Range header")
+			return
+		}
+	} else {
+		q := r.URL.Query()
+		if v := q.Get("length"); v != "" {
+			if length, err = strconv.ParseInt(v, 10, 64); err != nil || length < 0 {
+				writeError(w, http.StatusBadRequest, "invalid length")
+				return
+			}
+		}
+		if v := q.Get("offset"); v != "" {
+			if offset, err = strconv.ParseInt(v, 10, 64); err != nil || offset < 0 {
+				writeError(w, http.StatusBadRequest, "invalid offset")
+				return
+			}
+		}
+	}
+	if length > maxRecordingRange {
+		length = maxRecordingRange
+	}
+	if offset < 0 {
+		offset = end - length
+	}
+
+	data, from, err := recording.ReadRange(path, offset, length)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("X-Recording-Start", strconv.FormatInt(start, 10))
+	w.Header().Set("X-Recording-End", strconv.FormatInt(end, 10))
+	if len(data) == 0 {
+		if r.Header.Get("Range") != "" {
+			// Nothing of the requested range has been recorded.
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", end))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", from, from+int64(len(data))-1, end))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(data)
+}
+
+// Scrollback page limits: the default number of lines and the most served
+// in one response.
+const (
+	defaultScrollbackLines = 200
+	maxScrollbackLines     = 5000
+)
+
+// handleGetScrollback serves a page of a pane's scrollback. Lines are
+// numbered from the oldest line tmux holds, so ?from= stays valid while new
+// output arrives, until the history is full ("drifting" in the response);
+// without it the last ?lines= lines are returned. ?ansi=0 strips colour
+// escapes.
+func (s *Server) handleGetScrollback(w http.ResponseWriter, r *http.Request) {
+	name := ParseSessionName(r)
+	session := s.findSession(r, name)
+	if session == nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	pane := findPane(session, r.PathValue("pane"))
+	if pane == nil {
+		writeError(w, http.StatusNotFound, "pane not found")
+		return
+	}
+
+	q := r.URL.Query()
+	from, lines := -1, defaultScrollbackLines
+	if v := q.Get("from"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid from")
+			return
+		}
+		from = n
+	}
+	if v := q.Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid lines")
+			return
+		}
+		lines = min(n, maxScrollbackLines)
+	}
+	ansi := true
+	if v := q.Get("ansi"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid ansi")
+			return
+		}
+		ansi = b
+	}
+
+	h, err := service.CaptureScrollback(session.Server, pane.ID, from, lines, ansi)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"paneId":       pane.ID,
+		"from":         h.From,
+		"lines":        h.Lines,
+		"total":        h.Total,
+		"historySize":  h.Size,
+		"historyLimit": h.Limit,
+		"height":       h.Height,
+		"drifting":     h.Drifting,
+	})
+}
+
+// parseByteRange parses a single-range Range header against a stream of
+// length end. Returns the offset and length to read.
+func parseByteRange(h string, end int64) (offset, length int64, ok bool) {
+	spec, found := strings.CutPrefix(h, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > end {
+			n = end
+		}
+		return end - n, n, true
+	}
+	offset, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, false
+	}
+	if last == "" {
+		return offset, end - offset, true
+	}
+	stop, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || stop < offset {
+		return 0, 0, false
+	}
+	return offset, stop - offset + 1, true
+}
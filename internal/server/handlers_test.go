@@ -1,10 +1,13 @@
 package server
 
 import (
+	"bufio"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -23,9 +26,13 @@ func newTestServer() *Server {
 	authMw := auth.NewMiddleware(adminToken, deviceStore)
 
 	return &Server{
-		cfg:     &config.Config{},
-		bus:     service.NewBus(),
-		monitor: service.NewMonitor(500, nil, "", nil, service.NewBus()),
+		cfg:      &config.Config{},
+		bus:      service.NewBus(),
+		monitor:  service.NewMonitor(500, nil, "", nil, service.NewBus()),
+		settings: service.NewSettingsStore(filepath.Join(tmpDir, "session-settings.json"), config.DashConfig{}),
+		history:  service.NewStatusHistory(filepath.Join(tmpDir, "history"), config.HistoryConfig{}, service.NewBus()),
+		labels:   service.NewLabelStore(filepath.Join(tmpDir, "labels.json")),
+		journal:  service.NewJournal(filepath.Join(tmpDir, "events"), 0, nil),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool { return true },
 		},
@@ -36,6 +43,16 @@ func newTestServer() *Server {
 	}
 }
 
+func TestNewRefusesBlockingWithWebhooks(t *testing.T) {
+	cfg := &config.Config{
+		Events:   config.EventsConfig{Overflow: "block"},
+		Webhooks: []config.WebhookConfig{{Name: "chat", URL: "https://example.com/hook"}},
+	}
+	if _, err := New(cfg, t.TempDir()); err == nil || !strings.Contains(err.Error(), "events.overflow") {
+		t.Errorf("err = %v, want an events.overflow error", err)
+	}
+}
+
 func TestHealthEndpoint(t *testing.T) {
 	srv := newTestServer()
 	mux := http.NewServeMux()
@@ -85,3 +102,191 @@ func TestSessionsEndpointWithMonitor(t *testing.T) {
 		t.Errorf("expected Content-Type application/json, got %q", ct)
 	}
 }
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header         string
+		offset, length int64
+		ok             bool
+	}{
+		{"bytes=0-99", 0, 100, true},
+		{"bytes=500-", 500, 500, true},
+		{"bytes=-200", 800, 200, true},
+		{"bytes=-5000", 0, 1000, true},
+		{"bytes=10-5", 0, 0, false},
+		{"bytes=0-1,5-6", 0, 0, false},
+		{"items=0-1", 0, 0, false},
+		{"bytes=abc", 0, 0, false},
+	}
+	for _, tt := range tests {
+		offset, length, ok := parseByteRange(tt.header, 1000)
+		if ok != tt.ok || (ok && (offset != tt.offset || length != tt.length)) {
+			t.Errorf("parseByteRange(%q) = %d, %d, %v; want %d, %d, %v", tt.header, offset, length, ok, tt.offset, tt.length, tt.ok)
+		}
+	}
+}
+
+func TestFindPane(t *testing.T) {
+	session := &service.Session{Panes: []service.Pane{
+		{ID: "%3", Window: 1, Index: 0},
+		{ID: "%4", Window: 1, Index: 1},
+		{ID: "%7", Window: 2, Index: 0},
+	}}
+	tests := map[string]string{
+		"%7":  "%7",
+		"1":   "%4",
+		"2.0": "%7",
+		"%99": "",
+		"5":   "",
+		"x.y": "",
+	}
+	for ref, want := range tests {
+		got := ""
+		if p := findPane(session, ref); p != nil {
+			got = p.ID
+		}
+		if got != want {
+			t.Errorf("findPane(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestSettingsPatchApply(t *testing.T) {
+	zero, idle, neg := 0, 45, -1
+	cur := service.StatusSettings{IdleAfterS: 10, StuckAfterS: 600}
+
+	got, err := settingsPatch{IdleAfterS: &idle, StuckAfterS: &zero}.apply(cur)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	want := service.StatusSettings{IdleAfterS: 45}
+	if got != want {
+		t.Errorf("apply = %+v, want %+v", got, want)
+	}
+
+	if _, err := (settingsPatch{DoneAfterS: &neg}).apply(cur); err == nil {
+		t.Error("expected an error for a negative threshold")
+	}
+}
+
+func TestLabelledSessions(t *testing.T) {
+	srv := newTestServer()
+	srv.labels.Add("api-fix", "repo:api", "sprint:42")
+	srv.labels.Add("web-fix", "repo:web", "sprint:42")
+	sessions := []service.Session{{Name: "api-fix"}, {Name: "web-fix"}, {Name: "scratch"}}
+
+	if got := srv.labelled(sessions, nil); len(got) != 3 || len(got[0].Labels) != 2 {
+		t.Errorf("unfiltered = %+v, want all sessions with their labels", got)
+	}
+	got := srv.labelled(sessions, []string{"sprint:42", "repo:web"})
+	if len(got) != 1 || got[0].Name != "web-fix" {
+		t.Errorf("filtered = %+v, want only web-fix", got)
+	}
+	if got := srv.labelled(sessions, []string{"sprint"}); len(got) != 2 {
+		t.Errorf("by key = %d sessions, want 2", len(got))
+	}
+}
+
+func TestListEvents(t *testing.T) {
+	srv := newTestServer()
+	now := time.Now()
+	srv.journal.Append(service.StatusChangedEvent{Session: "a", From: "active", To: "done"}, now)
+	srv.journal.Append(service.CIStatusChangedEvent{Session: "b", To: "fail"}, now)
+	srv.journal.Append(service.StatusChangedEvent{Session: "b", From: "idle", To: "active"}, now)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/events", srv.handleListEvents)
+	get := func(query string) (int, []service.JournalEntry, uint64) {
+		req := httptest.NewRequest("GET", "/api/events"+query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var resp struct {
+			Events  []service.JournalEntry `json:"events"`
+			LastSeq uint64                 `json:"lastSeq"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp.Events, resp.LastSeq
+	}
+
+	if code, events, last := get("?since=1"); code != http.StatusOK || len(events) != 2 || events[0].Seq != 2 || last != 3 {
+		t.Errorf("since=1: code %d, events %+v, lastSeq %d", code, events, last)
+	}
+	if _, events, _ := get("?since=0&type=status.changed&session=b"); len(events) != 1 || events[0].Seq != 3 {
+		t.Errorf("filtered: %+v", events)
+	}
+	if _, events, _ := get("?limit=1"); len(events) != 1 || events[0].Seq != 3 {
+		t.Errorf("latest: %+v", events)
+	}
+	if code, _, _ := get("?since=x"); code != http.StatusBadRequest {
+		t.Errorf("invalid since: code %d, want 400", code)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	srv := newTestServer()
+	srv.journal.Append(service.StatusChangedEvent{Session: "api-auth", To: "done"}, time.Now())
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/events/stream", srv.handleEventStream)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	// open connects and returns a function reading the next event's id,
+	// name and data.
+	open := func(query, lastEventID string) (func() (string, string, string), func()) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+"/api/events/stream"+query, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Content-Type = %q", ct)
+		}
+		lines := bufio.NewScanner(resp.Body)
+		next := func() (id, event, data string) {
+			for lines.Scan() {
+				line := lines.Text()
+				switch {
+				case line == "" && id != "":
+					return
+				case strings.HasPrefix(line, "id: "):
+					id = line[4:]
+				case strings.HasPrefix(line, "event: "):
+					event = line[7:]
+				case strings.HasPrefix(line, "data: "):
+					data = line[6:]
+				}
+			}
+			t.Fatal("stream ended")
+			return
+		}
+		return next, func() { resp.Body.Close() }
+	}
+
+	next, closeStream := open("?types=ci.status.changed,agent.waiting&session=api-*", "")
+	defer closeStream()
+	time.Sleep(50 * time.Millisecond) // let the handler subscribe
+	srv.journal.Append(service.CIStatusChangedEvent{Session: "web-auth", To: "fail"}, time.Now())
+	srv.journal.Append(service.StatusChangedEvent{Session: "api-auth", To: "active"}, time.Now())
+	srv.journal.Append(service.CIStatusChangedEvent{Session: "api-auth", To: "fail"}, time.Now())
+	srv.journal.Append(service.AgentWaitingEvent{Session: "api-auth", Prompt: "Proceed?"}, time.Now())
+
+	if id, event, data := next(); id != "4" || event != "ci.status.changed" || !strings.Contains(data, `"to":"fail"`) {
+		t.Errorf("first event = %s %s %s", id, event, data)
+	}
+	if id, event, _ := next(); id != "5" || event != "agent.waiting" {
+		t.Errorf("second event = %s %s", id, event)
+	}
+
+	// Resuming replays what came after the last event seen.
+	next2, closeStream2 := open("", "3")
+	defer closeStream2()
+	for _, want := range []string{"4", "5"} {
+		if id, _, _ := next2(); id != want {
+			t.Errorf("replayed id = %s, want %s", id, want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/matteo-hertel/tmux-super-powers/internal/device"
 	"github.com/matteo-hertel/tmux-super-powers/internal/pathutil"
 	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 )
 
 //go:embed web/index.html
@@ -30,6 +32,13 @@ type Server struct {
 	monitor        *service.Monitor
 	notifier       *service.Notifier
 	watcher        *service.Watcher
+	recorder       *service.Recorder // nil unless recording.enabled
+	settings       *service.SettingsStore
+	labels         *service.LabelStore
+	history        *service.StatusHistory
+	journal        *service.Journal
+	webhooks       []*service.Webhook
+	hooks          *service.HookRunner
 	upgrader       websocket.Upgrader
 	httpSrv        *http.Server
 	deviceStore    *device.Store
@@ -52,7 +61,17 @@ func New(cfg *config.Config, tspDir string) (*Server, error) {
 	pairing := device.NewPairingManager(5 * time.Minute)
 	authMiddleware := auth.NewMiddleware(adminToken, deviceStore)
 
+	overflow, err := service.ParseOverflowPolicy(cfg.Events.Overflow)
+	if err != nil {
+		return nil, fmt.Errorf("events.overflow: %w", err)
+	}
+	if overflow == service.Block && (len(cfg.Webhooks) > 0 || len(cfg.Hooks.On) > 0) {
+		// The monitor publishes from its poll loop; a webhook retrying a dead
+		// endpoint or a hook waiting for a free slot would stall polling.
+		return nil, fmt.Errorf("events.overflow: block can't be used with webhooks or hooks")
+	}
 	bus := service.NewBus()
+	bus.SetQueue(cfg.Events.QueueSize, overflow)
 	srv := &Server{
 		cfg: cfg,
 		bus: bus,
@@ -71,7 +90,41 @@ func New(cfg *config.Config, tspDir string) (*Server, error) {
 		adminToken:     adminToken,
 		authMiddleware: authMiddleware,
 	}
+	// Monitor the default server (config or --socket) plus any extra
+	// servers listed under tmux.servers.
+	servers := []tmuxpkg.Server{tmuxpkg.DefaultServer()}
+	for _, ts := range cfg.Tmux.Servers {
+		servers = append(servers, tmuxpkg.Server{Name: ts.Name, SocketName: ts.SocketName, SocketPath: ts.SocketPath})
+	}
+	srv.monitor.SetServers(servers)
+	srv.monitor.SetDetectors(service.NewDetectors(cfg.Dash))
+	srv.monitor.SetResources(cfg.Resources)
+	srv.settings = service.NewSettingsStore(service.SettingsPath(), cfg.Dash)
+	srv.monitor.SetSettings(srv.settings)
+	srv.labels = service.NewLabelStore(service.LabelsPath())
+	srv.monitor.SetLabels(srv.labels)
+	srv.monitor.SetUsage(service.NewUsageCache(service.NewPriceTable(cfg.Usage)))
+	if cfg.Recording.Enabled {
+		srv.recorder = service.NewRecorder(cfg.Recording, service.RecordingsDir())
+		srv.monitor.SetRecorder(srv.recorder)
+	}
+	srv.history = service.NewStatusHistory(service.HistoryDir(), cfg.History, bus)
+	srv.journal = service.NewJournal(service.EventsDir(), cfg.Events.RetentionDays, bus)
+	if srv.webhooks, err = service.NewWebhooks(cfg.Webhooks, service.WebhookDeadLetterPath(), bus); err != nil {
+		return nil, err
+	}
+	if srv.hooks, err = service.NewHookRunner(cfg.Hooks, service.HooksLogPath(), bus); err != nil {
+		return nil, err
+	}
+	srv.hooks.SetMonitor(srv.monitor)
 	srv.notifier = service.NewNotifier(srv.monitor, srv.deviceStore, bus)
+	if len(cfg.Notifications.Sinks) > 0 {
+		routes, err := service.NewNotificationRoutes(cfg.Notifications.Sinks, srv.deviceStore)
+		if err != nil {
+			return nil, err
+		}
+		srv.notifier.SetRoutes(routes)
+	}
 	srv.watcher = service.NewWatcher(bus, cfg.Watcher)
 	srv.watcher.SetMonitor(srv.monitor)
 	return srv, nil
@@ -81,8 +134,17 @@ func New(cfg *config.Config, tspDir string) (*Server, error) {
 func (s *Server) Start(bind string, port int) error {
 	s.bindAddr = bind
 	s.port = port
+	s.history.Start()
+	s.journal.Start()
 	s.monitor.Start()
+	if s.recorder != nil {
+		s.recorder.Start()
+	}
 	s.notifier.Start()
+	for _, w := range s.webhooks {
+		w.Start()
+	}
+	s.hooks.Start()
 	s.watcher.Start()
 
 	mux := http.NewServeMux()
@@ -103,8 +165,17 @@ func (s *Server) Start(bind string, port int) error {
 // Stop gracefully shuts down the server.
 func (s *Server) Stop() error {
 	s.watcher.Stop()
+	s.hooks.Stop()
+	for _, w := range s.webhooks {
+		w.Stop()
+	}
 	s.notifier.Stop()
+	if s.recorder != nil {
+		s.recorder.Stop()
+	}
 	s.monitor.Stop()
+	s.journal.Stop()
+	s.history.Stop()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	return s.httpSrv.Shutdown(ctx)
@@ -128,6 +199,17 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/sessions", s.handleCreateSession)
 	mux.HandleFunc("DELETE /api/sessions/{name}", s.handleDeleteSession)
 	mux.HandleFunc("POST /api/sessions/{name}/send", s.handleSendToPane)
+	mux.HandleFunc("GET /api/sessions/{name}/settings", s.handleGetSettings)
+	mux.HandleFunc("PATCH /api/sessions/{name}/settings", s.handlePatchSettings)
+	mux.HandleFunc("GET /api/sessions/{name}/timeline", s.handleGetTimeline)
+	mux.HandleFunc("GET /api/sessions/{name}/labels", s.handleGetLabels)
+	mux.HandleFunc("PUT /api/sessions/{name}/labels", s.handlePutLabels)
+	mux.HandleFunc("PATCH /api/sessions/{name}/labels", s.handlePatchLabels)
+	mux.HandleFunc("POST /api/sessions/bulk", s.handleBulkAction)
+
+	// Event journal
+	mux.HandleFunc("GET /api/events", s.handleListEvents)
+	mux.HandleFunc("GET /api/events/stream", s.handleEventStream)
 
 	// Spawn
 	mux.HandleFunc("POST /api/spawn", s.handleSpawn)
@@ -145,6 +227,10 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	// Agent log
 	mux.HandleFunc("GET /api/sessions/{name}/agent-log", s.handleGetAgentLog)
 
+	// Pane history
+	mux.HandleFunc("GET /api/sessions/{name}/panes/{pane}/recording", s.handleGetRecording)
+	mux.HandleFunc("GET /api/sessions/{name}/panes/{pane}/scrollback", s.handleGetScrollback)
+
 	// Device management
 	mux.HandleFunc("PUT /api/devices/push-token", s.handleRegisterPushToken)
 
@@ -195,7 +281,7 @@ func DetectTailscaleIP() string {
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -246,6 +332,41 @@ func ParseSessionName(r *http.Request) string {
 	return strings.ReplaceAll(name, "%20", " ")
 }
 
+// findSession looks up a session by name. When several tmux servers are
+// monitored, ?server=<name> picks the server; otherwise the first match wins.
+func (s *Server) findSession(r *http.Request, name string) *service.Session {
+	return s.monitor.FindSessionOn(r.URL.Query().Get("server"), name)
+}
+
+// forgetSession deletes what is kept by name for a session killed on srv,
+// unless another monitored server still has a session by that name.
+func (s *Server) forgetSession(srv tmuxpkg.Server, name string) {
+	if err := service.ForgetSession(s.labels, s.settings, srv, name, s.monitor.Servers()); err != nil {
+		log.Printf("kill: forgetting %s: %v", name, err)
+	}
+}
+
+// findPane resolves a pane reference from a URL path: a pane ID ("%12"), a
+// window.pane pair ("1.0"), or a pane index in the session's first window.
+func findPane(session *service.Session, ref string) *service.Pane {
+	if strings.HasPrefix(ref, "%") {
+		return session.FindPane(ref)
+	}
+	if w, p, ok := strings.Cut(ref, "."); ok {
+		window, err1 := strconv.Atoi(w)
+		index, err2 := strconv.Atoi(p)
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		return session.FindPaneAt(window, index)
+	}
+	index, err := strconv.Atoi(ref)
+	if err != nil || len(session.Panes) == 0 {
+		return nil
+	}
+	return session.FindPaneAt(session.Panes[0].Window, index)
+}
+
 // handleDirectories returns resolved directory paths from the config.
 // Uses the same resolution logic as `tsp dir` (git repos, ignore hidden/gitignored).
 func (s *Server) handleDirectories(w http.ResponseWriter, r *http.Request) {
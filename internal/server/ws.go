@@ -0,0 +1,421 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+
+	"github.com/gorilla/websocket"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+)
+
+// WebSocket protocol v2, selected with /api/ws?v=2 or the "tsp.v2"
+// subprotocol. Every message is a JSON object with a "type".
+//
+// Server to client:
+//
+//	snapshot  every subscribed session in full; sent on connect, subscribe and resync
+//	patch     sessions that changed since the last snapshot or patch, with only
+//	          their changed panes, plus the sessions that went away
+//	event     a bus event, as its journal entry
+//	ack       the outcome of a client command, echoing its id
+//	error     a message the server couldn't understand
+//
+// Snapshots and patches carry a seq that increases by one per message, so a
+// client that misses or misapplies one can ask for a resync.
+//
+// Client to server:
+//
+//	subscribe  {"sessions": [names or globs], "panes": [pane IDs], "events": [types]}
+//	           limits the sessions sent (all when empty), the panes whose content
+//	           is sent (all when absent, none when empty) and the event types sent
+//	           (all when absent, none when empty)
+//	resync     asks for a new snapshot
+//	input      {"session", "server", "pane", "text", "keys"}: pastes text (then
+//	           Enter) and/or sends tmux keys such as "Escape" to a pane, the
+//	           agent pane when none is given
+const (
+	wsProtocolVersion = 2
+	wsSubprotocol     = "tsp.v2"
+)
+
+// wsSession is a session as sent in protocol v2. Panes carry a key, and
+// windows list the keys of their panes instead of repeating them. In a
+// patch, Panes holds only the panes that changed and RemovedPanes the keys
+// of those that went away.
+type wsSession struct {
+	service.Session
+	Key          string     `json:"key"` // server and name, unique across servers
+	Panes        []wsPane   `json:"panes,omitempty"`
+	Windows      []wsWindow `json:"windows"`
+	RemovedPanes []string   `json:"removedPanes,omitempty"`
+}
+
+type wsPane struct {
+	service.Pane
+	Key string `json:"key"`
+}
+
+type wsWindow struct {
+	Index  int      `json:"index"`
+	Name   string   `json:"name"`
+	Active bool     `json:"active"`
+	Panes  []string `json:"panes"`
+}
+
+// wsSessionRef identifies a session that went away.
+type wsSessionRef struct {
+	Key    string `json:"key"`
+	Server string `json:"server"`
+	Name   string `json:"name"`
+}
+
+type wsSnapshot struct {
+	Type     string      `json:"type"`
+	Protocol int         `json:"protocol"`
+	Seq      uint64      `json:"seq"`
+	Sessions []wsSession `json:"sessions"`
+}
+
+type wsPatch struct {
+	Type     string         `json:"type"`
+	Seq      uint64         `json:"seq"`
+	Sessions []wsSession    `json:"sessions,omitempty"`
+	Removed  []wsSessionRef `json:"removed,omitempty"`
+}
+
+type wsEvent struct {
+	Type  string               `json:"type"`
+	Event service.JournalEntry `json:"event"`
+}
+
+type wsAck struct {
+	Type  string `json:"type"`
+	ID    string `json:"id,omitempty"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// wsClientMessage is any message from the client; which fields apply
+// depends on Type.
+type wsClientMessage struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"` // echoed in the ack
+
+	// subscribe
+	Sessions []string  `json:"sessions,omitempty"`
+	Panes    *[]string `json:"panes,omitempty"`
+	Events   *[]string `json:"events,omitempty"`
+
+	// input
+	Session string   `json:"session,omitempty"`
+	Server  string   `json:"server,omitempty"`
+	Pane    string   `json:"pane,omitempty"`
+	Text    string   `json:"text,omitempty"`
+	Keys    []string `json:"keys,omitempty"`
+}
+
+// wsSubscription is what a v2 client asked to receive.
+type wsSubscription struct {
+	sessions []string // names or globs; empty for all
+	panes    []string // pane IDs whose content is sent; nil for all
+	events   []string // event types; nil for all
+}
+
+func (sub wsSubscription) session(s service.Session) bool {
+	if len(sub.sessions) == 0 {
+		// Subscribing to panes alone selects the sessions they are in.
+		return sub.panes == nil || slices.ContainsFunc(s.Panes, func(p service.Pane) bool {
+			return slices.Contains(sub.panes, p.ID)
+		})
+	}
+	return sub.sessionName(s.Name)
+}
+
+func (sub wsSubscription) sessionName(name string) bool {
+	return len(sub.sessions) == 0 || slices.ContainsFunc(sub.sessions, func(glob string) bool {
+		ok, _ := path.Match(glob, name)
+		return ok || glob == name
+	})
+}
+
+func (sub wsSubscription) content(p service.Pane) bool {
+	return sub.panes == nil || slices.Contains(sub.panes, p.ID)
+}
+
+func (sub wsSubscription) event(e service.JournalEntry) bool {
+	if sub.events != nil && !slices.Contains(sub.events, e.Type) {
+		return false
+	}
+	return sub.sessionName(e.Session)
+}
+
+// wsSent is what a client was last sent of a session, to diff against.
+type wsSent struct {
+	ref   wsSessionRef
+	meta  []byte            // the session without its panes
+	panes map[string][]byte // by pane key
+}
+
+// wsConn is the state of one v2 connection. Only the connection's own
+// goroutine uses it.
+type wsConn struct {
+	conn *websocket.Conn
+	sub  wsSubscription
+	seq  uint64
+	sent map[string]wsSent // by session key
+}
+
+func wsSessionKey(s service.Session) string {
+	return s.Server.Name + ":" + s.Name
+}
+
+func wsPaneKey(p service.Pane) string {
+	if p.ID != "" {
+		return p.ID
+	}
+	return fmt.Sprintf("%d.%d", p.Window, p.Index)
+}
+
+// convert turns a session into its v2 form, with the content of panes
+// outside the subscription left out.
+func (c *wsConn) convert(s service.Session) wsSession {
+	ws := wsSession{Session: s, Key: wsSessionKey(s)}
+	for _, p := range s.Panes {
+		if !c.sub.content(p) {
+			p.Content = ""
+		}
+		ws.Panes = append(ws.Panes, wsPane{Pane: p, Key: wsPaneKey(p)})
+	}
+	for _, w := range s.Windows {
+		win := wsWindow{Index: w.Index, Name: w.Name, Active: w.Active, Panes: []string{}}
+		for _, p := range s.Panes {
+			if p.Window == w.Index {
+				win.Panes = append(win.Panes, wsPaneKey(p))
+			}
+		}
+		ws.Windows = append(ws.Windows, win)
+	}
+	return ws
+}
+
+// record remembers a session as sent and returns it as it would be sent in
+// a patch: nil if nothing changed, only the changed panes otherwise.
+func (c *wsConn) record(ws wsSession) *wsSession {
+	panes := ws.Panes
+	ws.Panes = nil
+	meta, _ := json.Marshal(ws)
+	cur := wsSent{
+		ref:   wsSessionRef{Key: ws.Key, Server: ws.Server.Name, Name: ws.Name},
+		meta:  meta,
+		panes: make(map[string][]byte, len(panes)),
+	}
+	prev, seen := c.sent[ws.Key]
+	changed := !seen || !bytes.Equal(prev.meta, meta)
+	for _, p := range panes {
+		data, _ := json.Marshal(p)
+		cur.panes[p.Key] = data
+		if !seen || !bytes.Equal(prev.panes[p.Key], data) {
+			ws.Panes = append(ws.Panes, p)
+		}
+	}
+	for key := range prev.panes {
+		if _, ok := cur.panes[key]; !ok {
+			ws.RemovedPanes = append(ws.RemovedPanes, key)
+		}
+	}
+	c.sent[ws.Key] = cur
+	if !changed && len(ws.Panes) == 0 && len(ws.RemovedPanes) == 0 {
+		return nil
+	}
+	slices.Sort(ws.RemovedPanes)
+	return &ws
+}
+
+// snapshot returns every subscribed session in full, and diffs later
+// patches against it.
+func (c *wsConn) snapshot(sessions []service.Session) wsSnapshot {
+	c.seq++
+	c.sent = make(map[string]wsSent)
+	msg := wsSnapshot{Type: "snapshot", Protocol: wsProtocolVersion, Seq: c.seq, Sessions: []wsSession{}}
+	for _, s := range sessions {
+		if c.sub.session(s) {
+			ws := c.convert(s)
+			c.record(ws)
+			msg.Sessions = append(msg.Sessions, ws)
+		}
+	}
+	return msg
+}
+
+// patch returns what changed since the last snapshot or patch, and false
+// if nothing did.
+func (c *wsConn) patch(sessions []service.Session) (wsPatch, bool) {
+	msg := wsPatch{Type: "patch"}
+	current := make(map[string]bool)
+	for _, s := range sessions {
+		if !c.sub.session(s) {
+			continue
+		}
+		ws := c.convert(s)
+		current[ws.Key] = true
+		if changed := c.record(ws); changed != nil {
+			msg.Sessions = append(msg.Sessions, *changed)
+		}
+	}
+	for key, sent := range c.sent {
+		if !current[key] {
+			msg.Removed = append(msg.Removed, sent.ref)
+			delete(c.sent, key)
+		}
+	}
+	if len(msg.Sessions) == 0 && len(msg.Removed) == 0 {
+		return msg, false
+	}
+	slices.SortFunc(msg.Removed, func(a, b wsSessionRef) int { return cmp.Compare(a.Key, b.Key) })
+	c.seq++
+	msg.Seq = c.seq
+	return msg, true
+}
+
+// handleWebSocketV2 serves protocol v2 (see the top of this file).
+func (s *Server) handleWebSocketV2(w http.ResponseWriter, r *http.Request) {
+	var header http.Header
+	if slices.Contains(websocket.Subprotocols(r), wsSubprotocol) {
+		header = http.Header{"Sec-Websocket-Protocol": {wsSubprotocol}}
+	}
+	conn, err := s.upgrader.Upgrade(w, r, header)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ch := s.monitor.Subscribe()
+	defer s.monitor.Unsubscribe(ch)
+	var events chan service.JournalEntry
+	if s.journal != nil {
+		events = s.journal.Subscribe()
+		defer s.journal.Unsubscribe(events)
+	}
+
+	// Read pump: hands client messages to the loop below, which owns all
+	// writes to the connection. quit stops it when the loop returns first.
+	messages := make(chan wsClientMessage)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(done)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg wsClientMessage
+			if json.Unmarshal(data, &msg) != nil {
+				msg = wsClientMessage{Type: "invalid"}
+			}
+			select {
+			case messages <- msg:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	c := &wsConn{conn: conn}
+	if err := conn.WriteJSON(c.snapshot(s.monitor.Snapshot())); err != nil {
+		return
+	}
+	for {
+		var err error
+		select {
+		case sessions, ok := <-ch:
+			if !ok {
+				return
+			}
+			if msg, changed := c.patch(sessions); changed {
+				err = conn.WriteJSON(msg)
+			}
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if c.sub.event(e) {
+				err = conn.WriteJSON(wsEvent{Type: "event", Event: e})
+			}
+		case msg := <-messages:
+			err = s.handleWSMessage(c, msg)
+		case <-done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handleWSMessage acts on a message from a v2 client.
+func (s *Server) handleWSMessage(c *wsConn, msg wsClientMessage) error {
+	switch msg.Type {
+	case "subscribe":
+		c.sub = wsSubscription{sessions: msg.Sessions}
+		if msg.Panes != nil {
+			c.sub.panes = *msg.Panes
+		}
+		if msg.Events != nil {
+			c.sub.events = *msg.Events
+		}
+		return c.conn.WriteJSON(c.snapshot(s.monitor.Snapshot()))
+	case "resync":
+		return c.conn.WriteJSON(c.snapshot(s.monitor.Snapshot()))
+	case "input":
+		ack := wsAck{Type: "ack", ID: msg.ID, OK: true}
+		if err := s.wsInput(msg); err != nil {
+			ack.OK, ack.Error = false, err.Error()
+		}
+		return c.conn.WriteJSON(ack)
+	}
+	return c.conn.WriteJSON(wsAck{Type: "error", ID: msg.ID, Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+}
+
+// wsInput sends a client's input to a pane.
+func (s *Server) wsInput(msg wsClientMessage) error {
+	if msg.Text == "" && len(msg.Keys) == 0 {
+		return fmt.Errorf("text or keys required")
+	}
+	session := s.monitor.FindSessionOn(msg.Server, msg.Session)
+	if session == nil {
+		return fmt.Errorf("session not found")
+	}
+	pane := session.AgentPane()
+	if msg.Pane != "" {
+		pane = findPane(session, msg.Pane)
+	}
+	if pane == nil {
+		return fmt.Errorf("pane not found")
+	}
+	target := pane.ID
+	if target == "" {
+		target = fmt.Sprintf("%s:%d.%d", session.Name, pane.Window, pane.Index)
+	}
+	if msg.Text != "" {
+		if err := service.SendToPane(session.Server, target, msg.Text); err != nil {
+			return err
+		}
+	}
+	for _, key := range msg.Keys {
+		if key == "" {
+			continue
+		}
+		if err := session.Server.SendRawKey(target, key); err != nil {
+			return err
+		}
+	}
+	s.monitor.Wake(session.Server.Name, session.Name)
+	return nil
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/matteo-hertel/tmux-super-powers/internal/service"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+func testSession(name string, panes ...service.Pane) service.Session {
+	s := service.Session{Name: name, Server: tmuxpkg.Server{Name: "default"}, Status: "active", Panes: panes}
+	s.Windows = []service.Window{{Index: 0, Name: "main", Panes: []string{}}}
+	for _, p := range panes {
+		s.Windows[0].Panes = append(s.Windows[0].Panes, p.ID)
+	}
+	return s
+}
+
+func TestWSPatches(t *testing.T) {
+	agent := service.Pane{ID: "%1", Type: "agent", Content: "thinking"}
+	shell := service.Pane{ID: "%2", Index: 1, Type: "shell", Content: "$ "}
+	c := &wsConn{}
+
+	snap := c.snapshot([]service.Session{testSession("api", agent, shell), testSession("web", shell)})
+	if snap.Seq != 1 || len(snap.Sessions) != 2 || len(snap.Sessions[0].Panes) != 2 {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	if w := snap.Sessions[0].Windows[0]; len(w.Panes) != 2 || w.Panes[0] != "%1" {
+		t.Errorf("window = %+v, want pane keys", w)
+	}
+
+	if _, changed := c.patch([]service.Session{testSession("api", agent, shell), testSession("web", shell)}); changed {
+		t.Error("patch without changes")
+	}
+
+	// One pane of one session changes, the other session goes away.
+	agent.Content = "done"
+	patch, changed := c.patch([]service.Session{testSession("api", agent, shell)})
+	if !changed || patch.Seq != 2 {
+		t.Fatalf("patch = %+v, %v", patch, changed)
+	}
+	if len(patch.Sessions) != 1 || len(patch.Sessions[0].Panes) != 1 || patch.Sessions[0].Panes[0].Content != "done" {
+		t.Errorf("patch sessions = %+v, want only api's agent pane", patch.Sessions)
+	}
+	if len(patch.Removed) != 1 || patch.Removed[0].Name != "web" || patch.Removed[0].Key != "default:web" {
+		t.Errorf("removed = %+v", patch.Removed)
+	}
+
+	// A pane closes.
+	api := testSession("api", agent)
+	patch, _ = c.patch([]service.Session{api})
+	if len(patch.Sessions) != 1 || len(patch.Sessions[0].Panes) != 0 || len(patch.Sessions[0].RemovedPanes) != 1 {
+		t.Errorf("patch = %+v, want %%2 removed", patch.Sessions)
+	}
+
+	// Only the subscribed pane carries content.
+	c.sub = wsSubscription{panes: []string{"%2"}}
+	snap = c.snapshot([]service.Session{testSession("api", agent, shell), testSession("web", service.Pane{ID: "%3"})})
+	if len(snap.Sessions) != 1 || snap.Sessions[0].Panes[0].Content != "" || snap.Sessions[0].Panes[1].Content != "$ " {
+		t.Errorf("pane subscription snapshot = %+v", snap.Sessions)
+	}
+}
+
+func TestWebSocketV2(t *testing.T) {
+	srv := newTestServer()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/ws", srv.handleWebSocket)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Sec-WebSocket-Protocol": {wsSubprotocol}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if p := resp.Header.Get("Sec-WebSocket-Protocol"); p != wsSubprotocol {
+		t.Errorf("subprotocol = %q", p)
+	}
+	read := func() map[string]any {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg map[string]any
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	if msg := read(); msg["type"] != "snapshot" || msg["protocol"] != float64(2) || msg["seq"] != float64(1) {
+		t.Fatalf("first message = %v", msg)
+	}
+
+	conn.WriteJSON(map[string]any{"type": "subscribe", "events": []string{"ci.status.changed"}})
+	if msg := read(); msg["type"] != "snapshot" || msg["seq"] != float64(2) {
+		t.Fatalf("after subscribe = %v", msg)
+	}
+	srv.journal.Append(service.StatusChangedEvent{Session: "api", To: "done"}, time.Now())
+	srv.journal.Append(service.CIStatusChangedEvent{Session: "api", To: "fail"}, time.Now())
+	msg := read()
+	event, _ := msg["event"].(map[string]any)
+	if msg["type"] != "event" || event["type"] != "ci.status.changed" || event["seq"] != float64(2) {
+		t.Errorf("event message = %v", msg)
+	}
+
+	conn.WriteJSON(map[string]any{"type": "input", "id": "1", "session": "nope", "text": "hi"})
+	if msg := read(); msg["type"] != "ack" || msg["id"] != "1" || msg["ok"] != false || msg["error"] != "session not found" {
+		t.Errorf("input ack = %v", msg)
+	}
+	conn.WriteJSON(map[string]any{"type": "resync"})
+	if msg := read(); msg["type"] != "snapshot" || msg["seq"] != float64(3) {
+		t.Errorf("after resync = %v", msg)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	if msg := read(); msg["type"] != "error" {
+		t.Errorf("after garbage = %v", msg)
+	}
+}
+
+func TestWebSocketV1Unchanged(t *testing.T) {
+	srv := newTestServer()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/ws", srv.handleWebSocket)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg["sessions"]; !ok || msg["type"] != nil {
+		t.Errorf("v1 message = %v, want a bare session list", msg)
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
+)
+
+// StatusInput is what a StatusDetector sees of a pane on each poll.
+type StatusInput struct {
+	Process     string    // the pane's process, or the agent running under its shell
+	Content     string    // current capture
+	PrevContent string    // capture from the previous poll
+	LastChanged time.Time // when the content last changed
+	Now         time.Time
+	Log         *agentlog.State // state from the agent's session log; nil if there is none
+	Thresholds  Thresholds      // zero means DefaultThresholds
+}
+
+// StatusDetector infers the status of panes running a particular program:
+// active, idle, done, waiting or error. For waiting it also returns the
+// prompt the pane is waiting on.
+type StatusDetector interface {
+	Name() string
+	Match(process string) bool
+	IsAgent() bool
+	Detect(in StatusInput) (status, prompt string)
+}
+
+// patternDetector infers status from the pane content: error patterns in
+// the last lines, a prompt that means the program finished, and input
+// prompts that mean an agent is waiting for an answer.
+type patternDetector struct {
+	name          string
+	processes     []string
+	agent         bool
+	errorPatterns []string
+	promptPattern string
+	inputPatterns []*regexp.Regexp
+}
+
+// newPatternDetector builds a detector whose patterns are the dash patterns
+// plus its own. Invalid input patterns are skipped.
+func newPatternDetector(dash config.DashConfig, d config.DetectorConfig) *patternDetector {
+	p := &patternDetector{
+		name:          d.Name,
+		processes:     d.Processes,
+		agent:         d.Agent,
+		errorPatterns: append(slices.Clone(dash.ErrorPatterns), d.ErrorPatterns...),
+		promptPattern: joinPatterns(dash.PromptPattern, d.PromptPattern),
+	}
+	if p.agent {
+		for _, pattern := range append(slices.Clone(dash.InputPatterns), d.InputPatterns...) {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Printf("[detector] %s: invalid input pattern %q: %v", d.Name, pattern, err)
+				continue
+			}
+			p.inputPatterns = append(p.inputPatterns, re)
+		}
+	}
+	return p
+}
+
+// joinPatterns combines two regexps into one that matches either.
+func joinPatterns(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return "(?:" + a + ")|(?:" + b + ")"
+}
+
+func (p *patternDetector) Name() string { return p.name }
+
+func (p *patternDetector) Match(process string) bool { return slices.Contains(p.processes, process) }
+
+func (p *patternDetector) IsAgent() bool { return p.agent }
+
+func (p *patternDetector) Detect(in StatusInput) (string, string) {
+	th := in.Thresholds
+	if th == (Thresholds{}) {
+		th = DefaultThresholds
+	}
+	status := InferStatusWith(in.PrevContent, in.Content, in.LastChanged, in.Now, p.errorPatterns, p.promptPattern, th)
+	if status == "error" || status == "done" {
+		return status, ""
+	}
+	if prompt, ok := matchInputPrompt(in.Content, p.inputPatterns); ok {
+		return "waiting", prompt
+	}
+	return status, ""
+}
+
+// claudeDetector is the detector for Claude Code. It reads status from the
+// session's JSONL log, which knows when a turn has ended or a question is
+// pending, and falls back to its patterns when there is no log or the log
+// has not been written for the idle threshold.
+type claudeDetector struct {
+	*patternDetector
+}
+
+func (c claudeDetector) Match(process string) bool { return isClaudeComm(process) }
+
+func (c claudeDetector) Detect(in StatusInput) (string, string) {
+	if in.Log == nil || in.Log.Status == "" {
+		return c.patternDetector.Detect(in)
+	}
+	th := in.Thresholds
+	if th == (Thresholds{}) {
+		th = DefaultThresholds
+	}
+	if !in.Log.WrittenAt.IsZero() && in.Now.Sub(in.Log.WrittenAt) >= th.IdleAfter {
+		// The log may be stale: the agent hung, exited or moved to another
+		// log. A quiet screen doesn't undo a finished turn, though.
+		status, prompt := c.patternDetector.Detect(in)
+		if status == "idle" && (in.Log.Status == "done" || in.Log.Status == "error") {
+			return in.Log.Status, ""
+		}
+		return status, prompt
+	}
+	switch in.Log.Status {
+	case "waiting":
+		prompt := in.Log.Prompt
+		if prompt == "" {
+			prompt = lastLines(in.Content, 3)
+		}
+		return "waiting", prompt
+	case "active":
+		// Permission prompts for a pending tool call only show on screen.
+		if in.Log.Pending != "" {
+			if prompt, ok := matchInputPrompt(in.Content, c.inputPatterns); ok {
+				return "waiting", prompt
+			}
+		}
+	}
+	return in.Log.Status, ""
+}
+
+// shellDetector is the detector for plain shells and any process no other
+// detector claims. Shells never wait for input; a visible prompt after a
+// quiet minute means the last command finished.
+type shellDetector struct {
+	*patternDetector
+}
+
+func (s shellDetector) Match(process string) bool {
+	return PaneTypeFromProcess(process) == "shell"
+}
+
+// builtinDetectors returns the detectors for the agents tsp knows about.
+func builtinDetectors(dash config.DashConfig) []StatusDetector {
+	return []StatusDetector{
+		claudeDetector{newPatternDetector(dash, config.DetectorConfig{
+			Name:          "claude",
+			Agent:         true,
+			ErrorPatterns: []string{"API Error:"},
+			InputPatterns: []string{`Enter to select`, `^\s*❯ \d+\. `},
+		})},
+		newPatternDetector(dash, config.DetectorConfig{
+			Name:          "aider",
+			Processes:     []string{"aider"},
+			Agent:         true,
+			PromptPattern: `^\w*>$`,
+			InputPatterns: []string{`\(Y\)es/\(N\)o`},
+		}),
+		newPatternDetector(dash, config.DetectorConfig{
+			Name:          "codex",
+			Processes:     []string{"codex"},
+			Agent:         true,
+			ErrorPatterns: []string{"stream error"},
+			PromptPattern: `^\s*[▌›]$`,
+			InputPatterns: []string{`Allow command\?`, `Yes, proceed`},
+		}),
+	}
+}
+
+// Detectors picks the StatusDetector for a pane from its process. Detectors
+// defined in config are tried first, so they can replace a built-in one.
+type Detectors struct {
+	list     []StatusDetector
+	fallback StatusDetector
+}
+
+// NewDetectors builds the built-in detectors plus those in dash.detectors,
+// all sharing the dash error, prompt and input patterns.
+func NewDetectors(dash config.DashConfig) *Detectors {
+	d := &Detectors{
+		fallback: shellDetector{newPatternDetector(dash, config.DetectorConfig{Name: "shell"})},
+	}
+	for _, dc := range dash.Detectors {
+		d.list = append(d.list, newPatternDetector(dash, dc))
+	}
+	d.list = append(d.list, builtinDetectors(dash)...)
+	return d
+}
+
+// For returns the detector for a process, falling back to the shell one.
+func (d *Detectors) For(process string) StatusDetector {
+	for _, det := range d.list {
+		if det.Match(process) {
+			return det
+		}
+	}
+	return d.fallback
+}
+
+// IsAgent reports whether a process is an agent according to its detector.
+func (d *Detectors) IsAgent(process string) bool {
+	return d.For(process).IsAgent()
+}
+
+// PaneType classifies a pane's process like PaneTypeFromProcess, counting
+// processes of agent detectors from config as agents too.
+func (d *Detectors) PaneType(process string) string {
+	if t := PaneTypeFromProcess(process); t != "process" {
+		return t
+	}
+	if d.IsAgent(process) {
+		return "agent"
+	}
+	return "process"
+}
+
+// matchInputPrompt checks the last lines of a pane's content for an input
+// prompt. Returns the last three lines as the prompt text.
+func matchInputPrompt(content string, patterns []*regexp.Regexp) (string, bool) {
+	if content == "" {
+		return "", false
+	}
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	check := lines
+	if len(check) > 10 {
+		check = check[len(check)-10:]
+	}
+	for _, re := range patterns {
+		for _, line := range check {
+			if re.MatchString(ansiRe.ReplaceAllString(line, "")) {
+				return lastLines(content, 3), true
+			}
+		}
+	}
+	return "", false
+}
+
+// lastLines returns the last n lines of a pane's content.
+func lastLines(content string, n int) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
+)
+
+func testDashConfig() config.DashConfig {
+	return config.DashConfig{
+		ErrorPatterns: []string{"FAIL"},
+		PromptPattern: `\$\s*$`,
+		InputPatterns: []string{`\(y/n\)`},
+	}
+}
+
+func TestDetectorsFor(t *testing.T) {
+	dash := testDashConfig()
+	dash.Detectors = []config.DetectorConfig{
+		{Name: "goose", Processes: []string{"goose"}, Agent: true},
+		{Name: "my-aider", Processes: []string{"aider"}, Agent: true},
+	}
+	d := NewDetectors(dash)
+
+	tests := []struct {
+		process string
+		want    string
+	}{
+		{"claude", "claude"},
+		{"2.1.71", "claude"},
+		{"aider", "my-aider"},
+		{"codex", "codex"},
+		{"goose", "goose"},
+		{"zsh", "shell"},
+		{"node", "shell"},
+	}
+	for _, tt := range tests {
+		if got := d.For(tt.process).Name(); got != tt.want {
+			t.Errorf("For(%q) = %q, want %q", tt.process, got, tt.want)
+		}
+	}
+
+	if got := d.PaneType("goose"); got != "agent" {
+		t.Errorf("PaneType(goose) = %q, want agent", got)
+	}
+	if got := d.PaneType("node"); got != "process" {
+		t.Errorf("PaneType(node) = %q, want process", got)
+	}
+	if d.IsAgent("zsh") {
+		t.Error("zsh should not be an agent")
+	}
+}
+
+func TestPatternDetectorDetect(t *testing.T) {
+	d := NewDetectors(testDashConfig())
+	now := time.Now()
+	quiet := now.Add(-90 * time.Second)
+
+	tests := []struct {
+		name        string
+		process     string
+		content     string
+		lastChanged time.Time
+		want        string
+	}{
+		{"claude waiting on dash pattern", "claude", "Run tests? (y/n)", now, "waiting"},
+		{"claude waiting on menu", "claude", "Do you want to proceed?\n❯ 1. Yes\n  2. No", now, "waiting"},
+		{"claude api error", "claude", "API Error: 529 overloaded", now, "error"},
+		{"aider prompt done", "aider", "Applied edit to main.go\narchitect> ", quiet, "done"},
+		{"aider confirm", "aider", "Add file to the chat? (Y)es/(N)o [Yes]:", now, "waiting"},
+		{"codex approval", "codex", "Allow command?\n  Yes, proceed", now, "waiting"},
+		{"shell ignores input prompts", "zsh", "Overwrite? (y/n)", now, "active"},
+		{"shell done", "zsh", "ok\n$ ", quiet, "done"},
+		{"shell error", "bash", "--- FAIL: TestX", now, "error"},
+	}
+	for _, tt := range tests {
+		got, _ := d.For(tt.process).Detect(StatusInput{
+			Process:     tt.process,
+			Content:     tt.content,
+			PrevContent: tt.content,
+			LastChanged: tt.lastChanged,
+			Now:         now,
+		})
+		if got != tt.want {
+			t.Errorf("%s: status = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClaudeDetectorLog(t *testing.T) {
+	d := NewDetectors(testDashConfig()).For("claude")
+	now := time.Now()
+	// A spinner keeps the screen changing after the turn has ended.
+	in := StatusInput{Process: "claude", Content: "✻ Thinking…", PrevContent: "✢ Thinking…", LastChanged: now, Now: now}
+
+	tests := []struct {
+		name       string
+		log        *agentlog.State
+		want       string
+		wantPrompt string
+	}{
+		{"no log falls back to patterns", nil, "active", ""},
+		{"empty log falls back to patterns", &agentlog.State{}, "active", ""},
+		{"end_turn", &agentlog.State{Status: "done"}, "done", ""},
+		{"question", &agentlog.State{Status: "waiting", Pending: "AskUserQuestion", Prompt: "Which DB?"}, "waiting", "Which DB?"},
+		{"tool running", &agentlog.State{Status: "active", Pending: "Bash"}, "active", ""},
+		{"api error", &agentlog.State{Status: "error"}, "error", ""},
+	}
+	for _, tt := range tests {
+		in.Log = tt.log
+		got, prompt := d.Detect(in)
+		if got != tt.want || prompt != tt.wantPrompt {
+			t.Errorf("%s: Detect = (%q, %q), want (%q, %q)", tt.name, got, prompt, tt.want, tt.wantPrompt)
+		}
+	}
+
+	// A permission prompt for a pending tool call is only on screen.
+	in.Content = "Bash(rm -rf build)\nDo you want to proceed?\n❯ 1. Yes\n  2. No"
+	in.Log = &agentlog.State{Status: "active", Pending: "Bash"}
+	if got, _ := d.Detect(in); got != "waiting" {
+		t.Errorf("permission prompt: status = %q, want waiting", got)
+	}
+}
+
+func TestClaudeDetectorStaleLog(t *testing.T) {
+	d := NewDetectors(testDashConfig()).For("claude")
+	now := time.Now()
+	quiet := StatusInput{Process: "claude", Content: "> ", PrevContent: "> ", LastChanged: now.Add(-45 * time.Second), Now: now}
+
+	// A log left saying active long ago no longer overrides a quiet screen.
+	quiet.Log = &agentlog.State{Status: "active", Pending: "Bash", WrittenAt: now.Add(-time.Minute)}
+	if got, _ := d.Detect(quiet); got != "idle" {
+		t.Errorf("stale active log: status = %q, want idle", got)
+	}
+	// A recent write still wins.
+	quiet.Log.WrittenAt = now.Add(-time.Second)
+	if got, _ := d.Detect(quiet); got != "active" {
+		t.Errorf("fresh active log: status = %q, want active", got)
+	}
+	// A finished turn stays done while the screen is quiet.
+	quiet.Log = &agentlog.State{Status: "done", WrittenAt: now.Add(-time.Hour)}
+	if got, _ := d.Detect(quiet); got != "done" {
+		t.Errorf("stale done log: status = %q, want done", got)
+	}
+}
+
+func TestSessionStatus(t *testing.T) {
+	panes := []Pane{{Status: "idle"}, {Status: "waiting"}}
+	if got := sessionStatus(panes, 0); got != "waiting" {
+		t.Errorf("sessionStatus = %q, want waiting", got)
+	}
+	panes[0].Status = "done"
+	if got := sessionStatus(panes, 0); got != "done" {
+		t.Errorf("sessionStatus = %q, want done", got)
+	}
+	panes[1].Status = "active"
+	panes[0].Status = "idle"
+	if got := sessionStatus(panes, 0); got != "idle" {
+		t.Errorf("sessionStatus = %q, want idle", got)
+	}
+}
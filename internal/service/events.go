@@ -1,8 +1,12 @@
 package service
 
 import (
+	"cmp"
+	"fmt"
 	"log"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,82 +15,335 @@ type Event interface {
 	EventType() string
 }
 
+// EventSession returns the session an event is about.
+func EventSession(e Event) string {
+	_, name := eventSessionRef(e)
+	return name
+}
+
+// EventServer returns the label of the tmux server of the session an event
+// is about; empty when the publisher didn't know it.
+func EventServer(e Event) string {
+	server, _ := eventSessionRef(e)
+	return server
+}
+
+func eventSessionRef(e Event) (server, name string) {
+	switch ev := e.(type) {
+	case SessionCreatedEvent:
+		return ev.Server, ev.Name
+	case SessionRemovedEvent:
+		return ev.Server, ev.Name
+	case StatusChangedEvent:
+		return ev.Server, ev.Session
+	case PaneUpdatedEvent:
+		return ev.Server, ev.Session
+	case AgentStuckEvent:
+		return ev.Server, ev.Session
+	case AgentCrashedEvent:
+		return ev.Server, ev.Session
+	case AgentWaitingEvent:
+		return ev.Server, ev.Session
+	case ResourceThresholdEvent:
+		return ev.Server, ev.Session
+	case BudgetExceededEvent:
+		return ev.Server, ev.Session
+	case PRDetectedEvent:
+		return ev.Server, ev.Session
+	case CIStatusChangedEvent:
+		return ev.Server, ev.Session
+	case ReviewsChangedEvent:
+		return ev.Server, ev.Session
+	case PRMergedEvent:
+		return ev.Server, ev.Session
+	case FixAttemptedEvent:
+		return ev.Server, ev.Session
+	case CleanupCompletedEvent:
+		return ev.Server, ev.Session
+	}
+	return "", ""
+}
+
 // UnsubscribeFunc removes a subscriber when called.
 type UnsubscribeFunc func()
 
-// Bus is a typed pub/sub event bus.
+// OverflowPolicy decides what Publish does when a subscriber's queue is
+// full.
+type OverflowPolicy string
+
+const (
+	// DropOldest discards the subscriber's oldest queued event to make room.
+	DropOldest OverflowPolicy = "drop-oldest"
+	// Block makes Publish wait until the subscriber has room. A handler that
+	// publishes while its own queue is full waits forever, so use it only
+	// with handlers that don't publish or with a generous queue, and only
+	// with handlers that return quickly: the monitor's polling waits too.
+	Block OverflowPolicy = "block"
+)
+
+// ParseOverflowPolicy parses events.overflow from the config.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch p := OverflowPolicy(s); p {
+	case DropOldest, Block:
+		return p, nil
+	case "":
+		return DropOldest, nil
+	}
+	return "", fmt.Errorf("unknown overflow policy %q (want drop-oldest or block)", s)
+}
+
+// defaultQueueSize is the queue length of each subscriber unless SetQueue
+// changes it.
+const defaultQueueSize = 256
+
+// dropLogInterval is how often a subscriber that keeps dropping events
+// logs it.
+const dropLogInterval = time.Minute
+
+// Bus is a typed pub/sub event bus. Every subscriber has a bounded queue
+// drained by its own goroutine, so it sees events one at a time and in the
+// order they were published, and a slow subscriber doesn't hold up the
+// others.
 type Bus struct {
 	mu          sync.RWMutex
-	subscribers map[int]func(Event)
+	subscribers map[int]*subscriber
 	nextID      int
+	queueSize   int
+	policy      OverflowPolicy
+
+	published atomic.Uint64
+	dropped   atomic.Uint64 // including by subscribers since removed
 }
 
 // NewBus creates a new event bus.
 func NewBus() *Bus {
 	return &Bus{
-		subscribers: make(map[int]func(Event)),
+		subscribers: make(map[int]*subscriber),
+		queueSize:   defaultQueueSize,
+		policy:      DropOldest,
+	}
+}
+
+// SetQueue sets the queue length and overflow policy of subscribers.
+// Must be called before Subscribe.
+func (b *Bus) SetQueue(size int, policy OverflowPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if size > 0 {
+		b.queueSize = size
+	}
+	if policy != "" {
+		b.policy = policy
 	}
 }
 
 // Subscribe registers a handler that receives all published events.
-// Returns an UnsubscribeFunc to remove the handler.
+// Returns an UnsubscribeFunc to remove the handler; events still queued
+// for it are discarded.
 func (b *Bus) Subscribe(handler func(Event)) UnsubscribeFunc {
+	return b.SubscribeWith(handler, "")
+}
+
+// SubscribeWith is Subscribe with an overflow policy for this subscriber
+// only, overriding the bus's (which an empty policy keeps).
+func (b *Bus) SubscribeWith(handler func(Event), policy OverflowPolicy) UnsubscribeFunc {
 	b.mu.Lock()
-	id := b.nextID
+	sub := &subscriber{
+		id:      b.nextID,
+		handler: handler,
+		size:    b.queueSize,
+		policy:  cmp.Or(policy, b.policy),
+		bus:     b,
+	}
+	sub.cond = sync.NewCond(&sub.mu)
 	b.nextID++
-	b.subscribers[id] = handler
+	b.subscribers[sub.id] = sub
 	b.mu.Unlock()
+	go sub.run()
 	return func() {
 		b.mu.Lock()
-		delete(b.subscribers, id)
+		delete(b.subscribers, sub.id)
 		b.mu.Unlock()
+		sub.close()
 	}
 }
 
-// Publish sends an event to all subscribers.
-// Each handler is called in its own goroutine. Panics are recovered.
+// Publish queues an event for every subscriber. It returns once the event
+// is queued, or with the Block policy once every subscriber had room.
+// Handler panics are recovered.
 func (b *Bus) Publish(e Event) {
 	b.mu.RLock()
-	handlers := make([]func(Event), 0, len(b.subscribers))
-	for _, h := range b.subscribers {
-		handlers = append(handlers, h)
+	subs := make([]*subscriber, 0, len(b.subscribers))
+	for _, s := range b.subscribers {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	b.published.Add(1)
+	for _, s := range subs {
+		s.push(e)
+	}
+}
+
+// BusStats counts the events through a bus.
+type BusStats struct {
+	Published   uint64            `json:"published"`
+	Dropped     uint64            `json:"dropped"`
+	Subscribers []SubscriberStats `json:"subscribers"`
+}
+
+// SubscriberStats counts the events of one subscriber.
+type SubscriberStats struct {
+	ID        int    `json:"id"`
+	Queued    int    `json:"queued"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// Stats returns the bus's event counters.
+func (b *Bus) Stats() BusStats {
+	b.mu.RLock()
+	subs := make([]*subscriber, 0, len(b.subscribers))
+	for _, s := range b.subscribers {
+		subs = append(subs, s)
 	}
 	b.mu.RUnlock()
 
-	for _, h := range handlers {
-		go func(handler func(Event)) {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("event bus: handler panicked on %s: %v", e.EventType(), r)
-				}
-			}()
-			handler(e)
-		}(h)
+	stats := BusStats{Published: b.published.Load(), Dropped: b.dropped.Load()}
+	for _, s := range subs {
+		stats.Subscribers = append(stats.Subscribers, s.stats())
+	}
+	sort.Slice(stats.Subscribers, func(i, j int) bool { return stats.Subscribers[i].ID < stats.Subscribers[j].ID })
+	return stats
+}
+
+// subscriber is a handler with its queue of undelivered events.
+type subscriber struct {
+	id      int
+	handler func(Event)
+	size    int
+	policy  OverflowPolicy
+	bus     *Bus
+
+	mu        sync.Mutex
+	cond      *sync.Cond // broadcast when the queue grows, shrinks or closes
+	queue     []Event
+	closed    bool
+	delivered uint64
+	dropped   uint64
+	loggedAt  time.Time // when dropping was last logged
+	unlogged  uint64    // drops since then
+}
+
+func (s *subscriber) push(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.policy == Block && len(s.queue) >= s.size && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return
+	}
+	if len(s.queue) >= s.size {
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.unlogged++
+		if now := time.Now(); now.Sub(s.loggedAt) >= dropLogInterval {
+			log.Printf("event bus: subscriber %d is %d events behind, dropped the oldest %d", s.id, s.size, s.unlogged)
+			s.loggedAt, s.unlogged = now, 0
+		}
+		s.dropped++
+		s.bus.dropped.Add(1)
+	}
+	s.queue = append(s.queue, e)
+	s.cond.Broadcast()
+}
+
+// run delivers queued events in order until the subscriber is closed.
+func (s *subscriber) run() {
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		e := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		s.deliver(e)
+
+		s.mu.Lock()
+		s.delivered++
+		s.mu.Unlock()
 	}
 }
 
+func (s *subscriber) deliver(e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("event bus: handler panicked on %s: %v", e.EventType(), r)
+		}
+	}()
+	s.handler(e)
+}
+
+// close stops delivery, discards queued events and releases blocked
+// publishers.
+func (s *subscriber) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.queue = nil
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func (s *subscriber) stats() SubscriberStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SubscriberStats{ID: s.id, Queued: len(s.queue), Delivered: s.delivered, Dropped: s.dropped}
+}
+
 // --- Core lifecycle events ---
 
-type SessionCreatedEvent struct{ Name string }
+type SessionCreatedEvent struct {
+	Name   string    `json:"name"`
+	Server string    `json:"server,omitempty"`
+	Status string    `json:"status"` // status when first seen
+	At     time.Time `json:"at"`     // when the monitor saw it
+}
 
 func (e SessionCreatedEvent) EventType() string { return "session.created" }
 
-type SessionRemovedEvent struct{ Name string }
+type SessionRemovedEvent struct {
+	Name   string    `json:"name"`
+	Server string    `json:"server,omitempty"`
+	Status string    `json:"status"` // last status
+	At     time.Time `json:"at"`     // when the monitor noticed
+}
 
 func (e SessionRemovedEvent) EventType() string { return "session.removed" }
 
 type StatusChangedEvent struct {
-	Session string
-	From    string
-	To      string
+	Session string    `json:"session"`
+	Server  string    `json:"server,omitempty"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	At      time.Time `json:"at"` // when the monitor saw the change
 }
 
 func (e StatusChangedEvent) EventType() string { return "status.changed" }
 
 type PaneUpdatedEvent struct {
-	Session   string
-	PaneIndex int
-	Content   string
+	Session   string `json:"session"`
+	Server    string `json:"server,omitempty"`
+	PaneIndex int    `json:"paneIndex"`
+	Content   string `json:"content"`
 }
 
 func (e PaneUpdatedEvent) EventType() string { return "pane.updated" }
@@ -94,60 +351,95 @@ func (e PaneUpdatedEvent) EventType() string { return "pane.updated" }
 // --- Agent health events ---
 
 type AgentStuckEvent struct {
-	Session      string
-	PaneIndex    int
-	IdleDuration time.Duration
+	Session      string        `json:"session"`
+	Server       string        `json:"server,omitempty"`
+	PaneIndex    int           `json:"paneIndex"`
+	PaneID       string        `json:"paneId"`
+	IdleDuration time.Duration `json:"idleDurationNs"`
 }
 
 func (e AgentStuckEvent) EventType() string { return "agent.stuck" }
 
 type AgentCrashedEvent struct {
-	Session     string
-	PaneIndex   int
-	PrevProcess string
+	Session     string `json:"session"`
+	Server      string `json:"server,omitempty"`
+	PaneIndex   int    `json:"paneIndex"`
+	PaneID      string `json:"paneId"`
+	PrevProcess string `json:"prevProcess"`
 }
 
 func (e AgentCrashedEvent) EventType() string { return "agent.crashed" }
 
 type AgentWaitingEvent struct {
-	Session   string
-	PaneIndex int
-	Prompt    string
+	Session   string `json:"session"`
+	Server    string `json:"server,omitempty"`
+	PaneIndex int    `json:"paneIndex"`
+	PaneID    string `json:"paneId"`
+	Prompt    string `json:"prompt"`
 }
 
 func (e AgentWaitingEvent) EventType() string { return "agent.waiting" }
 
+// ResourceThresholdEvent fires when a session's process trees cross a
+// resources limit: Resource is "cpu" (Value and Limit in percent of one
+// core) or "memory" (in bytes).
+type ResourceThresholdEvent struct {
+	Session  string  `json:"session"`
+	Server   string  `json:"server,omitempty"`
+	Resource string  `json:"resource"`
+	Value    float64 `json:"value"`
+	Limit    float64 `json:"limit"`
+}
+
+func (e ResourceThresholdEvent) EventType() string { return "resource.threshold" }
+
+// BudgetExceededEvent fires once when a session's estimated cost reaches its
+// budget. Paused reports whether its agents were interrupted.
+type BudgetExceededEvent struct {
+	Session   string  `json:"session"`
+	Server    string  `json:"server,omitempty"`
+	CostUSD   float64 `json:"costUsd"`
+	BudgetUSD float64 `json:"budgetUsd"`
+	Paused    bool    `json:"paused"`
+}
+
+func (e BudgetExceededEvent) EventType() string { return "budget.exceeded" }
+
 // --- PR/CI lifecycle events ---
 
 type PRDetectedEvent struct {
-	Session  string
-	PRNumber int
-	URL      string
+	Session  string `json:"session"`
+	Server   string `json:"server,omitempty"`
+	PRNumber int    `json:"prNumber"`
+	URL      string `json:"url"`
 }
 
 func (e PRDetectedEvent) EventType() string { return "pr.detected" }
 
 type CIStatusChangedEvent struct {
-	Session  string
-	PRNumber int
-	From     string
-	To       string
+	Session  string `json:"session"`
+	Server   string `json:"server,omitempty"`
+	PRNumber int    `json:"prNumber"`
+	From     string `json:"from"`
+	To       string `json:"to"`
 }
 
 func (e CIStatusChangedEvent) EventType() string { return "ci.status.changed" }
 
 type ReviewsChangedEvent struct {
-	Session   string
-	PRNumber  int
-	Count     int
-	PrevCount int
+	Session   string `json:"session"`
+	Server    string `json:"server,omitempty"`
+	PRNumber  int    `json:"prNumber"`
+	Count     int    `json:"count"`
+	PrevCount int    `json:"prevCount"`
 }
 
 func (e ReviewsChangedEvent) EventType() string { return "reviews.changed" }
 
 type PRMergedEvent struct {
-	Session  string
-	PRNumber int
+	Session  string `json:"session"`
+	Server   string `json:"server,omitempty"`
+	PRNumber int    `json:"prNumber"`
 }
 
 func (e PRMergedEvent) EventType() string { return "pr.merged" }
@@ -155,18 +447,20 @@ func (e PRMergedEvent) EventType() string { return "pr.merged" }
 // --- Action events ---
 
 type FixAttemptedEvent struct {
-	Session     string
-	FixType     string // "ci" or "reviews"
-	Attempt     int
-	MaxAttempts int
+	Session     string `json:"session"`
+	Server      string `json:"server,omitempty"`
+	FixType     string `json:"fixType"` // "ci" or "reviews"
+	Attempt     int    `json:"attempt"`
+	MaxAttempts int    `json:"maxAttempts"`
 }
 
 func (e FixAttemptedEvent) EventType() string { return "fix.attempted" }
 
 type CleanupCompletedEvent struct {
-	Session      string
-	WorktreePath string
-	Branch       string
+	Session      string `json:"session"`
+	Server       string `json:"server,omitempty"`
+	WorktreePath string `json:"worktreePath"`
+	Branch       string `json:"branch"`
 }
 
 func (e CleanupCompletedEvent) EventType() string { return "cleanup.completed" }
@@ -1,6 +1,9 @@
 package service
 
 import (
+	"math/rand"
+	"slices"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -111,3 +114,231 @@ func TestBusMultipleSubscribers(t *testing.T) {
 		t.Errorf("expected both subscribers to receive, got %d and %d", count1, count2)
 	}
 }
+
+// seqEvent is a StatusChangedEvent numbered by publisher and sequence.
+func seqEvent(publisher, seq int) Event {
+	return StatusChangedEvent{Session: strconv.Itoa(publisher), From: strconv.Itoa(seq)}
+}
+
+func TestBusOrderingUnderLoad(t *testing.T) {
+	const publishers, perPublisher, subscribers = 8, 500, 4
+
+	bus := NewBus()
+	bus.SetQueue(16, Block) // small queue, so publishers wait on slow subscribers
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	got := make([]map[int][]int, subscribers)
+	for i := range subscribers {
+		got[i] = make(map[int][]int)
+		wg.Add(publishers * perPublisher)
+		bus.Subscribe(func(e Event) {
+			defer wg.Done()
+			sc := e.(StatusChangedEvent)
+			p, _ := strconv.Atoi(sc.Session)
+			seq, _ := strconv.Atoi(sc.From)
+			if i%2 == 0 && rand.Intn(50) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			mu.Lock()
+			got[i][p] = append(got[i][p], seq)
+			mu.Unlock()
+		})
+	}
+
+	for p := range publishers {
+		go func() {
+			for seq := range perPublisher {
+				bus.Publish(seqEvent(p, seq))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range subscribers {
+		for p := range publishers {
+			seqs := got[i][p]
+			if len(seqs) != perPublisher || !slices.IsSorted(seqs) {
+				t.Fatalf("subscriber %d got publisher %d's events out of order or incomplete: %d events", i, p, len(seqs))
+			}
+		}
+	}
+	if stats := bus.Stats(); stats.Dropped != 0 || stats.Published != publishers*perPublisher {
+		t.Errorf("stats = %+v, want %d published and none dropped", stats, publishers*perPublisher)
+	}
+}
+
+func TestBusDropOldest(t *testing.T) {
+	bus := NewBus()
+	bus.SetQueue(2, DropOldest)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var got []string
+	bus.Subscribe(func(e Event) {
+		name := e.(SessionCreatedEvent).Name
+		if name == "1" {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		got = append(got, name)
+		mu.Unlock()
+	})
+
+	bus.Publish(SessionCreatedEvent{Name: "1"})
+	<-started // 1 is being handled, the queue is empty
+	for i := 2; i <= 5; i++ {
+		bus.Publish(SessionCreatedEvent{Name: strconv.Itoa(i)})
+	}
+	stats := bus.Stats()
+	if stats.Dropped != 2 || stats.Subscribers[0].Dropped != 2 || stats.Subscribers[0].Queued != 2 {
+		t.Errorf("stats = %+v, want 2 dropped and 2 queued", stats)
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"1", "4", "5"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBusBlock(t *testing.T) {
+	bus := NewBus()
+	bus.SetQueue(1, Block)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	received := make(chan string, 3)
+	unsub := bus.Subscribe(func(e Event) {
+		name := e.(SessionCreatedEvent).Name
+		if name == "1" {
+			close(started)
+			<-release
+		}
+		received <- name
+	})
+
+	bus.Publish(SessionCreatedEvent{Name: "1"})
+	<-started
+	bus.Publish(SessionCreatedEvent{Name: "2"}) // fills the queue
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(SessionCreatedEvent{Name: "3"})
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("Publish returned while the queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish still blocked after the subscriber caught up")
+	}
+
+	var got []string
+	for range 3 {
+		got = append(got, <-received)
+	}
+	if want := []string{"1", "2", "3"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if d := bus.Stats().Dropped; d != 0 {
+		t.Errorf("dropped = %d, want 0", d)
+	}
+	unsub()
+}
+
+func TestBusSubscribeWithBlock(t *testing.T) {
+	bus := NewBus()
+	bus.SetQueue(1, DropOldest)
+	var mu sync.Mutex
+	var got []string
+	unsub := bus.SubscribeWith(func(e Event) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got = append(got, e.(SessionCreatedEvent).Name)
+		mu.Unlock()
+	}, Block)
+	defer unsub()
+
+	var want []string
+	for i := range 20 {
+		name := strconv.Itoa(i)
+		want = append(want, name)
+		bus.Publish(SessionCreatedEvent{Name: name})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		done := len(got) == len(want)
+		mu.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want every event in order", got)
+	}
+	if d := bus.Stats().Dropped; d != 0 {
+		t.Errorf("dropped %d events", d)
+	}
+}
+
+func TestBusUnsubscribeReleasesBlockedPublisher(t *testing.T) {
+	bus := NewBus()
+	bus.SetQueue(1, Block)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	unsub := bus.Subscribe(func(e Event) {
+		if e.(SessionCreatedEvent).Name == "1" {
+			close(started)
+		}
+		<-release
+	})
+
+	bus.Publish(SessionCreatedEvent{Name: "1"})
+	<-started
+	bus.Publish(SessionCreatedEvent{Name: "2"})
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(SessionCreatedEvent{Name: "3"})
+		close(published)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	unsub()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish still blocked after Unsubscribe")
+	}
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for in, want := range map[string]OverflowPolicy{"": DropOldest, "drop-oldest": DropOldest, "block": Block} {
+		if got, err := ParseOverflowPolicy(in); err != nil || got != want {
+			t.Errorf("ParseOverflowPolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseOverflowPolicy("drop-newest"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+// StatusTransition is one entry of a session's status history. From is
+// empty when the session appeared, To when it went away.
+type StatusTransition struct {
+	Time time.Time `json:"time"`
+	From string    `json:"from,omitempty"`
+	To   string    `json:"to,omitempty"`
+}
+
+// historyPruneInterval is how often the history directory is checked for
+// files past their retention.
+const historyPruneInterval = time.Hour
+
+// StatusHistory records every status transition to an append-only JSONL
+// file per session and tmux server, from the events on the bus. A session
+// that goes away gets a final transition to no status; a new session
+// reusing the name continues the same file. Files untouched for longer
+// than the retention are deleted, and the oldest transitions dropped from
+// one that outgrows its size limit.
+type StatusHistory struct {
+	dir      string
+	maxAge   time.Duration // zero keeps files forever
+	maxBytes int64         // zero lets files grow without limit
+	bus      *Bus
+	unsub    UnsubscribeFunc
+
+	mu     sync.Mutex
+	pruned time.Time // when the directory was last pruned
+}
+
+// HistoryDir returns the directory status history is kept in
+// (~/.tsp/history).
+func HistoryDir() string {
+	return filepath.Join(config.TspDir(), "history")
+}
+
+// NewStatusHistory creates a history that writes under dir (normally
+// HistoryDir()), limited as cfg says. The zero config keeps everything.
+func NewStatusHistory(dir string, cfg config.HistoryConfig, bus *Bus) *StatusHistory {
+	h := &StatusHistory{dir: dir, bus: bus}
+	if cfg.RetentionDays > 0 {
+		h.maxAge = time.Duration(cfg.RetentionDays) * 24 * time.Hour
+	}
+	if cfg.MaxFileKB > 0 {
+		h.maxBytes = int64(cfg.MaxFileKB) * 1024
+	}
+	return h
+}
+
+// Start subscribes to the bus. Start it before the monitor so the initial
+// status of every session is recorded.
+func (h *StatusHistory) Start() {
+	h.unsub = h.bus.Subscribe(h.HandleEvent)
+}
+
+// Stop unsubscribes from the bus.
+func (h *StatusHistory) Stop() {
+	if h.unsub != nil {
+		h.unsub()
+	}
+}
+
+// HandleEvent records the transition an event describes, if any.
+func (h *StatusHistory) HandleEvent(e Event) {
+	var server, session string
+	var t StatusTransition
+	switch ev := e.(type) {
+	case SessionCreatedEvent:
+		server, session, t = ev.Server, ev.Name, StatusTransition{Time: ev.At, To: ev.Status}
+	case StatusChangedEvent:
+		server, session, t = ev.Server, ev.Session, StatusTransition{Time: ev.At, From: ev.From, To: ev.To}
+	case SessionRemovedEvent:
+		server, session, t = ev.Server, ev.Name, StatusTransition{Time: ev.At, From: ev.Status}
+	default:
+		return
+	}
+	if t.Time.IsZero() {
+		t.Time = time.Now()
+	}
+	if err := h.Append(server, session, t); err != nil {
+		log.Printf("[history] %s: %v", session, err)
+	}
+}
+
+// path returns the history file of a session on a tmux server, the
+// default server when it is empty.
+func (h *StatusHistory) path(server, session string) string {
+	if server == "" {
+		server = tmuxpkg.DefaultServer().Name
+	}
+	clean := func(s string) string { return strings.ReplaceAll(s, string(filepath.Separator), "_") }
+	return filepath.Join(h.dir, clean(server), clean(session)+".jsonl")
+}
+
+// Append adds a transition to the history of a session on a tmux server.
+func (h *StatusHistory) Append(server, session string, t StatusTransition) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now := time.Now(); now.Sub(h.pruned) >= historyPruneInterval {
+		h.pruned = now
+		h.prune(now)
+	}
+	path := h.path(server, session)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	info, statErr := f.Stat()
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if statErr == nil && h.maxBytes > 0 && info.Size() > h.maxBytes {
+		return h.trim(path)
+	}
+	return nil
+}
+
+// trim drops the oldest transitions of a file, keeping the newest half of
+// the size limit. Callers must hold h.mu.
+func (h *StatusHistory) trim(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	keep := data[max(int64(len(data))-h.maxBytes/2, 0):]
+	if i := bytes.IndexByte(keep, '\n'); i >= 0 && len(keep) < len(data) {
+		keep = keep[i+1:]
+	}
+	return writeFileAtomic(path, keep)
+}
+
+// prune deletes the files not written to within the retention. Callers
+// must hold h.mu.
+func (h *StatusHistory) prune(now time.Time) {
+	if h.maxAge <= 0 {
+		return
+	}
+	files, _ := filepath.Glob(filepath.Join(h.dir, "*", "*.jsonl"))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil && now.Sub(info.ModTime()) > h.maxAge {
+			if err := os.Remove(file); err != nil {
+				log.Printf("[history] %v", err)
+			}
+		}
+	}
+}
+
+// Load returns the transitions of a session on a tmux server, oldest
+// first. A session with no history has none.
+func (h *StatusHistory) Load(server, session string) ([]StatusTransition, error) {
+	f, err := os.Open(h.path(server, session))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []StatusTransition
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var t StatusTransition
+		if json.Unmarshal(sc.Bytes(), &t) == nil && !t.Time.IsZero() {
+			out = append(out, t)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TimelineEntry is a transition together with how long the session stayed
+// in the status it moved to.
+type TimelineEntry struct {
+	StatusTransition
+	DurationMs int64 `json:"durationMs"`
+}
+
+// Timeline is a session's status history with the total time spent, and
+// the number of times entered, per status.
+type Timeline struct {
+	Session  string           `json:"session"`
+	Entries  []TimelineEntry  `json:"entries"`
+	TotalsMs map[string]int64 `json:"totalsMs"`
+	Counts   map[string]int   `json:"counts"`
+}
+
+// BuildTimeline computes a timeline from transitions, oldest first. The
+// last status counts up to now unless the session went away.
+func BuildTimeline(session string, transitions []StatusTransition, now time.Time) Timeline {
+	tl := Timeline{
+		Session:  session,
+		Entries:  make([]TimelineEntry, 0, len(transitions)),
+		TotalsMs: make(map[string]int64),
+		Counts:   make(map[string]int),
+	}
+	for i, t := range transitions {
+		end := now
+		if i+1 < len(transitions) {
+			end = transitions[i+1].Time
+		}
+		e := TimelineEntry{StatusTransition: t}
+		if t.To != "" {
+			e.DurationMs = max(end.Sub(t.Time), 0).Milliseconds()
+			tl.TotalsMs[t.To] += e.DurationMs
+			// A session seen again after a restart hasn't re-entered its status.
+			if i == 0 || transitions[i-1].To != t.To {
+				tl.Counts[t.To]++
+			}
+		}
+		tl.Entries = append(tl.Entries, e)
+	}
+	return tl
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+)
+
+func TestStatusHistoryRecordsEvents(t *testing.T) {
+	h := NewStatusHistory(filepath.Join(t.TempDir(), "history"), config.HistoryConfig{}, NewBus())
+	t0 := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	h.HandleEvent(SessionCreatedEvent{Name: "feat/x", Server: "work", Status: "active", At: t0})
+	h.HandleEvent(StatusChangedEvent{Session: "feat/x", Server: "work", From: "active", To: "idle", At: t0.Add(time.Minute)})
+	h.HandleEvent(AgentWaitingEvent{Session: "feat/x", Server: "work"})
+	h.HandleEvent(SessionRemovedEvent{Name: "feat/x", Server: "work", Status: "idle", At: t0.Add(3 * time.Minute)})
+	// A session of the same name on another server has its own history.
+	h.HandleEvent(SessionCreatedEvent{Name: "feat/x", Server: "personal", Status: "error", At: t0})
+	h.HandleEvent(SessionRemovedEvent{Name: "feat/x", Server: "personal", Status: "error", At: t0.Add(time.Minute)})
+
+	got, err := h.Load("work", "feat/x")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []StatusTransition{
+		{Time: t0, To: "active"},
+		{Time: t0.Add(time.Minute), From: "active", To: "idle"},
+		{Time: t0.Add(3 * time.Minute), From: "idle"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d transitions, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].From != want[i].From || got[i].To != want[i].To {
+			t.Errorf("transition %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if other, _ := h.Load("personal", "feat/x"); len(other) != 2 {
+		t.Errorf("history on the other server = %+v, want 2 transitions", other)
+	}
+
+	if none, err := h.Load("work", "other"); err != nil || none != nil {
+		t.Errorf("Load(other) = %v, %v; want no history", none, err)
+	}
+}
+
+func TestStatusHistoryLimits(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	h := NewStatusHistory(dir, config.HistoryConfig{RetentionDays: 1, MaxFileKB: 1}, NewBus())
+	t0 := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	h.Append("default", "old", StatusTransition{Time: t0, To: "done"})
+	stale := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(h.path("default", "old"), stale, stale)
+
+	for i := range 100 {
+		h.Append("default", "busy", StatusTransition{Time: t0.Add(time.Duration(i) * time.Minute), From: "idle", To: "active"})
+	}
+	info, err := os.Stat(h.path("default", "busy"))
+	if err != nil || info.Size() > 1024 {
+		t.Fatalf("history file not kept under its size limit: %v, %v", info.Size(), err)
+	}
+	got, _ := h.Load("default", "busy")
+	if len(got) == 0 || !got[len(got)-1].Time.Equal(t0.Add(99*time.Minute)) {
+		t.Errorf("trimming lost the newest transitions: %+v", got)
+	}
+
+	// Pruning runs at most once an interval.
+	h.pruned = time.Time{}
+	h.Append("default", "busy", StatusTransition{Time: t0.Add(time.Hour), To: "idle"})
+	if old, _ := h.Load("default", "old"); old != nil {
+		t.Errorf("history past its retention was kept: %+v", old)
+	}
+}
+
+func TestBuildTimeline(t *testing.T) {
+	t0 := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	transitions := []StatusTransition{
+		{Time: t0, To: "active"},
+		{Time: t0.Add(2 * time.Minute), From: "active", To: "error"},
+		{Time: t0.Add(3 * time.Minute), From: "error", To: "active"},
+		{Time: t0.Add(4 * time.Minute), From: "active", To: "error"},
+		// tsp serve restarted: the session is seen again in the same status.
+		{Time: t0.Add(6 * time.Minute), To: "error"},
+	}
+	tl := BuildTimeline("s", transitions, t0.Add(10*time.Minute))
+
+	if got := tl.TotalsMs["active"]; got != (3 * time.Minute).Milliseconds() {
+		t.Errorf("active total = %dms, want 3m", got)
+	}
+	if got := tl.TotalsMs["error"]; got != (7 * time.Minute).Milliseconds() {
+		t.Errorf("error total = %dms, want 7m", got)
+	}
+	if tl.Counts["error"] != 2 || tl.Counts["active"] != 2 {
+		t.Errorf("counts = %v, want error 2, active 2", tl.Counts)
+	}
+	if got := tl.Entries[4].DurationMs; got != (4 * time.Minute).Milliseconds() {
+		t.Errorf("last entry duration = %dms, want 4m", got)
+	}
+
+	// A removed session stops accruing time.
+	gone := append(transitions[:2:2], StatusTransition{Time: t0.Add(5 * time.Minute), From: "error"})
+	tl = BuildTimeline("s", gone, t0.Add(time.Hour))
+	if got := tl.TotalsMs["error"]; got != (3 * time.Minute).Milliseconds() {
+		t.Errorf("error total after removal = %dms, want 3m", got)
+	}
+}
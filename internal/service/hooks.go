@@ -0,0 +1,303 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+)
+
+// hookOutputLimit caps how much of a command's output is logged.
+const hookOutputLimit = 64 * 1024
+
+// HooksLogPath returns the file hook commands and their output are logged
+// to (~/.tsp/hooks.log).
+func HooksLogPath() string {
+	return filepath.Join(config.TspDir(), "hooks.log")
+}
+
+// HookRunner runs the configured shell commands on bus events.
+type HookRunner struct {
+	hooks   []config.HookConfig
+	timeout time.Duration
+	logPath string
+	bus     *Bus
+	monitor *Monitor
+	unsub   UnsubscribeFunc
+
+	sem    chan struct{} // one slot per command allowed to run at once
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	logMu  sync.Mutex
+}
+
+// NewHookRunner creates a runner for the hooks in cfg, logging to logPath
+// (normally HooksLogPath()).
+func NewHookRunner(cfg config.HooksConfig, logPath string, bus *Bus) (*HookRunner, error) {
+	for i, hook := range cfg.On {
+		if hook.Event == "" || strings.TrimSpace(hook.Run) == "" {
+			return nil, fmt.Errorf("hooks.on[%d]: event and run are required", i)
+		}
+		for _, glob := range hook.Sessions {
+			if _, err := path.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("hooks.on[%d]: bad session glob %q", i, glob)
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HookRunner{
+		hooks:   cfg.On,
+		timeout: time.Duration(max(cfg.TimeoutS, 1)) * time.Second,
+		logPath: logPath,
+		bus:     bus,
+		sem:     make(chan struct{}, max(cfg.Concurrency, 1)),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// SetMonitor sets the monitor used to look up the session of an event, for
+// its directory, worktree and branch. Must be called before Start.
+func (h *HookRunner) SetMonitor(m *Monitor) {
+	h.monitor = m
+}
+
+// Start subscribes to the bus, unless no hooks are configured.
+func (h *HookRunner) Start() {
+	if len(h.hooks) == 0 {
+		return
+	}
+	h.unsub = h.bus.Subscribe(h.HandleEvent)
+}
+
+// Stop unsubscribes from the bus and kills the commands still running.
+func (h *HookRunner) Stop() {
+	if h.unsub != nil {
+		h.unsub()
+	}
+	h.cancel()
+	h.wg.Wait()
+}
+
+// HandleEvent starts the commands hooked to an event. When as many
+// commands as allowed are running it waits for one to finish, so later
+// events queue up behind it in order.
+func (h *HookRunner) HandleEvent(e Event) {
+	var session *Session
+	if h.monitor != nil {
+		session = h.monitor.FindSessionOn(EventServer(e), EventSession(e))
+	}
+	for _, hook := range h.hooks {
+		if !HookMatches(hook, e) {
+			continue
+		}
+		select {
+		case h.sem <- struct{}{}:
+		case <-h.ctx.Done():
+			return
+		}
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			defer func() { <-h.sem }()
+			h.run(hook, e, session)
+		}()
+	}
+}
+
+// HookMatches reports whether a hook runs on an event.
+func HookMatches(hook config.HookConfig, e Event) bool {
+	if hook.Event != "*" && hook.Event != e.EventType() {
+		return false
+	}
+	if hook.To != "" {
+		switch ev := e.(type) {
+		case StatusChangedEvent:
+			if ev.To != hook.To {
+				return false
+			}
+		case CIStatusChangedEvent:
+			if ev.To != hook.To {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	if len(hook.Sessions) == 0 {
+		return true
+	}
+	name := EventSession(e)
+	return slices.ContainsFunc(hook.Sessions, func(glob string) bool {
+		ok, _ := path.Match(glob, name)
+		return ok
+	})
+}
+
+// HookEnv returns the environment a hook command gets for an event:
+// TSP_EVENT, TSP_SESSION and TSP_EVENT_JSON, every field of the event
+// (prNumber as TSP_PR_NUMBER, ...), and TSP_DIR, TSP_WORKTREE and
+// TSP_BRANCH from the event or its session. session may be nil.
+func HookEnv(e Event, session *Session) []string {
+	vars := map[string]string{
+		"TSP_EVENT":   e.EventType(),
+		"TSP_SESSION": EventSession(e),
+	}
+	if session != nil {
+		vars["TSP_DIR"] = session.Dir
+		vars["TSP_WORKTREE"] = session.WorktreePath
+		vars["TSP_BRANCH"] = session.Branch
+		vars["TSP_STATUS"] = session.Status
+	}
+	data, _ := json.Marshal(e)
+	vars["TSP_EVENT_JSON"] = string(data)
+	var fields map[string]any
+	json.Unmarshal(data, &fields)
+	for k, v := range fields {
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			s = strconv.FormatBool(v)
+		default:
+			continue
+		}
+		if s != "" {
+			vars["TSP_"+upperSnake(k)] = s
+		}
+	}
+	if wt := vars["TSP_WORKTREE_PATH"]; wt != "" {
+		vars["TSP_WORKTREE"] = wt
+	}
+
+	env := make([]string, 0, len(vars))
+	for k, v := range vars {
+		env = append(env, k+"="+v)
+	}
+	slices.Sort(env)
+	return env
+}
+
+// upperSnake turns a JSON field name into an environment variable name:
+// prNumber becomes PR_NUMBER.
+func upperSnake(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(s[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// run runs a hook's command and logs it with its output.
+func (h *HookRunner) run(hook config.HookConfig, e Event, session *Session) {
+	ctx, cancel := context.WithTimeout(h.ctx, h.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Run)
+	cmd.Env = append(os.Environ(), HookEnv(e, session)...)
+	if session != nil {
+		for _, dir := range []string{session.Dir, session.WorktreePath} {
+			if info, err := os.Stat(dir); dir != "" && err == nil && info.IsDir() {
+				cmd.Dir = dir
+				break
+			}
+		}
+	}
+	// Kill everything the command started, not just the shell.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = time.Second
+	var out limitedBuffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+
+	start := time.Now()
+	err := cmd.Run()
+	result := "ok"
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result = fmt.Sprintf("timed out after %s", h.timeout)
+	case err != nil:
+		result = err.Error()
+	}
+	if err != nil {
+		log.Printf("[hooks] %q on %s %s: %s", hook.Run, e.EventType(), EventSession(e), result)
+	}
+	h.writeLog(fmt.Sprintf("%s %s %s: %s (%s in %s)\n%s",
+		start.Format(time.RFC3339), e.EventType(), EventSession(e), hook.Run, result,
+		time.Since(start).Round(time.Millisecond), indentOutput(out.String())))
+}
+
+func (h *HookRunner) writeLog(entry string) {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(h.logPath), 0700); err != nil {
+		log.Printf("[hooks] %v", err)
+		return
+	}
+	f, err := os.OpenFile(h.logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		log.Printf("[hooks] %v", err)
+		return
+	}
+	defer f.Close()
+	f.WriteString(entry)
+}
+
+// indentOutput prefixes each line of a command's output for the log.
+func indentOutput(out string) string {
+	out = strings.TrimRight(out, "\n")
+	if out == "" {
+		return ""
+	}
+	return "  | " + strings.ReplaceAll(out, "\n", "\n  | ") + "\n"
+}
+
+// limitedBuffer keeps the first hookOutputLimit bytes written to it and
+// discards the rest.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := hookOutputLimit - b.buf.Len(); room < len(p) {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+)
+
+func TestHookMatches(t *testing.T) {
+	tests := []struct {
+		hook config.HookConfig
+		e    Event
+		want bool
+	}{
+		{config.HookConfig{Event: "agent.waiting"}, AgentWaitingEvent{Session: "a"}, true},
+		{config.HookConfig{Event: "agent.waiting"}, AgentStuckEvent{Session: "a"}, false},
+		{config.HookConfig{Event: "*"}, PRMergedEvent{Session: "a"}, true},
+		{config.HookConfig{Event: "status.changed", To: "done"}, StatusChangedEvent{Session: "a", To: "done"}, true},
+		{config.HookConfig{Event: "status.changed", To: "done"}, StatusChangedEvent{Session: "a", To: "error"}, false},
+		{config.HookConfig{Event: "ci.status.changed", To: "fail"}, CIStatusChangedEvent{Session: "a", To: "fail"}, true},
+		{config.HookConfig{Event: "*", To: "done"}, PRMergedEvent{Session: "a"}, false},
+		{config.HookConfig{Event: "*", Sessions: []string{"api-*"}}, PRMergedEvent{Session: "api-auth"}, true},
+		{config.HookConfig{Event: "*", Sessions: []string{"api-*"}}, PRMergedEvent{Session: "web-auth"}, false},
+	}
+	for _, tt := range tests {
+		if got := HookMatches(tt.hook, tt.e); got != tt.want {
+			t.Errorf("HookMatches(%+v, %T) = %v, want %v", tt.hook, tt.e, got, tt.want)
+		}
+	}
+
+	if _, err := NewHookRunner(config.HooksConfig{On: []config.HookConfig{{Event: "pr.merged"}}}, "", nil); err == nil {
+		t.Error("expected an error for a hook without run")
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	env := HookEnv(CIStatusChangedEvent{Session: "api-auth", PRNumber: 42, From: "pending", To: "fail"},
+		&Session{Name: "api-auth", Dir: "/src/api", WorktreePath: "/src/api-auth", Branch: "feat/auth"})
+	for _, want := range []string{
+		"TSP_EVENT=ci.status.changed",
+		"TSP_SESSION=api-auth",
+		"TSP_PR_NUMBER=42",
+		"TSP_FROM=pending",
+		"TSP_TO=fail",
+		"TSP_DIR=/src/api",
+		"TSP_WORKTREE=/src/api-auth",
+		"TSP_BRANCH=feat/auth",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env is missing %s: %v", want, env)
+		}
+	}
+
+	// A removed session is gone from the monitor; the event carries the
+	// worktree.
+	env = HookEnv(CleanupCompletedEvent{Session: "x", WorktreePath: "/wt/x", Branch: "b"}, nil)
+	for _, want := range []string{"TSP_WORKTREE=/wt/x", "TSP_WORKTREE_PATH=/wt/x", "TSP_BRANCH=b"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env is missing %s: %v", want, env)
+		}
+	}
+}
+
+func TestHookRunner(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "hooks.log")
+	out := filepath.Join(dir, "out")
+	h, err := NewHookRunner(config.HooksConfig{TimeoutS: 10, Concurrency: 1, On: []config.HookConfig{
+		{Event: "pr.merged", Run: `sleep 0.2; echo "first $TSP_SESSION $TSP_PR_NUMBER" >> ` + out},
+		{Event: "pr.merged", Run: `echo second >> ` + out + `; echo to the log; exit 3`},
+		{Event: "agent.waiting", Run: `echo never >> ` + out},
+	}}, logPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.HandleEvent(PRMergedEvent{Session: "api-auth", PRNumber: 7})
+	h.wg.Wait()
+
+	// With one command at a time the second waits for the first.
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != "first api-auth 7\nsecond\n" {
+		t.Errorf("output = %q", got)
+	}
+	logData, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(logData)
+	if !strings.Contains(log, "pr.merged api-auth: sleep 0.2") || !strings.Contains(log, "(ok in ") {
+		t.Errorf("log is missing the first command:\n%s", log)
+	}
+	if !strings.Contains(log, "(exit status 3 in ") || !strings.Contains(log, "  | to the log\n") {
+		t.Errorf("log is missing the failed command and its output:\n%s", log)
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "hooks.log")
+	h, err := NewHookRunner(config.HooksConfig{On: []config.HookConfig{
+		// The background sleep keeps stdout open; it must be killed too.
+		{Event: "pr.merged", Run: "sleep 30 & sleep 30"},
+	}}, logPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	h.HandleEvent(PRMergedEvent{Session: "a"})
+	h.wg.Wait()
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("hook ran for %s", d)
+	}
+	data, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(data), "timed out after 100ms") {
+		t.Errorf("log = %q", data)
+	}
+}
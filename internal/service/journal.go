@@ -0,0 +1,362 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+)
+
+// JournalEntry is one event in the journal. Seq increases by one per event
+// and survives restarts, so a client can ask for everything after the last
+// entry it saw.
+type JournalEntry struct {
+	Seq     uint64          `json:"seq"`
+	Time    time.Time       `json:"ts"`
+	Type    string          `json:"type"`
+	Session string          `json:"session,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// EventFilter selects events by type and session. Empty fields match
+// everything.
+type EventFilter struct {
+	Types   []string
+	Session string // a session name or glob, e.g. "api-*"
+}
+
+// Match reports whether an event of the given type about the given session
+// passes the filter.
+func (f EventFilter) Match(typ, session string) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, typ) {
+		return false
+	}
+	if f.Session == "" || f.Session == session {
+		return true
+	}
+	ok, _ := path.Match(f.Session, session)
+	return ok
+}
+
+// journalDay is the layout of the date in journal file names.
+const journalDay = "2006-01-02"
+
+// journalRecent is how many of the latest entries the journal keeps in
+// memory, so Tail rarely reads the files.
+const journalRecent = 1000
+
+// Journal appends every event on the bus to a JSONL file per day, so what
+// happened (and why the watcher acted) can be looked up, and replayed by
+// clients that missed it.
+type Journal struct {
+	dir           string
+	retentionDays int
+	bus           *Bus
+	unsub         UnsubscribeFunc
+
+	mu        sync.Mutex
+	seq       uint64         // of the last entry written
+	day       string         // of the file last written to
+	recent    []JournalEntry // the latest entries, oldest first
+	truncated bool           // older entries than recent are on disk
+
+	subMu       sync.Mutex
+	subscribers []chan JournalEntry
+}
+
+// EventsDir returns the directory the event journal is kept in
+// (~/.tsp/events).
+func EventsDir() string {
+	return filepath.Join(config.TspDir(), "events")
+}
+
+// NewJournal creates a journal that writes under dir (normally
+// EventsDir()), deleting files older than retentionDays (none if it is
+// negative or zero). Sequence numbers continue from the last one written,
+// which is kept apart from the files so it survives their deletion.
+func NewJournal(dir string, retentionDays int, bus *Bus) *Journal {
+	j := &Journal{dir: dir, retentionDays: retentionDays, bus: bus}
+	if data, err := os.ReadFile(j.seqPath()); err == nil {
+		j.seq, _ = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	}
+	files := j.files()
+	for i := len(files) - 1; i >= 0; i-- {
+		if len(j.recent) >= journalRecent {
+			j.truncated = true
+			break
+		}
+		var entries []JournalEntry
+		scanJournal(files[i], func(e JournalEntry) bool {
+			entries = append(entries, e)
+			j.seq = max(j.seq, e.Seq)
+			return true
+		})
+		j.recent = append(entries, j.recent...)
+	}
+	if n := len(j.recent); n > journalRecent {
+		j.recent, j.truncated = j.recent[n-journalRecent:], true
+	}
+	return j
+}
+
+// Start subscribes to the bus. Start it before the monitor so the first
+// events are journaled. Publishers wait for the journal rather than have
+// it drop events, which would leave gaps clients resuming from a sequence
+// number can't see.
+func (j *Journal) Start() {
+	j.unsub = j.bus.SubscribeWith(j.HandleEvent, Block)
+}
+
+// Stop unsubscribes from the bus and closes the subscriber channels.
+func (j *Journal) Stop() {
+	if j.unsub != nil {
+		j.unsub()
+	}
+	j.subMu.Lock()
+	defer j.subMu.Unlock()
+	for _, ch := range j.subscribers {
+		close(ch)
+	}
+	j.subscribers = nil
+}
+
+// HandleEvent journals an event.
+func (j *Journal) HandleEvent(e Event) {
+	if _, err := j.Append(e, time.Now()); err != nil {
+		log.Printf("[journal] %s: %v", e.EventType(), err)
+	}
+}
+
+// Append journals an event as happening at now and returns its entry.
+func (j *Journal) Append(e Event, now time.Time) (JournalEntry, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry := JournalEntry{Seq: j.seq + 1, Time: now, Type: e.EventType(), Session: EventSession(e), Data: data}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return JournalEntry{}, err
+	}
+	day := now.Local().Format(journalDay)
+	if day != j.day {
+		j.day = day
+		j.prune(now)
+	}
+	f, err := os.OpenFile(j.path(day), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return JournalEntry{}, err
+	}
+	if err := f.Close(); err != nil {
+		return JournalEntry{}, err
+	}
+	j.seq = entry.Seq
+	if err := writeFileAtomic(j.seqPath(), []byte(strconv.FormatUint(j.seq, 10)+"\n")); err != nil {
+		log.Printf("[journal] %v", err)
+	}
+	j.recent = append(j.recent, entry)
+	if len(j.recent) > journalRecent {
+		j.recent[0] = JournalEntry{}
+		j.recent, j.truncated = j.recent[1:], true
+	}
+	j.broadcast(entry)
+	return entry, nil
+}
+
+// Subscribe returns a channel that receives every entry as it is written.
+// A subscriber that falls behind misses entries rather than holding up the
+// journal; the gap in Seq tells it to catch up with Entries.
+func (j *Journal) Subscribe() chan JournalEntry {
+	ch := make(chan JournalEntry, 64)
+	j.subMu.Lock()
+	j.subscribers = append(j.subscribers, ch)
+	j.subMu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes a subscriber channel.
+func (j *Journal) Unsubscribe(ch chan JournalEntry) {
+	j.subMu.Lock()
+	defer j.subMu.Unlock()
+	for i, sub := range j.subscribers {
+		if sub == ch {
+			j.subscribers = append(j.subscribers[:i], j.subscribers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+func (j *Journal) broadcast(e JournalEntry) {
+	j.subMu.Lock()
+	defer j.subMu.Unlock()
+	for _, ch := range j.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// LastSeq returns the sequence number of the last entry written.
+func (j *Journal) LastSeq() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq
+}
+
+// Entries returns the entries after seq that match f, oldest first, and at
+// most limit of them unless limit is zero.
+func (j *Journal) Entries(since uint64, f EventFilter, limit int) ([]JournalEntry, error) {
+	files := j.files()
+	// Skip the files that end before since.
+	start := 0
+	for i := len(files) - 1; i > 0; i-- {
+		var first uint64
+		scanJournal(files[i], func(e JournalEntry) bool {
+			first = e.Seq
+			return false
+		})
+		if first != 0 && first <= since {
+			start = i
+			break
+		}
+	}
+
+	out := []JournalEntry{}
+	for _, file := range files[start:] {
+		err := scanJournal(file, func(e JournalEntry) bool {
+			if e.Seq > since && f.Match(e.Type, e.Session) {
+				out = append(out, e)
+			}
+			return limit == 0 || len(out) < limit
+		})
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// Tail returns the last n entries that match f, oldest first. They come
+// from memory unless fewer than n of the recent entries match.
+func (j *Journal) Tail(n int, f EventFilter) ([]JournalEntry, error) {
+	j.mu.Lock()
+	out, truncated := tailEntries(j.recent, n, f), j.truncated
+	j.mu.Unlock()
+	if len(out) >= n || !truncated {
+		return out, nil
+	}
+
+	// Read the files from the newest, until enough entries match.
+	out = []JournalEntry{}
+	files := j.files()
+	for i := len(files) - 1; i >= 0 && len(out) < n; i-- {
+		var entries []JournalEntry
+		err := scanJournal(files[i], func(e JournalEntry) bool {
+			entries = append(entries, e)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		out = append(tailEntries(entries, n-len(out), f), out...)
+	}
+	return out, nil
+}
+
+// tailEntries returns the last n of entries that match f, oldest first.
+func tailEntries(entries []JournalEntry, n int, f EventFilter) []JournalEntry {
+	out := []JournalEntry{}
+	for i := len(entries) - 1; i >= 0 && len(out) < n; i-- {
+		if f.Match(entries[i].Type, entries[i].Session) {
+			out = append(out, entries[i])
+		}
+	}
+	slices.Reverse(out)
+	return out
+}
+
+// seqPath returns the file the last sequence number is kept in.
+func (j *Journal) seqPath() string {
+	return filepath.Join(j.dir, "seq")
+}
+
+// path returns the journal file of a day.
+func (j *Journal) path(day string) string {
+	return filepath.Join(j.dir, "events-"+day+".jsonl")
+}
+
+// files returns the journal files, oldest first.
+func (j *Journal) files() []string {
+	files, _ := filepath.Glob(filepath.Join(j.dir, "events-*.jsonl"))
+	slices.Sort(files)
+	return files
+}
+
+// prune deletes the files older than the retention period. Callers must
+// hold j.mu.
+func (j *Journal) prune(now time.Time) {
+	if j.retentionDays <= 0 {
+		return
+	}
+	cutoff := j.path(now.Local().AddDate(0, 0, -j.retentionDays).Format(journalDay))
+	for _, file := range j.files() {
+		if file < cutoff {
+			if err := os.Remove(file); err != nil {
+				log.Printf("[journal] %v", err)
+			}
+		}
+	}
+}
+
+// scanJournal calls fn on every entry of a journal file until it returns
+// false. Lines that don't parse, such as one still being written, are
+// skipped.
+func scanJournal(file string, fn func(JournalEntry) bool) error {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		var e JournalEntry
+		if line == "" || json.Unmarshal([]byte(line), &e) != nil || e.Seq == 0 {
+			continue
+		}
+		if !fn(e) {
+			return nil
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	dir := t.TempDir()
+	j := NewJournal(dir, 0, nil)
+	day1 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+
+	j.Append(StatusChangedEvent{Session: "a", From: "active", To: "done"}, day1)
+	j.Append(CIStatusChangedEvent{Session: "b", PRNumber: 7, To: "fail"}, day1)
+	e, err := j.Append(FixAttemptedEvent{Session: "b", FixType: "ci", Attempt: 1}, day2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Seq != 3 || e.Type != "fix.attempted" || e.Session != "b" {
+		t.Errorf("entry = %+v", e)
+	}
+	var fix FixAttemptedEvent
+	if err := json.Unmarshal(e.Data, &fix); err != nil || fix.FixType != "ci" {
+		t.Errorf("data = %s (%v)", e.Data, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl")); len(files) != 2 {
+		t.Errorf("files = %v, want one per day", files)
+	}
+
+	// Sequence numbers continue after a restart.
+	j = NewJournal(dir, 0, nil)
+	if j.LastSeq() != 3 {
+		t.Fatalf("LastSeq after reopening = %d, want 3", j.LastSeq())
+	}
+	j.Append(SessionRemovedEvent{Name: "a"}, day2)
+
+	seqs := func(entries []JournalEntry, err error) []uint64 {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []uint64
+		for _, e := range entries {
+			out = append(out, e.Seq)
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		got  []uint64
+		want []uint64
+	}{
+		{"since 0", seqs(j.Entries(0, EventFilter{}, 0)), []uint64{1, 2, 3, 4}},
+		{"since 2", seqs(j.Entries(2, EventFilter{}, 0)), []uint64{3, 4}},
+		{"since 3", seqs(j.Entries(3, EventFilter{}, 0)), []uint64{4}},
+		{"limit", seqs(j.Entries(0, EventFilter{}, 2)), []uint64{1, 2}},
+		{"session", seqs(j.Entries(0, EventFilter{Session: "a"}, 0)), []uint64{1, 4}},
+		{"session glob", seqs(j.Entries(0, EventFilter{Session: "[b-z]*"}, 0)), []uint64{2, 3}},
+		{"type", seqs(j.Entries(1, EventFilter{Types: []string{"ci.status.changed", "fix.attempted"}}, 0)), []uint64{2, 3}},
+		{"tail", seqs(j.Tail(3, EventFilter{})), []uint64{2, 3, 4}},
+		{"tail session", seqs(j.Tail(1, EventFilter{Session: "b"})), []uint64{3}},
+	}
+	for _, tt := range tests {
+		if len(tt.got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+			continue
+		}
+		for i := range tt.got {
+			if tt.got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestJournalTailFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	j := NewJournal(dir, 0, nil)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	for _, name := range []string{"a", "b", "a", "b"} {
+		j.Append(SessionCreatedEvent{Name: name}, now)
+	}
+	// Only the newest entry is still in memory.
+	j.recent, j.truncated = j.recent[3:], true
+	got, err := j.Tail(2, EventFilter{Session: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Seq != 1 || got[1].Seq != 3 {
+		t.Errorf("Tail = %+v, want seqs 1 and 3", got)
+	}
+}
+
+func TestJournalSeqSurvivesRetention(t *testing.T) {
+	dir := t.TempDir()
+	j := NewJournal(dir, 0, nil)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	j.Append(SessionCreatedEvent{Name: "a"}, now)
+	j.Append(SessionCreatedEvent{Name: "b"}, now)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	for _, f := range files {
+		os.Remove(f)
+	}
+	j = NewJournal(dir, 0, nil)
+	if j.LastSeq() != 2 {
+		t.Fatalf("LastSeq with every file deleted = %d, want 2", j.LastSeq())
+	}
+	if e, _ := j.Append(SessionCreatedEvent{Name: "c"}, now); e.Seq != 3 {
+		t.Errorf("next seq = %d, want 3", e.Seq)
+	}
+}
+
+func TestJournalRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.Local)
+	for _, day := range []string{"2026-03-01", "2026-03-13", "2026-03-19"} {
+		os.WriteFile(filepath.Join(dir, "events-"+day+".jsonl"), nil, 0600)
+	}
+	j := NewJournal(dir, 7, nil)
+	if _, err := j.Append(SessionCreatedEvent{Name: "a"}, now); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	want := []string{"events-2026-03-13.jsonl", "events-2026-03-19.jsonl", "events-2026-03-20.jsonl"}
+	if len(files) != len(want) {
+		t.Fatalf("files = %v, want %v", files, want)
+	}
+	for i, f := range files {
+		if filepath.Base(f) != want[i] {
+			t.Errorf("files = %v, want %v", files, want)
+		}
+	}
+}
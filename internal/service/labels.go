@@ -0,0 +1,278 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+// LabelKey returns the key of a label: the part before the first ':', or
+// the whole label when there is none.
+func LabelKey(label string) string {
+	key, _, _ := strings.Cut(label, ":")
+	return key
+}
+
+// ValidateLabel reports whether a label can be stored: it must be non-empty
+// and contain no whitespace or commas (which separate selectors).
+func ValidateLabel(label string) error {
+	if label == "" || strings.HasPrefix(label, ":") {
+		return fmt.Errorf("invalid label %q: empty key", label)
+	}
+	if strings.ContainsAny(label, " \t\n,") {
+		return fmt.Errorf("invalid label %q: no spaces or commas allowed", label)
+	}
+	return nil
+}
+
+// MatchLabels reports whether labels satisfy every selector. A selector
+// with a ':' matches that exact label; one without matches any label with
+// that key, e.g. "sprint" matches "sprint:42". Selectors may be given
+// comma-separated.
+func MatchLabels(labels []string, selectors ...string) bool {
+	for _, sel := range selectors {
+		for _, s := range strings.Split(sel, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			if !slices.ContainsFunc(labels, func(l string) bool {
+				return l == s || (!strings.Contains(s, ":") && LabelKey(l) == s)
+			}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// LabelGroup returns the value of a session's label with the given key: ""
+// if it has none, and the key itself for a label without a value.
+func LabelGroup(labels []string, key string) string {
+	for _, l := range labels {
+		if k, v, ok := strings.Cut(l, ":"); k == key {
+			if !ok {
+				return k
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// LabelStore keeps the labels of every session, free-form tags such as
+// "repo:api", "sprint:42" or "urgent", persisted to a JSON file so they
+// survive restarts. Labels belong to session names and are deleted when
+// the session is killed or ends, so a new session reusing the name starts
+// without them.
+type LabelStore struct {
+	path string
+
+	mu       sync.Mutex
+	sessions map[string][]string
+	modTime  time.Time // of the file when last read or written
+	bad      error     // why the file couldn't be parsed; it isn't overwritten
+}
+
+// LabelsPath returns the file session labels are kept in
+// (~/.tsp/labels.json).
+func LabelsPath() string {
+	return filepath.Join(config.TspDir(), "labels.json")
+}
+
+// NewLabelStore creates a store backed by the JSON file at path. The file
+// does not need to exist yet.
+func NewLabelStore(path string) *LabelStore {
+	s := &LabelStore{path: path, sessions: make(map[string][]string)}
+	s.reload()
+	return s
+}
+
+// reload re-reads the file if another process (tsp label, tsp spawn)
+// changed it since it was last read or written. Callers must hold s.mu,
+// except in NewLabelStore.
+func (s *LabelStore) reload() {
+	info, err := os.Stat(s.path)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+	s.modTime = info.ModTime()
+	var file struct {
+		Sessions map[string][]string `json:"sessions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("[labels] %s: %v; not saving labels until it is fixed", s.path, err)
+		s.bad = err
+		return
+	}
+	s.sessions, s.bad = file.Sessions, nil
+	if s.sessions == nil {
+		s.sessions = make(map[string][]string)
+	}
+}
+
+func (s *LabelStore) write() error {
+	if s.bad != nil {
+		return fmt.Errorf("%s is invalid, not overwriting it: %w", s.path, s.bad)
+	}
+	data, err := json.MarshalIndent(map[string]any{"sessions": s.sessions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Labels returns a session's labels, sorted.
+func (s *LabelStore) Labels(session string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	return slices.Clone(s.sessions[session])
+}
+
+// All returns the labels of every labelled session.
+func (s *LabelStore) All() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	out := make(map[string][]string, len(s.sessions))
+	for name, labels := range s.sessions {
+		out[name] = slices.Clone(labels)
+	}
+	return out
+}
+
+// Set replaces a session's labels. No labels removes the session.
+func (s *LabelStore) Set(session string, labels []string) error {
+	for _, l := range labels {
+		if err := ValidateLabel(l); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	s.set(session, labels)
+	return s.write()
+}
+
+// Add adds labels to a session. A label with the same key and a different
+// value is replaced, so "sprint:43" moves a session out of "sprint:42".
+func (s *LabelStore) Add(session string, labels ...string) error {
+	for _, l := range labels {
+		if err := ValidateLabel(l); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	cur := slices.Clone(s.sessions[session])
+	for _, l := range labels {
+		key := LabelKey(l)
+		cur = slices.DeleteFunc(cur, func(c string) bool {
+			return strings.Contains(l, ":") && strings.Contains(c, ":") && LabelKey(c) == key
+		})
+		cur = append(cur, l)
+	}
+	s.set(session, cur)
+	return s.write()
+}
+
+// Remove removes labels from a session. A label without a value removes
+// every label with that key.
+func (s *LabelStore) Remove(session string, labels ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	cur := slices.DeleteFunc(slices.Clone(s.sessions[session]), func(c string) bool {
+		return slices.ContainsFunc(labels, func(l string) bool { return MatchLabels([]string{c}, l) })
+	})
+	s.set(session, cur)
+	return s.write()
+}
+
+// Delete removes every label of a session.
+func (s *LabelStore) Delete(session string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	if _, ok := s.sessions[session]; !ok {
+		return nil
+	}
+	delete(s.sessions, session)
+	return s.write()
+}
+
+// ForgetSession deletes the labels and settings of a session killed on srv,
+// so a new session reusing the name starts without them. They are kept
+// while another of servers still has a session by that name. Either store
+// may be nil.
+func ForgetSession(labels *LabelStore, settings *SettingsStore, srv tmuxpkg.Server, name string, servers []tmuxpkg.Server) error {
+	for _, other := range servers {
+		if other.Name == srv.Name {
+			continue
+		}
+		if ok, _ := other.HasSession(name); ok {
+			return nil
+		}
+	}
+	if labels != nil {
+		if err := labels.Delete(name); err != nil {
+			return err
+		}
+	}
+	if settings != nil {
+		return settings.DeleteSession(name)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary
+// file, so a crash mid-write never leaves it truncated.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// set stores labels sorted and without duplicates. Callers must hold s.mu.
+func (s *LabelStore) set(session string, labels []string) {
+	labels = slices.Compact(slices.Sorted(slices.Values(labels)))
+	if len(labels) == 0 {
+		delete(s.sessions, session)
+		return
+	}
+	s.sessions[session] = labels
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+func TestMatchLabels(t *testing.T) {
+	labels := []string{"owner:alice", "repo:api", "sprint:42", "urgent"}
+	tests := []struct {
+		selectors []string
+		want      bool
+	}{
+		{nil, true},
+		{[]string{"repo:api"}, true},
+		{[]string{"repo:web"}, false},
+		{[]string{"sprint"}, true}, // key only
+		{[]string{"urgent"}, true},
+		{[]string{"repo:api,sprint:42"}, true},
+		{[]string{"repo:api,sprint:41"}, false},
+		{[]string{"repo:api", "owner:bob"}, false},
+		{[]string{"alice"}, false},
+	}
+	for _, tt := range tests {
+		if got := MatchLabels(labels, tt.selectors...); got != tt.want {
+			t.Errorf("MatchLabels(%v) = %v, want %v", tt.selectors, got, tt.want)
+		}
+	}
+
+	if got := LabelGroup(labels, "sprint"); got != "42" {
+		t.Errorf("LabelGroup(sprint) = %q, want 42", got)
+	}
+	if got := LabelGroup(labels, "urgent"); got != "urgent" {
+		t.Errorf("LabelGroup(urgent) = %q, want urgent", got)
+	}
+	if got := LabelGroup(labels, "team"); got != "" {
+		t.Errorf("LabelGroup(team) = %q, want empty", got)
+	}
+}
+
+func TestLabelStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.json")
+	s := NewLabelStore(path)
+
+	if err := s.Add("app-feat", "sprint:42", "repo:api", "urgent", "urgent"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Labels("app-feat"), []string{"repo:api", "sprint:42", "urgent"}; !slices.Equal(got, want) {
+		t.Errorf("Labels = %v, want %v", got, want)
+	}
+	// A new value for a key replaces the old one.
+	s.Add("app-feat", "sprint:43")
+	if got, want := s.Labels("app-feat"), []string{"repo:api", "sprint:43", "urgent"}; !slices.Equal(got, want) {
+		t.Errorf("after re-adding sprint, Labels = %v, want %v", got, want)
+	}
+	if err := s.Add("app-feat", "two words"); err == nil {
+		t.Error("expected a label with a space to be rejected")
+	}
+
+	// Changes made by another process are picked up.
+	if got := NewLabelStore(path).Labels("app-feat"); len(got) != 3 {
+		t.Errorf("reloaded Labels = %v, want 3 labels", got)
+	}
+
+	// Removing a key removes its labels, whatever their value.
+	s.Remove("app-feat", "sprint", "urgent")
+	if got, want := s.Labels("app-feat"), []string{"repo:api"}; !slices.Equal(got, want) {
+		t.Errorf("after Remove, Labels = %v, want %v", got, want)
+	}
+	s.Set("app-feat", nil)
+	if _, ok := s.All()["app-feat"]; ok {
+		t.Error("a session without labels should be dropped")
+	}
+
+	s.Add("app-fix", "urgent")
+	if err := s.Delete("app-fix"); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewLabelStore(path).Labels("app-fix"); len(got) != 0 {
+		t.Errorf("deleted session still has labels %v", got)
+	}
+}
+
+func TestLabelStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.json")
+	os.WriteFile(path, []byte(`{"sessions": {"app-feat": ["urgent"]},`), 0600)
+	s := NewLabelStore(path)
+	if err := s.Add("app-fix", "sprint:42"); err == nil {
+		t.Error("expected an error saving over an invalid file")
+	}
+	if data, _ := os.ReadFile(path); !strings.HasSuffix(string(data), ",") {
+		t.Errorf("invalid file was overwritten: %s", data)
+	}
+}
+
+// startTmuxServer starts a tmux server on a private socket with a session
+// named keep, so it stays up while tests kill their other sessions.
+func startTmuxServer(t *testing.T) tmuxpkg.Server {
+	t.Helper()
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	srv := tmuxpkg.Server{Name: "test", SocketPath: filepath.Join(t.TempDir(), "tmux")}
+	if out, err := srv.Command("new-session", "-d", "-s", "keep").CombinedOutput(); err != nil {
+		t.Skipf("can't start tmux: %v: %s", err, out)
+	}
+	t.Cleanup(func() { srv.Command("kill-server").Run() })
+	return srv
+}
+
+func TestMonitorForgetsLabels(t *testing.T) {
+	live := startTmuxServer(t)
+	labels := NewLabelStore(filepath.Join(t.TempDir(), "labels.json"))
+	labels.Add("gone", "sprint:42")
+	labels.Add("moved", "sprint:42")
+	labels.Add("keep", "sprint:42")
+	labels.Add("crashed", "sprint:42")
+	settings := NewSettingsStore(filepath.Join(t.TempDir(), "session-settings.json"), config.DashConfig{})
+	settings.SetBudget("gone", Budget{USD: 5})
+	settings.SetBudget("crashed", Budget{USD: 5})
+	m := NewMonitor(500, nil, "", nil, NewBus())
+	m.SetLabels(labels)
+	m.SetSettings(settings)
+	// "moved" ended on one server but still runs on another.
+	m.sessions = []Session{{Name: "moved", Server: tmuxpkg.Server{Name: "b"}}}
+
+	dead := tmuxpkg.Server{Name: "a", SocketPath: filepath.Join(t.TempDir(), "no-server")}
+	m.forgetSessions([]Session{
+		{Name: "gone", Server: live},
+		{Name: "moved", Server: live},
+		{Name: "keep", Server: live},    // only missing from a failed listing
+		{Name: "crashed", Server: dead}, // its server may come back
+	})
+	if got := labels.Labels("gone"); len(got) != 0 {
+		t.Errorf("labels of an ended session = %v", got)
+	}
+	for _, name := range []string{"moved", "keep", "crashed"} {
+		if got := labels.Labels(name); len(got) != 1 {
+			t.Errorf("labels of %s = %v, want them kept", name, got)
+		}
+	}
+	if _, ok := settings.Budget("gone"); ok {
+		t.Error("budget of an ended session should be dropped")
+	}
+	if _, ok := settings.Budget("crashed"); !ok {
+		t.Error("budget of a session on a crashed server should be kept")
+	}
+}
+
+func TestForgetSession(t *testing.T) {
+	other := startTmuxServer(t)
+	labels := NewLabelStore(filepath.Join(t.TempDir(), "labels.json"))
+	labels.Add("keep", "sprint:42")
+	labels.Add("gone", "sprint:42")
+	settings := NewSettingsStore(filepath.Join(t.TempDir(), "session-settings.json"), config.DashConfig{})
+	settings.SetBudget("keep", Budget{USD: 5})
+	settings.SetBudget("gone", Budget{USD: 5})
+	killed := tmuxpkg.Server{Name: "a", SocketPath: filepath.Join(t.TempDir(), "no-server")}
+	servers := []tmuxpkg.Server{killed, other}
+
+	// "keep" still runs on the other server.
+	if err := ForgetSession(labels, settings, killed, "keep", servers); err != nil {
+		t.Fatal(err)
+	}
+	if got := labels.Labels("keep"); len(got) != 1 {
+		t.Errorf("labels of a session running elsewhere = %v", got)
+	}
+	if err := ForgetSession(labels, settings, killed, "gone", servers); err != nil {
+		t.Fatal(err)
+	}
+	if got := labels.Labels("gone"); len(got) != 0 {
+		t.Errorf("labels of a killed session = %v", got)
+	}
+	if _, ok := settings.Budget("keep"); !ok {
+		t.Error("budget of a session running elsewhere should be kept")
+	}
+	if _, ok := settings.Budget("gone"); ok {
+		t.Error("budget of a killed session should be dropped")
+	}
+}
+
+func TestSpawnLabels(t *testing.T) {
+	got := SpawnLabels("/work/My Repo", "fullstack")
+	want := []string{"spawned", "repo:My-Repo", "layout:fullstack"}
+	if !slices.Equal(got, want) {
+		t.Errorf("SpawnLabels = %v, want %v", got, want)
+	}
+	for _, l := range got {
+		if err := ValidateLabel(l); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+// ResolveLayout returns the named layout from config as a tmux layout. An
+// empty name falls back to spawn.layout, and then to the classic nvim + agent
+// split. Agent panes without a command of their own run spawn.agent_command.
+func ResolveLayout(cfg *config.Config, name string) (tmuxpkg.Layout, error) {
+	if name == "" {
+		name = cfg.Spawn.Layout
+	}
+	if name == "" {
+		return tmuxpkg.TwoPaneLayout("nvim", cfg.Spawn.AgentCommand), nil
+	}
+	l, ok := cfg.Layouts[name]
+	if !ok {
+		return tmuxpkg.Layout{}, fmt.Errorf("unknown layout %q (configured: %s)", name, strings.Join(layoutNames(cfg), ", "))
+	}
+
+	var layout tmuxpkg.Layout
+	for _, w := range l.Windows {
+		win := tmuxpkg.LayoutWindow{Name: w.Name, Arrange: w.Layout}
+		for _, p := range w.Panes {
+			cmd := p.Command
+			if p.Agent && cmd == "" {
+				cmd = cfg.Spawn.AgentCommand
+			}
+			win.Panes = append(win.Panes, tmuxpkg.LayoutPane{
+				Command: cmd,
+				Split:   p.Split,
+				Size:    p.Size,
+				Agent:   p.Agent,
+			})
+		}
+		layout.Windows = append(layout.Windows, win)
+	}
+	if err := layout.Validate(); err != nil {
+		return tmuxpkg.Layout{}, fmt.Errorf("layout %q: %w", name, err)
+	}
+	return layout, nil
+}
+
+// layoutNames returns the names of the configured layouts, sorted.
+func layoutNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(cfg.Layouts))
+	for name := range cfg.Layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withAgentTask returns a copy of layout whose agent pane command has task
+// appended as a quoted CLI argument, so the agent starts on it immediately.
+func withAgentTask(layout tmuxpkg.Layout, task string) tmuxpkg.Layout {
+	out := tmuxpkg.Layout{Windows: make([]tmuxpkg.LayoutWindow, len(layout.Windows))}
+	for wi, w := range layout.Windows {
+		w.Panes = append([]tmuxpkg.LayoutPane(nil), w.Panes...)
+		for pi := range w.Panes {
+			if w.Panes[pi].Agent {
+				w.Panes[pi].Command += " " + shellQuote(task)
+			}
+		}
+		out.Windows[wi] = w
+	}
+	return out
+}
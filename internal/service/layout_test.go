@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+)
+
+func TestResolveLayout(t *testing.T) {
+	cfg := &config.Config{
+		Spawn: config.SpawnConfig{AgentCommand: "claude"},
+		Layouts: map[string]config.Layout{
+			"fullstack": {Windows: []config.LayoutWindow{
+				{Name: "main", Layout: "main-vertical", Panes: []config.LayoutPane{
+					{Agent: true},
+					{Command: "npm run dev", Split: "h", Size: "40%"},
+					{Command: "npm test -- --watch", Split: "v"},
+				}},
+				{Name: "shell", Panes: []config.LayoutPane{{}}},
+			}},
+			"broken": {},
+		},
+	}
+
+	def, err := ResolveLayout(cfg, "")
+	if err != nil {
+		t.Fatalf("default layout: unexpected error: %v", err)
+	}
+	if len(def.Windows) != 1 || len(def.Windows[0].Panes) != 2 || def.Windows[0].Panes[1].Command != "claude" {
+		t.Errorf("default layout = %+v, want nvim + claude", def)
+	}
+
+	l, err := ResolveLayout(cfg, "fullstack")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(l.Windows) != 2 || l.Windows[0].Arrange != "main-vertical" {
+		t.Fatalf("unexpected layout: %+v", l)
+	}
+	if p := l.Windows[0].Panes[0]; !p.Agent || p.Command != "claude" {
+		t.Errorf("agent pane = %+v, want agent running claude", p)
+	}
+	if p := l.Windows[0].Panes[1]; p.Size != "40%" || p.Command != "npm run dev" {
+		t.Errorf("dev pane = %+v", p)
+	}
+
+	cfg.Spawn.Layout = "fullstack"
+	if l, _ := ResolveLayout(cfg, ""); len(l.Windows) != 2 {
+		t.Errorf("expected spawn.layout to be used as the default, got %+v", l)
+	}
+
+	if _, err := ResolveLayout(cfg, "missing"); err == nil {
+		t.Error("expected error for unknown layout")
+	}
+	if _, err := ResolveLayout(cfg, "broken"); err == nil {
+		t.Error("expected error for layout without windows")
+	}
+}
+
+func TestWithAgentTask(t *testing.T) {
+	cfg := &config.Config{Spawn: config.SpawnConfig{AgentCommand: "claude"}}
+	layout, _ := ResolveLayout(cfg, "")
+
+	got := withAgentTask(layout, "fix it's bug")
+	if cmd := got.Windows[0].Panes[1].Command; cmd != `claude 'fix it'\''s bug'` {
+		t.Errorf("agent command = %q", cmd)
+	}
+	if cmd := layout.Windows[0].Panes[1].Command; cmd != "claude" {
+		t.Errorf("original layout modified: %q", cmd)
+	}
+}
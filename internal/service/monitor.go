@@ -1,10 +1,22 @@
 package service
 
 import (
+	"cmp"
+	"log"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 )
 
+// controlRetryInterval is the minimum delay between attempts to (re)open the
+// tmux control-mode connection after it fails or exits.
+const controlRetryInterval = 30 * time.Second
+
 // Monitor continuously polls tmux sessions and maintains their state.
 type Monitor struct {
 	mu            sync.RWMutex
@@ -17,22 +29,180 @@ type Monitor struct {
 	subMu         sync.Mutex
 	stopCh        chan struct{}
 	bus           *Bus
+	recorder      *Recorder
+	detectors     *Detectors
+	settings      *SettingsStore                     // nil uses DefaultThresholds
+	labels        *LabelStore                        // nil leaves sessions unlabelled
+	agentLogs     map[string]*agentlog.StatusTracker // by pane key; only touched from poll
+	sched         *scheduler
+	wakeCh        chan struct{} // nudges the loop when a schedule was reset
+	unsub         UnsubscribeFunc
+
+	// Resource sampling; nil when off or unsupported. Only touched from poll.
+	resources   *ResourceSampler
+	resourceCfg config.ResourcesConfig
+	lastSample  time.Time
+	overLimit   map[string]bool // session key + resource, while over its limit
+
+	// Token usage; nil when off. Only touched from poll.
+	usage     *UsageCache
+	lastUsage time.Time
+	budgetHit map[string]float64 // session key -> budget already reported exceeded
+
+	// Per-server control-mode state. Only touched from the loop goroutine
+	// (servers itself is fixed once Start is called).
+	servers   []*serverConn
+	controlCh chan controlMessage
+}
+
+// serverConn is the Monitor's state for one tmux server.
+type serverConn struct {
+	server   tmuxpkg.Server
+	control  *tmuxpkg.ControlClient
+	attached string          // session the control client is attached to
+	tried    time.Time       // last connection attempt
+	dirty    map[string]bool // pane IDs with %output since their last capture
+}
+
+// controlMessage is a control-mode notification forwarded to the loop,
+// tagged with the connection it came from. closed reports that the
+// connection exited, overflow that notifications were dropped.
+type controlMessage struct {
+	conn     *serverConn
+	client   *tmuxpkg.ControlClient
+	n        tmuxpkg.ControlNotification
+	closed   bool
+	overflow bool
 }
 
 func NewMonitor(refreshMs int, errorPatterns []string, promptPattern string, inputPatterns []string, bus *Bus) *Monitor {
-	return &Monitor{
+	m := &Monitor{
 		refreshMs:     refreshMs,
 		errorPatterns: errorPatterns,
 		promptPattern: promptPattern,
 		inputPatterns: inputPatterns,
 		stopCh:        make(chan struct{}),
 		bus:           bus,
+		controlCh:     make(chan controlMessage, 256),
+		agentLogs:     make(map[string]*agentlog.StatusTracker),
+		sched:         newScheduler(time.Duration(refreshMs) * time.Millisecond),
+		wakeCh:        make(chan struct{}, 1),
+		detectors: NewDetectors(config.DashConfig{
+			ErrorPatterns: errorPatterns,
+			PromptPattern: promptPattern,
+			InputPatterns: inputPatterns,
+		}),
+	}
+	m.SetServers([]tmuxpkg.Server{tmuxpkg.DefaultServer()})
+	return m
+}
+
+// SetServers sets the tmux servers to monitor. Defaults to the default
+// server. Must be called before Start.
+func (m *Monitor) SetServers(servers []tmuxpkg.Server) {
+	m.servers = nil
+	for _, srv := range servers {
+		m.servers = append(m.servers, &serverConn{server: srv.Named(), dirty: make(map[string]bool)})
+	}
+}
+
+// SetRecorder makes the monitor start recording panes as it discovers them.
+// Must be called before Start.
+func (m *Monitor) SetRecorder(r *Recorder) {
+	m.recorder = r
+}
+
+// SetDetectors replaces the status detectors built from the patterns given
+// to NewMonitor, e.g. with NewDetectors(cfg.Dash) to add the detectors from
+// config. Must be called before Start.
+func (m *Monitor) SetDetectors(d *Detectors) {
+	m.detectors = d
+}
+
+// SetSettings makes the monitor take status thresholds from a settings
+// store. Must be called before Start.
+func (m *Monitor) SetSettings(s *SettingsStore) {
+	m.settings = s
+}
+
+// SetLabels makes the monitor attach each session's labels from a label
+// store. Must be called before Start.
+func (m *Monitor) SetLabels(l *LabelStore) {
+	m.labels = l
+}
+
+// SetResources turns on CPU and memory sampling of pane process trees.
+// Sampling needs /proc, so on other systems it stays off. Must be called
+// before Start.
+func (m *Monitor) SetResources(cfg config.ResourcesConfig) {
+	s := NewResourceSampler()
+	if err := s.Sample(time.Now()); err != nil {
+		log.Printf("monitor: resource sampling unavailable: %v", err)
+		return
 	}
+	m.resources, m.resourceCfg, m.lastSample = s, cfg, time.Now()
+	m.overLimit = make(map[string]bool)
 }
 
-func (m *Monitor) Start() { go m.loop() }
+// SetUsage turns on token usage and cost tracking of the sessions' agents,
+// and enforcement of their budgets. Must be called before Start.
+func (m *Monitor) SetUsage(c *UsageCache) {
+	m.usage = c
+	m.budgetHit = make(map[string]float64)
+}
 
-func (m *Monitor) Stop() { close(m.stopCh) }
+// thresholds returns the status thresholds for a session.
+func (m *Monitor) thresholds(name, repo string) Thresholds {
+	if m.settings == nil {
+		return DefaultThresholds
+	}
+	return m.settings.Thresholds(name, repo)
+}
+
+// Servers returns the tmux servers being monitored.
+func (m *Monitor) Servers() []tmuxpkg.Server {
+	out := make([]tmuxpkg.Server, len(m.servers))
+	for i, sc := range m.servers {
+		out[i] = sc.server
+	}
+	return out
+}
+
+// Start begins polling. Bus events about a session reset its poll
+// interval, like Wake.
+func (m *Monitor) Start() {
+	m.unsub = m.bus.Subscribe(func(e Event) {
+		if name, ok := resetsSchedule(e); ok {
+			m.Wake("", name)
+		}
+	})
+	go m.loop()
+}
+
+func (m *Monitor) Stop() {
+	if m.unsub != nil {
+		m.unsub()
+	}
+	close(m.stopCh)
+}
+
+// Wake resets the poll interval of a session, e.g. after an API action on
+// it, so its changes show up at the fast rate again. An empty server
+// matches the session on any server.
+func (m *Monitor) Wake(server, name string) {
+	now := time.Now()
+	m.mu.RLock()
+	for _, s := range m.sessions {
+		if s.Name == name && (server == "" || s.Server.Name == server) {
+			m.sched.Reset(sessionKey(s.Server.Name, s.Name), now)
+		}
+	}
+	m.mu.RUnlock()
+	select {
+	case m.wakeCh <- struct{}{}:
+	default:
+	}
+}
 
 // Snapshot returns a copy of current session states.
 func (m *Monitor) Snapshot() []Session {
@@ -43,12 +213,20 @@ func (m *Monitor) Snapshot() []Session {
 	return cp
 }
 
-// FindSession returns a session by name, or nil.
+// FindSession returns a session by name, or nil. If servers have sessions
+// with the same name, the one on the first configured server wins; use
+// FindSessionOn to pick a server explicitly.
 func (m *Monitor) FindSession(name string) *Session {
+	return m.FindSessionOn("", name)
+}
+
+// FindSessionOn returns the session with the given name on the server with
+// the given label, or nil. An empty server matches any server.
+func (m *Monitor) FindSessionOn(server, name string) *Session {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	for i := range m.sessions {
-		if m.sessions[i].Name == name {
+		if m.sessions[i].Name == name && (server == "" || m.sessions[i].Server.Name == server) {
 			s := m.sessions[i]
 			return &s
 		}
@@ -78,165 +256,428 @@ func (m *Monitor) Unsubscribe(ch chan []Session) {
 	}
 }
 
+// loop polls whenever the next session falls due (see scheduler), and on
+// control-mode notifications that change the topology.
 func (m *Monitor) loop() {
-	ticker := time.NewTicker(time.Duration(m.refreshMs) * time.Millisecond)
-	defer ticker.Stop()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	defer func() {
+		for _, sc := range m.servers {
+			m.closeControl(sc)
+		}
+	}()
 	m.poll()
 	for {
+		timer.Reset(time.Until(m.sched.Next(time.Now())))
 		select {
 		case <-m.stopCh:
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			m.poll()
+		case <-m.wakeCh:
+			// A schedule was reset: recompute the timer.
+		case msg := <-m.controlCh:
+			// Ignore messages from a connection that has since been replaced.
+			if msg.client != msg.conn.control {
+				continue
+			}
+			if msg.closed {
+				m.closeControl(msg.conn)
+				continue
+			}
+			if msg.overflow {
+				m.markAllDirty(msg.conn)
+				m.poll()
+				continue
+			}
+			if m.handleControlNotification(msg.conn, msg.n) {
+				m.poll()
+			}
 		}
 	}
 }
 
+// ensureControl opens a control-mode connection to a server, attached to the
+// given session, if none is open. Failed attempts are retried at most every
+// controlRetryInterval so a tmux without control mode doesn't cost a process
+// spawn per tick.
+func (m *Monitor) ensureControl(sc *serverConn, session string) {
+	if sc.control != nil || time.Since(sc.tried) < controlRetryInterval {
+		return
+	}
+	sc.tried = time.Now()
+	c, err := sc.server.StartControlClient(session)
+	if err != nil {
+		log.Printf("monitor: control mode unavailable on %s, polling instead: %v", sc.server.Name, err)
+		return
+	}
+	sc.control = c
+	// Output may have been missed while disconnected; the %session-changed
+	// notification sent on attach marks the attached session's panes dirty.
+	sc.attached = ""
+	sc.dirty = make(map[string]bool)
+	go m.forwardControl(sc, c)
+}
+
+// forwardControl relays a connection's notifications and overflows to the
+// loop goroutine, followed by a closed message once the connection exits.
+func (m *Monitor) forwardControl(sc *serverConn, c *tmuxpkg.ControlClient) {
+	for {
+		msg := controlMessage{conn: sc, client: c}
+		select {
+		case n, ok := <-c.Notifications():
+			msg.n, msg.closed = n, !ok
+		case <-c.Overflow():
+			msg.overflow = true
+		case <-m.stopCh:
+			return
+		}
+		select {
+		case m.controlCh <- msg:
+		case <-m.stopCh:
+			return
+		}
+		if msg.closed {
+			return
+		}
+	}
+}
+
+// markAllDirty marks every known pane on a server for re-capture, after
+// dropped notifications left the monitor unable to tell which had output.
+func (m *Monitor) markAllDirty(sc *serverConn) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, s := range m.sessions {
+		if s.Server.Name != sc.server.Name {
+			continue
+		}
+		for _, p := range s.Panes {
+			if p.ID != "" {
+				sc.dirty[p.ID] = true
+			}
+		}
+	}
+}
+
+func (m *Monitor) closeControl(sc *serverConn) {
+	if sc.control == nil {
+		return
+	}
+	sc.control.Close()
+	sc.control = nil
+	sc.attached = ""
+}
+
+// handleControlNotification records pane output and reports whether the
+// notification changed the session/window topology and warrants an
+// immediate poll.
+func (m *Monitor) handleControlNotification(sc *serverConn, n tmuxpkg.ControlNotification) bool {
+	switch n.Type {
+	case "output":
+		sc.dirty[n.PaneID] = true
+		// Only the attached session's panes report output.
+		if sc.attached != "" {
+			m.sched.Reset(sessionKey(sc.server.Name, sc.attached), time.Now())
+		}
+		return false
+	case "session-changed":
+		// %session-changed $<id> <name>
+		if len(n.Args) >= 2 {
+			sc.attached = strings.Join(n.Args[1:], " ")
+			if s := m.FindSessionOn(sc.server.Name, sc.attached); s != nil {
+				for _, p := range s.Panes {
+					if p.ID != "" {
+						sc.dirty[p.ID] = true
+					}
+				}
+			}
+		}
+		return true
+	case "window-add", "window-close", "sessions-changed", "session-renamed", "unlinked-window-add":
+		return true
+	}
+	return false
+}
+
+// listAllPanes snapshots every pane on a server with a single list-panes
+// query, over the control connection when one is open.
+func (m *Monitor) listAllPanes(sc *serverConn) ([]tmuxpkg.PaneInfo, error) {
+	if sc.control != nil {
+		out, err := sc.control.Command(tmuxpkg.BuildListAllPanesArgs()...)
+		if err == nil {
+			return tmuxpkg.ParsePaneList(out), nil
+		}
+	}
+	return sc.server.ListAllPanes()
+}
+
+// capturePane returns a pane's visible content and when it was captured.
+// The previous capture is reused when the pane cannot have changed: with a
+// control connection, panes in the attached session are only re-captured
+// after tmux reports %output for them; elsewhere the window's activity
+// timestamp must be at or after the previous capture.
+func (m *Monitor) capturePane(sc *serverConn, info tmuxpkg.PaneInfo, prev *Pane) (string, time.Time) {
+	if prev != nil && !prev.CapturedAt.IsZero() {
+		if sc.control != nil && info.Session == sc.attached {
+			if !sc.dirty[info.PaneID] {
+				return prev.Content, prev.CapturedAt
+			}
+		} else if info.Activity.Before(prev.CapturedAt.Truncate(time.Second)) {
+			return prev.Content, prev.CapturedAt
+		}
+	}
+	now := time.Now()
+	if sc.control != nil {
+		out, err := sc.control.Command(tmuxpkg.BuildCapturePaneArgs(info.PaneID)...)
+		if err == nil {
+			delete(sc.dirty, info.PaneID)
+			// Match exec output, which ends every line with a newline.
+			if out != "" {
+				out += "\n"
+			}
+			return out, now
+		}
+	}
+	out, err := sc.server.Command(tmuxpkg.BuildCapturePaneArgs(info.PaneID)...).Output()
+	if err != nil {
+		return "", time.Time{}
+	}
+	return string(out), now
+}
+
 func (m *Monitor) poll() {
-	names, err := ListSessions()
-	if err != nil || len(names) == 0 {
+	// serverPanes is one server's pane snapshot, grouped by session.
+	type serverPanes struct {
+		conn      *serverConn
+		names     []string
+		bySession map[string][]tmuxpkg.PaneInfo
+	}
+	var listed []serverPanes
+	for _, sc := range m.servers {
+		all, err := m.listAllPanes(sc)
+		names, bySession := tmuxpkg.GroupPanesBySession(all)
+		if err != nil || len(names) == 0 {
+			continue
+		}
+		m.ensureControl(sc, names[0])
+		listed = append(listed, serverPanes{conn: sc, names: names, bySession: bySession})
+	}
+	if len(listed) == 0 {
 		m.mu.Lock()
 		m.sessions = nil
 		m.mu.Unlock()
+		m.sched.Prune(nil)
 		m.notify()
 		return
 	}
 	now := time.Now()
+
+	// The process table is loaded at most once per poll, and only when a
+	// shell pane or an uncached agent pane needs it.
+	var procs *processTable
+	getProcs := func() *processTable {
+		if procs == nil {
+			var err error
+			if procs, err = loadProcessTable(); err != nil {
+				procs = parseProcessTable("")
+			}
+		}
+		return procs
+	}
+
+	seenLogs := make(map[string]bool)
+	stuckAfter := make(map[string]time.Duration) // by session key
+	polled := make(map[string]bool)              // sessions re-captured this poll
+
 	m.mu.Lock()
 	existing := make(map[string]*Session)
 	for i := range m.sessions {
-		existing[m.sessions[i].Name] = &m.sessions[i]
+		existing[sessionKey(m.sessions[i].Server.Name, m.sessions[i].Name)] = &m.sessions[i]
 	}
 	var updated []Session
-	for _, name := range names {
-		paneCount := GetPaneCount(name)
-		var panes []Pane
-		var primaryContent string
-		for p := 0; p < paneCount; p++ {
-			process := GetPaneProcess(name, p)
-			pType := PaneTypeFromProcess(process)
-			// If pane is a shell, check if claude is running as a child process
-			if pType == "shell" {
-				if hasAgentChild(name, p) {
-					pType = "agent"
+	for _, sp := range listed {
+		sc := sp.conn
+		for _, name := range sp.names {
+			key := sessionKey(sc.server.Name, name)
+			prev, hasPrev := existing[key]
+			// Sessions that aren't due keep their last state, unless their
+			// panes changed.
+			if hasPrev && !m.sched.Due(key, now) && samePanes(prev.Panes, sp.bySession[name]) {
+				s := *prev
+				s.PollIntervalMs = m.sched.Interval(key).Milliseconds()
+				for _, p := range s.Panes {
+					if p.AgentSessionID != "" {
+						seenLogs[sessionKey(sc.server.Name, p.ID)] = true
+					}
 				}
+				updated = append(updated, s)
+				continue
 			}
-			pane := Pane{Index: p, Type: pType, Process: process}
-			if pType != "editor" {
-				content := CapturePaneContent(name, p)
-				pane.Content = content
-				if primaryContent == "" {
-					primaryContent = content
-				}
+			polled[key] = true
+
+			// Git info is detected once, when a session is first seen. The
+			// repo (or directory) picks the session's status thresholds.
+			var git GitInfo
+			repo := ""
+			if hasPrev {
+				repo = cmp.Or(prev.GitPath, prev.Dir)
+			} else {
+				git = DetectGitInfo(sp.bySession[name][0].Cwd)
+				repo = cmp.Or(git.GitPath, git.Cwd)
 			}
-			// For agent panes, resolve the JSONL session ID (cached from prev cycle)
-			if pType == "agent" {
-				if prev, ok := existing[name]; ok {
-					for _, pp := range prev.Panes {
-						if pp.Index == p && pp.AgentSessionID != "" {
-							pane.AgentSessionID = pp.AgentSessionID
+			th := m.thresholds(name, repo)
+			stuckAfter[key] = th.StuckAfter
+
+			var panes []Pane
+			var windows []Window
+			var primaryContent string
+			primary := -1 // first non-editor pane, which drives the session status
+			for _, info := range sp.bySession[name] {
+				if len(windows) == 0 || windows[len(windows)-1].Index != info.WindowIndex {
+					windows = append(windows, Window{Index: info.WindowIndex, Name: info.WindowName, Active: info.WindowActive})
+				}
+				var prevPane *Pane
+				if prev, ok := existing[key]; ok {
+					for i := range prev.Panes {
+						if prev.Panes[i].ID == info.PaneID {
+							prevPane = &prev.Panes[i]
 							break
 						}
 					}
 				}
-				// Resolve if not cached (first discovery or process restarted)
-				if pane.AgentSessionID == "" {
-					pane.AgentSessionID = GetAgentSessionID(name, p)
+				pType := m.detectors.PaneType(info.Command)
+				// If pane is a shell, check if an agent is running as a child
+				// process; its detector then reads the pane.
+				detectProc := info.Command
+				if pType == "shell" {
+					if child := getProcs().agentChild(info.PID, m.detectors.IsAgent); child != "" {
+						pType = "agent"
+						detectProc = child
+					}
 				}
-			}
-			panes = append(panes, pane)
-		}
-		s := Session{Name: name, Panes: panes, LastChanged: now}
-		if prev, ok := existing[name]; ok {
-			s.LastChanged = prev.LastChanged
-			s.PrevContent = prev.PrevContent
-			s.Branch = prev.Branch
-			s.IsWorktree = prev.IsWorktree
-			s.IsGitRepo = prev.IsGitRepo
-			s.GitPath = prev.GitPath
-			s.WorktreePath = prev.WorktreePath
-			s.Dir = prev.Dir
-			s.Diff = prev.Diff
-			s.PR = prev.PR
-			if primaryContent != prev.PrevContent {
-				s.LastChanged = now
-			}
-			s.PrevContent = primaryContent
-			s.Status = InferStatus(prev.PrevContent, primaryContent, s.LastChanged, now, m.errorPatterns, m.promptPattern)
-		} else {
-			info := DetectSessionGitInfoFull(name)
-			s.Dir = info.Cwd
-			if info.GitPath != "" {
-				s.IsGitRepo = true
-				s.GitPath = info.GitPath
-				s.Branch = info.Branch
-				s.IsWorktree = info.IsWorktree
-				s.WorktreePath = info.WorktreePath
-			}
-			s.PrevContent = primaryContent
-			s.Status = "active"
-		}
-		for i := range s.Panes {
-			if s.Panes[i].Type != "editor" {
-				s.Panes[i].Status = s.Status
-			}
-		}
-		// Detect per-pane waiting for input (only mark the specific pane that's waiting).
-		if s.Status != "error" && s.Status != "done" {
-			waitingPanes := DetectWaitingPanes(s.Panes, m.inputPatterns)
-			if len(waitingPanes) > 0 {
-				s.Status = "waiting"
-				waitingIdx := make(map[int]string)
-				for _, wp := range waitingPanes {
-					waitingIdx[wp.Index] = wp.Prompt
+				pane := Pane{
+					Index:   info.PaneIndex,
+					Window:  info.WindowIndex,
+					ID:      info.PaneID,
+					Type:    pType,
+					Process: info.Command,
+					Cwd:     info.Cwd,
+					PID:     info.PID,
+				}
+				if m.recorder != nil {
+					pane.Recording = m.recorder.Record(sc.server, name, info, pType)
+				}
+				if pType != "editor" {
+					pane.Content, pane.CapturedAt = m.capturePane(sc, info, prevPane)
+					if primaryContent == "" {
+						primaryContent = pane.Content
+						primary = len(panes)
+					}
 				}
-				for i := range s.Panes {
-					if prompt, ok := waitingIdx[s.Panes[i].Index]; ok {
-						s.Panes[i].Status = "waiting"
-						s.Panes[i].Prompt = prompt
+				// For agent panes, resolve the JSONL session ID (cached from prev cycle)
+				if pType == "agent" {
+					if prevPane != nil && prevPane.AgentSessionID != "" {
+						pane.AgentSessionID = prevPane.AgentSessionID
 					}
+					// Resolve if not cached (first discovery or process restarted)
+					if pane.AgentSessionID == "" {
+						pane.AgentSessionID = agentSessionIDForPid(getProcs(), info.PID)
+					}
+				}
+				if pType != "editor" {
+					var logState *agentlog.State
+					if pane.AgentSessionID != "" {
+						key := sessionKey(sc.server.Name, pane.ID)
+						logState = m.agentLogState(key, agentlog.JSONLPath(pane.Cwd, pane.AgentSessionID))
+						seenLogs[key] = true
+					}
+					pane.Status, pane.Prompt = m.detectPane(&pane, prevPane, detectProc, logState, th, now)
+				}
+				panes = append(panes, pane)
+			}
+			s := Session{Name: name, Server: sc.server, Panes: panes, LastChanged: now, Created: sp.bySession[name][0].SessionCreated}
+			if hasPrev {
+				s.LastChanged = prev.LastChanged
+				s.PrevContent = prev.PrevContent
+				s.Branch = prev.Branch
+				s.IsWorktree = prev.IsWorktree
+				s.IsGitRepo = prev.IsGitRepo
+				s.GitPath = prev.GitPath
+				s.WorktreePath = prev.WorktreePath
+				s.Dir = prev.Dir
+				s.Diff = prev.Diff
+				s.PR = prev.PR
+				s.Usage = prev.Usage
+				if primaryContent != prev.PrevContent {
+					s.LastChanged = now
+				}
+				s.PrevContent = primaryContent
+			} else {
+				s.Dir = git.Cwd
+				if git.GitPath != "" {
+					s.IsGitRepo = true
+					s.GitPath = git.GitPath
+					s.Branch = git.Branch
+					s.IsWorktree = git.IsWorktree
+					s.WorktreePath = git.WorktreePath
 				}
+				s.PrevContent = primaryContent
 			}
+			if primary >= 0 {
+				s.Status = sessionStatus(s.Panes, primary)
+			} else {
+				// Only editors: idle once nothing has changed for a while.
+				s.Status = InferStatusWith(s.PrevContent, primaryContent, s.LastChanged, now, nil, "", th)
+			}
+			s.Windows = groupWindowPanes(windows, s.Panes)
+			s.PollIntervalMs = m.sched.Polled(key, s.Status, now).Milliseconds()
+			updated = append(updated, s)
+		}
+	}
+	if m.labels != nil {
+		labels := m.labels.All()
+		for i := range updated {
+			updated[i].Labels = labels[updated[i].Name]
 		}
-		updated = append(updated, s)
 	}
 	// Collect events to publish AFTER releasing the lock (prevents deadlock
 	// since event handlers may call FindSession/Snapshot which need RLock).
 	var events []Event
-	prevNames := make(map[string]bool)
-	for name := range existing {
-		prevNames[name] = true
-	}
+	events = append(events, m.sampleResources(updated, now)...)
+	events = append(events, m.updateUsage(updated, now)...)
 	for _, s := range updated {
-		if !prevNames[s.Name] {
-			events = append(events, SessionCreatedEvent{Name: s.Name})
-		}
-		if prev, ok := existing[s.Name]; ok {
+		key := sessionKey(s.Server.Name, s.Name)
+		prev, ok := existing[key]
+		if !ok {
+			events = append(events, SessionCreatedEvent{Name: s.Name, Server: s.Server.Name, Status: s.Status, At: now})
+		} else if polled[key] {
 			if prev.Status != s.Status {
-				events = append(events, StatusChangedEvent{Session: s.Name, From: prev.Status, To: s.Status})
+				events = append(events, StatusChangedEvent{Session: s.Name, Server: s.Server.Name, From: prev.Status, To: s.Status, At: now})
 			}
 			if s.Status == "waiting" {
 				for _, p := range s.Panes {
 					if p.Status == "waiting" {
-						events = append(events, AgentWaitingEvent{Session: s.Name, PaneIndex: p.Index, Prompt: p.Prompt})
+						events = append(events, AgentWaitingEvent{Session: s.Name, Server: s.Server.Name, PaneIndex: p.Index, PaneID: p.ID, Prompt: p.Prompt})
 					}
 				}
 			}
 			// Detect agent crash: pane was agent, now shell
 			for _, p := range s.Panes {
 				for _, pp := range prev.Panes {
-					if pp.Index == p.Index && pp.Type == "agent" && p.Type == "shell" {
-						events = append(events, AgentCrashedEvent{Session: s.Name, PaneIndex: p.Index, PrevProcess: pp.Process})
+					if pp.ID == p.ID && pp.Type == "agent" && p.Type == "shell" {
+						events = append(events, AgentCrashedEvent{Session: s.Name, Server: s.Server.Name, PaneIndex: p.Index, PaneID: p.ID, PrevProcess: pp.Process})
 					}
 				}
 			}
-			// Detect agent stuck: agent pane unchanged for >5 minutes while session is "idle"
+			// Detect agent stuck: agent pane unchanged past the session's stuck
+			// threshold while the session is "idle"
 			if s.Status == "idle" {
 				idleDuration := now.Sub(s.LastChanged)
-				if idleDuration > 5*time.Minute {
+				if idleDuration > stuckAfter[key] {
 					for _, p := range s.Panes {
 						if p.Type == "agent" {
-							events = append(events, AgentStuckEvent{Session: s.Name, PaneIndex: p.Index, IdleDuration: idleDuration})
+							events = append(events, AgentStuckEvent{Session: s.Name, Server: s.Server.Name, PaneIndex: p.Index, PaneID: p.ID, IdleDuration: idleDuration})
 						}
 					}
 				}
@@ -244,15 +685,24 @@ func (m *Monitor) poll() {
 		}
 	}
 	// Detect removed sessions
-	currentNames := make(map[string]bool)
+	current := make(map[string]bool)
 	for _, s := range updated {
-		currentNames[s.Name] = true
+		current[sessionKey(s.Server.Name, s.Name)] = true
+	}
+	var removed []Session
+	for key, prev := range existing {
+		if !current[key] {
+			events = append(events, SessionRemovedEvent{Name: prev.Name, Server: prev.Server.Name, Status: prev.Status, At: now})
+			removed = append(removed, *prev)
+		}
 	}
-	for name := range existing {
-		if !currentNames[name] {
-			events = append(events, SessionRemovedEvent{Name: name})
+
+	for key := range m.agentLogs {
+		if !seenLogs[key] {
+			delete(m.agentLogs, key)
 		}
 	}
+	m.sched.Prune(current)
 
 	m.sessions = updated
 	m.mu.Unlock()
@@ -262,6 +712,246 @@ func (m *Monitor) poll() {
 	for _, e := range events {
 		m.bus.Publish(e)
 	}
+	m.forgetSessions(removed)
+}
+
+// forgetSessions deletes what is kept by name for sessions that ended, so
+// a new session reusing the name starts afresh. Only a session its server
+// confirms is gone is forgotten: one whose name is still in use on another
+// server, or whose server can't be reached (it crashed or is restarting,
+// and the session may come back from a snapshot), is kept.
+func (m *Monitor) forgetSessions(removed []Session) {
+	if m.labels == nil && m.settings == nil {
+		return
+	}
+	for _, s := range removed {
+		if m.FindSession(s.Name) != nil {
+			continue
+		}
+		if ok, err := s.Server.HasSession(s.Name); ok || err != nil {
+			continue
+		}
+		if m.labels != nil {
+			if err := m.labels.Delete(s.Name); err != nil {
+				log.Printf("monitor: labels of %s: %v", s.Name, err)
+			}
+		}
+		if m.settings != nil {
+			if err := m.settings.DeleteSession(s.Name); err != nil {
+				log.Printf("monitor: settings of %s: %v", s.Name, err)
+			}
+		}
+	}
+}
+
+// detectPane runs the pane's status detector, tracking when its content
+// last changed. The pane's content must already be captured.
+func (m *Monitor) detectPane(pane, prev *Pane, process string, logState *agentlog.State, th Thresholds, now time.Time) (status, prompt string) {
+	pane.ChangedAt = now
+	prevContent := pane.Content
+	if prev != nil {
+		prevContent = prev.Content
+		if pane.Content == prev.Content && !prev.ChangedAt.IsZero() {
+			pane.ChangedAt = prev.ChangedAt
+		}
+	}
+	in := StatusInput{
+		Process:     process,
+		Content:     pane.Content,
+		PrevContent: prevContent,
+		LastChanged: pane.ChangedAt,
+		Now:         now,
+		Log:         logState,
+		Thresholds:  th,
+	}
+	return m.detectors.For(process).Detect(in)
+}
+
+// agentLogState follows the session log of an agent pane, identified by
+// key, and returns the state read from it, or nil if the log can't be read.
+func (m *Monitor) agentLogState(key, path string) *agentlog.State {
+	if path == "" {
+		return nil
+	}
+	t := m.agentLogs[key]
+	if t == nil || t.Path() != path {
+		t = agentlog.NewStatusTracker(path)
+		m.agentLogs[key] = t
+	}
+	state, err := t.Update()
+	if err != nil {
+		return nil
+	}
+	return &state
+}
+
+// sampleResources fills in the resource usage of every session's panes,
+// taking a new sample once resources.sample_s has passed, and returns the
+// threshold events for sessions that crossed a limit in it.
+func (m *Monitor) sampleResources(sessions []Session, now time.Time) []Event {
+	if m.resources == nil {
+		return nil
+	}
+	sampled := false
+	if now.Sub(m.lastSample) >= time.Duration(m.resourceCfg.SampleS)*time.Second {
+		if err := m.resources.Sample(now); err == nil {
+			m.lastSample, sampled = now, true
+		}
+	}
+	var events []Event
+	seen := make(map[string]bool)
+	for i := range sessions {
+		s := &sessions[i]
+		// Carried-over sessions share their panes with earlier snapshots.
+		s.Panes = slices.Clone(s.Panes)
+		s.Resources = ResourceUsage{}
+		for j := range s.Panes {
+			s.Panes[j].Resources = m.resources.Tree(s.Panes[j].PID)
+			s.Resources = s.Resources.Add(s.Panes[j].Resources)
+		}
+		windows := make([]Window, len(s.Windows))
+		for j, w := range s.Windows {
+			windows[j] = Window{Index: w.Index, Name: w.Name, Active: w.Active}
+		}
+		s.Windows = groupWindowPanes(windows, s.Panes)
+		if !sampled {
+			continue
+		}
+		key := sessionKey(s.Server.Name, s.Name)
+		seen[key+"\x00cpu"], seen[key+"\x00memory"] = true, true
+		if e, ok := m.checkLimit(key+"\x00cpu", s.Server.Name, s.Name, "cpu", s.Resources.CPUPercent, m.resourceCfg.CPUPercent); ok {
+			events = append(events, e)
+		}
+		if e, ok := m.checkLimit(key+"\x00memory", s.Server.Name, s.Name, "memory", float64(s.Resources.RSSBytes), float64(m.resourceCfg.RSSMB)*(1<<20)); ok {
+			events = append(events, e)
+		}
+	}
+	if sampled {
+		for key := range m.overLimit {
+			if !seen[key] {
+				delete(m.overLimit, key)
+			}
+		}
+	}
+	return events
+}
+
+// updateUsage refreshes the token usage of every session once usageInterval
+// has passed, and returns the events for sessions that went over budget.
+// Sessions with a budget that pauses get their agents interrupted.
+func (m *Monitor) updateUsage(sessions []Session, now time.Time) []Event {
+	if m.usage == nil || now.Sub(m.lastUsage) < usageInterval {
+		return nil
+	}
+	m.lastUsage = now
+	var events []Event
+	seen := make(map[string]bool)
+	for i := range sessions {
+		s := &sessions[i]
+		key := sessionKey(s.Server.Name, s.Name)
+		seen[key] = true
+		r := m.usage.Report(sessionUsageLogs(s), s.Created)
+		var budget Budget
+		if m.settings != nil {
+			budget, _ = m.settings.Budget(s.Name)
+		}
+		r.BudgetUSD = budget.USD
+		s.Usage = &r
+
+		if budget.USD <= 0 || r.CostUSD < budget.USD {
+			delete(m.budgetHit, key)
+			continue
+		}
+		if m.budgetHit[key] == budget.USD {
+			continue
+		}
+		m.budgetHit[key] = budget.USD
+		paused := false
+		if budget.Pause {
+			for _, p := range s.Panes {
+				if p.Type != "agent" {
+					continue
+				}
+				if err := s.Server.SendRawKey(p.ID, "Escape"); err != nil {
+					log.Printf("monitor: pausing %s: %v", s.Name, err)
+				} else {
+					paused = true
+				}
+			}
+		}
+		events = append(events, BudgetExceededEvent{Session: s.Name, Server: s.Server.Name, CostUSD: r.CostUSD, BudgetUSD: budget.USD, Paused: paused})
+	}
+	for key := range m.budgetHit {
+		if !seen[key] {
+			delete(m.budgetHit, key)
+		}
+	}
+	return events
+}
+
+// checkLimit returns an event when value has gone over limit since the last
+// sample. A zero limit is off.
+func (m *Monitor) checkLimit(key, server, session, resource string, value, limit float64) (Event, bool) {
+	over := limit > 0 && value > limit
+	was := m.overLimit[key]
+	if over {
+		m.overLimit[key] = true
+	} else {
+		delete(m.overLimit, key)
+	}
+	if !over || was {
+		return nil, false
+	}
+	return ResourceThresholdEvent{Session: session, Server: server, Resource: resource, Value: value, Limit: limit}, true
+}
+
+// samePanes reports whether a session still has the panes it had at its
+// last poll, running the same processes.
+func samePanes(prev []Pane, infos []tmuxpkg.PaneInfo) bool {
+	if len(prev) != len(infos) {
+		return false
+	}
+	for i, info := range infos {
+		p := prev[i]
+		if p.ID != info.PaneID || p.Window != info.WindowIndex || p.Index != info.PaneIndex || p.Process != info.Command {
+			return false
+		}
+	}
+	return true
+}
+
+// sessionStatus derives a session's status from its panes: the status of
+// the primary (first non-editor) pane, or waiting if any pane is waiting for
+// input and the primary pane hasn't errored or finished.
+func sessionStatus(panes []Pane, primary int) string {
+	status := panes[primary].Status
+	if status == "error" || status == "done" {
+		return status
+	}
+	for _, p := range panes {
+		if p.Status == "waiting" {
+			return "waiting"
+		}
+	}
+	return status
+}
+
+// sessionKey identifies a session across servers, which may reuse names.
+func sessionKey(server, name string) string {
+	return server + "\x00" + name
+}
+
+// groupWindowPanes fills each window with the IDs of its panes.
+func groupWindowPanes(windows []Window, panes []Pane) []Window {
+	for i := range windows {
+		windows[i].Panes = []string{}
+		for _, p := range panes {
+			if p.Window == windows[i].Index {
+				windows[i].Panes = append(windows[i].Panes, p.ID)
+			}
+		}
+	}
+	return windows
 }
 
 func (m *Monitor) notify() {
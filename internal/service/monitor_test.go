@@ -1,6 +1,11 @@
 package service
 
-import "testing"
+import (
+	"slices"
+	"testing"
+
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
 
 func TestNewMonitor(t *testing.T) {
 	m := NewMonitor(500, []string{"FAIL"}, `\$\s*$`, nil, NewBus())
@@ -41,3 +46,78 @@ func TestMonitorSubscribeUnsubscribe(t *testing.T) {
 		t.Error("expected channel to be closed")
 	}
 }
+
+func TestMonitorControlNotificationMarksDirty(t *testing.T) {
+	m := NewMonitor(500, nil, "", nil, NewBus())
+	sc := m.servers[0]
+	if m.handleControlNotification(sc, tmuxpkg.ControlNotification{Type: "output", PaneID: "%4"}) {
+		t.Error("output should not trigger an immediate poll")
+	}
+	if !sc.dirty["%4"] {
+		t.Error("expected pane %4 to be marked dirty")
+	}
+	if !m.handleControlNotification(sc, tmuxpkg.ControlNotification{Type: "window-add", Args: []string{"@2"}}) {
+		t.Error("window-add should trigger an immediate poll")
+	}
+	m.handleControlNotification(sc, tmuxpkg.ControlNotification{Type: "session-changed", Args: []string{"$1", "work"}})
+	if sc.attached != "work" {
+		t.Errorf("expected attached session work, got %q", sc.attached)
+	}
+}
+
+func TestMonitorMarkAllDirty(t *testing.T) {
+	m := NewMonitor(500, nil, "", nil, NewBus())
+	m.SetServers([]tmuxpkg.Server{{}, {SocketName: "agents"}})
+	m.sessions = []Session{
+		{Name: "a", Server: tmuxpkg.Server{Name: "default"}, Panes: []Pane{{ID: "%1"}, {ID: "%2"}}},
+		{Name: "b", Server: tmuxpkg.Server{Name: "agents"}, Panes: []Pane{{ID: "%7"}}},
+	}
+	sc := m.servers[0]
+	m.markAllDirty(sc)
+	if !sc.dirty["%1"] || !sc.dirty["%2"] || sc.dirty["%7"] {
+		t.Errorf("dirty = %v, want the default server's panes", sc.dirty)
+	}
+}
+
+func TestMonitorSetServers(t *testing.T) {
+	m := NewMonitor(500, nil, "", nil, NewBus())
+	if got := m.Servers(); len(got) != 1 || got[0].Name != "default" {
+		t.Errorf("default servers = %+v, want one default server", got)
+	}
+	m.SetServers([]tmuxpkg.Server{{}, {SocketName: "agents"}})
+	got := m.Servers()
+	if len(got) != 2 || got[1].Name != "agents" || got[1].SocketName != "agents" {
+		t.Errorf("servers = %+v, want default and agents", got)
+	}
+}
+
+func TestGroupWindowPanes(t *testing.T) {
+	windows := []Window{{Index: 0, Name: "code"}, {Index: 2, Name: "agents", Active: true}}
+	panes := []Pane{
+		{Window: 0, Index: 0, ID: "%1"},
+		{Window: 2, Index: 0, ID: "%5"},
+		{Window: 2, Index: 1, ID: "%6", Status: "waiting"},
+	}
+	got := groupWindowPanes(windows, panes)
+	if len(got) != 2 || !slices.Equal(got[0].Panes, []string{"%1"}) || !slices.Equal(got[1].Panes, []string{"%5", "%6"}) {
+		t.Fatalf("unexpected grouping: %+v", got)
+	}
+}
+
+func TestSamePanes(t *testing.T) {
+	prev := []Pane{{ID: "%1", Window: 0, Index: 0, Process: "zsh"}, {ID: "%2", Window: 0, Index: 1, Process: "claude"}}
+	infos := []tmuxpkg.PaneInfo{
+		{PaneID: "%1", WindowIndex: 0, PaneIndex: 0, Command: "zsh"},
+		{PaneID: "%2", WindowIndex: 0, PaneIndex: 1, Command: "claude"},
+	}
+	if !samePanes(prev, infos) {
+		t.Error("expected identical panes to match")
+	}
+	infos[1].Command = "zsh"
+	if samePanes(prev, infos) {
+		t.Error("a changed process should not match")
+	}
+	if samePanes(prev, infos[:1]) {
+		t.Error("a closed pane should not match")
+	}
+}
@@ -1,21 +1,25 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os/exec"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/matteo-hertel/tmux-super-powers/internal/device"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
 )
 
-// Notifier watches session state changes via the event bus and sends push notifications.
+// Notifier watches session state changes via the event bus and sends
+// notifications to its sinks.
 type Notifier struct {
-	monitor     *Monitor
-	deviceStore *device.Store
-	push        *PushClient
-	bus         *Bus
+	monitor *Monitor
+	routes  []NotificationRoute
+	bus     *Bus
 
 	mu sync.Mutex
 	// lastNotified tracks the last status we sent a push notification for,
@@ -29,12 +33,13 @@ type Notifier struct {
 	stopCh chan struct{}
 }
 
-// NewNotifier creates a notifier that watches the given monitor via the event bus.
+// NewNotifier creates a notifier that watches the given monitor via the event
+// bus. It sends push notifications to the paired devices unless SetRoutes
+// picks other sinks.
 func NewNotifier(monitor *Monitor, deviceStore *device.Store, bus *Bus) *Notifier {
 	return &Notifier{
 		monitor:        monitor,
-		deviceStore:    deviceStore,
-		push:           NewPushClient(),
+		routes:         []NotificationRoute{{Sink: NewExpoSink(NewPushClient(), deviceStore)}},
 		bus:            bus,
 		lastNotified:   make(map[string]string),
 		lastCINotified: make(map[string]string),
@@ -42,6 +47,12 @@ func NewNotifier(monitor *Monitor, deviceStore *device.Store, bus *Bus) *Notifie
 	}
 }
 
+// SetRoutes replaces the sinks notifications are sent to. Must be called
+// before Start.
+func (n *Notifier) SetRoutes(routes []NotificationRoute) {
+	n.routes = routes
+}
+
 // Start begins watching for events.
 func (n *Notifier) Start() {
 	n.unsub = n.bus.Subscribe(func(e Event) {
@@ -71,40 +82,39 @@ func (n *Notifier) handleEvent(e Event) {
 		n.onCIStatusChanged(ev)
 	case SessionRemovedEvent:
 		n.mu.Lock()
-		delete(n.lastNotified, ev.Name)
-		delete(n.lastCINotified, ev.Name)
+		delete(n.lastNotified, sessionKey(ev.Server, ev.Name))
+		delete(n.lastCINotified, sessionKey(ev.Server, ev.Name))
 		n.mu.Unlock()
 	}
 }
 
 func (n *Notifier) onStatusChanged(ev StatusChangedEvent) {
-	tokens := n.deviceStore.PushTokens()
-	if len(tokens) == 0 {
+	if !n.wants(ev.To) {
 		return
 	}
 
 	// Skip if user is attached to this session
-	if sessionHasAttachedClient(ev.Session) {
+	if n.sessionHasAttachedClient(ev.Server, ev.Session) {
 		return
 	}
 
-	var msg *PushMessage
+	var msg *Notification
 
 	switch ev.To {
 	case "done":
 		if ev.From != "active" && ev.From != "idle" && ev.From != "waiting" && ev.From != "error" {
 			return
 		}
-		s := n.monitor.FindSession(ev.Session)
+		s := n.monitor.FindSessionOn(ev.Server, ev.Session)
 		body := "Session completed"
 		if s != nil && s.Diff != nil {
 			body = fmt.Sprintf("%d files changed, +%d/-%d", s.Diff.Files, s.Diff.Insertions, s.Diff.Deletions)
 		}
-		msg = &PushMessage{
-			Title:      fmt.Sprintf("Agent finished: %s", ev.Session),
-			Body:       body,
-			Sound:      "default",
-			CategoryID: "done",
+		msg = &Notification{
+			Category: "done",
+			Session:  ev.Session,
+			Title:    fmt.Sprintf("Agent finished: %s", ev.Session),
+			Body:     body,
 			Data: map[string]string{
 				"type":        "status_change",
 				"sessionName": ev.Session,
@@ -117,23 +127,23 @@ func (n *Notifier) onStatusChanged(ev StatusChangedEvent) {
 		return
 	}
 
+	key := sessionKey(ev.Server, ev.Session)
 	n.mu.Lock()
-	if n.lastNotified[ev.Session] == ev.To {
+	if n.lastNotified[key] == ev.To {
 		n.mu.Unlock()
 		return
 	}
-	n.lastNotified[ev.Session] = ev.To
+	n.lastNotified[key] = ev.To
 	n.mu.Unlock()
 
-	n.sendToAll(tokens, msg)
+	n.send(ev.Server, *msg)
 }
 
 func (n *Notifier) onAgentWaiting(ev AgentWaitingEvent) {
-	tokens := n.deviceStore.PushTokens()
-	if len(tokens) == 0 {
+	if !n.wants("waiting") {
 		return
 	}
-	if sessionHasAttachedClient(ev.Session) {
+	if n.sessionHasAttachedClient(ev.Server, ev.Session) {
 		return
 	}
 
@@ -145,86 +155,154 @@ func (n *Notifier) onAgentWaiting(ev AgentWaitingEvent) {
 		}
 	}
 
+	key := sessionKey(ev.Server, ev.Session)
 	n.mu.Lock()
-	if n.lastNotified[ev.Session] == "waiting" {
+	if n.lastNotified[key] == "waiting" {
 		n.mu.Unlock()
 		return
 	}
-	n.lastNotified[ev.Session] = "waiting"
+	n.lastNotified[key] = "waiting"
 	n.mu.Unlock()
 
-	msg := &PushMessage{
-		Title:      fmt.Sprintf("Input needed: %s", ev.Session),
-		Body:       body,
-		Sound:      "default",
-		Priority:   "high",
-		CategoryID: "waiting",
+	n.send(ev.Server, Notification{
+		Category: "waiting",
+		Session:  ev.Session,
+		Title:    fmt.Sprintf("Input needed: %s", ev.Session),
+		Body:     body,
+		Priority: "high",
 		Data: map[string]string{
 			"type":        "status_change",
 			"sessionName": ev.Session,
 			"status":      "waiting",
 		},
-	}
-	n.sendToAll(tokens, msg)
+	})
 }
 
 func (n *Notifier) onCIStatusChanged(ev CIStatusChangedEvent) {
-	tokens := n.deviceStore.PushTokens()
-	if len(tokens) == 0 {
-		return
-	}
-
-	if ev.To == "fail" {
+	key := sessionKey(ev.Server, ev.Session)
+	if ev.To == "fail" && n.wants("error") {
 		n.mu.Lock()
-		if n.lastCINotified[ev.Session] == "fail" {
+		if n.lastCINotified[key] == "fail" {
 			n.mu.Unlock()
 			return
 		}
-		n.lastCINotified[ev.Session] = "fail"
+		n.lastCINotified[key] = "fail"
 		n.mu.Unlock()
 
-		msg := &PushMessage{
-			To:         "",
-			Title:      fmt.Sprintf("CI failing: %s", ev.Session),
-			Body:       fmt.Sprintf("PR #%d checks failing", ev.PRNumber),
-			Sound:      "default",
-			Priority:   "high",
-			CategoryID: "error",
+		n.send(ev.Server, Notification{
+			Category: "error",
+			Session:  ev.Session,
+			Title:    fmt.Sprintf("CI failing: %s", ev.Session),
+			Body:     fmt.Sprintf("PR #%d checks failing", ev.PRNumber),
+			Priority: "high",
 			Data: map[string]string{
 				"type":        "ci_fail",
 				"sessionName": ev.Session,
 			},
-		}
-		n.sendToAll(tokens, msg)
+		})
 	}
 
 	// When CI recovers, clear so a future failure can re-notify.
 	if ev.From == "fail" && ev.To != "fail" {
 		n.mu.Lock()
-		delete(n.lastCINotified, ev.Session)
+		delete(n.lastCINotified, key)
 		n.mu.Unlock()
 	}
 }
 
-func (n *Notifier) sendToAll(tokens []string, msg *PushMessage) {
-	var messages []PushMessage
-	for _, token := range tokens {
-		m := *msg
-		m.To = token
-		messages = append(messages, m)
+// wants reports whether any sink takes notifications of a category.
+func (n *Notifier) wants(category string) bool {
+	for _, r := range n.routes {
+		if r.Accepts(category) {
+			return true
+		}
+	}
+	return false
+}
+
+// send delivers a notification about a session on the server with the
+// given label to the sinks that take its category, each in the background
+// so a slow one doesn't hold up the others.
+func (n *Notifier) send(server string, msg Notification) {
+	msg.Server = n.sessionServer(server, msg.Session)
+	for _, r := range n.routes {
+		if !r.Accepts(msg.Category) {
+			continue
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+			defer cancel()
+			if err := r.Sink.Notify(ctx, msg); err != nil && !errors.Is(err, ErrNoPushTokens) {
+				log.Printf("[notify] %s: %v", r.Sink.Name(), err)
+			}
+		}()
+	}
+}
+
+// SinkResult is the outcome of sending a notification to one sink.
+type SinkResult struct {
+	Sink  string `json:"sink"`
+	Error string `json:"error,omitempty"`
+}
+
+// Test sends a notification to every sink that takes its category, as a
+// real one would be, and waits for them all to finish or ctx to be done.
+func (n *Notifier) Test(ctx context.Context, msg Notification) ([]SinkResult, error) {
+	if !slices.Contains(notificationCategories, msg.Category) {
+		return nil, fmt.Errorf("unknown category %q (want done, waiting or error)", msg.Category)
+	}
+	if !n.wants(msg.Category) {
+		return nil, fmt.Errorf("no notification sink takes %s notifications", msg.Category)
+	}
+	msg.Server = tmuxpkg.DefaultServer()
+	var sinks []NotificationSink
+	for _, r := range n.routes {
+		if r.Accepts(msg.Category) {
+			sinks = append(sinks, r.Sink)
+		}
 	}
-	go func() {
-		if err := n.push.Send(messages); err != nil {
-			log.Printf("push notification error: %v", err)
+	results := make([]SinkResult, len(sinks))
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		results[i].Sink = sink.Name()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sink.Notify(ctx, msg); err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// sessionServer returns the tmux server of a session, falling back to the
+// default server if the monitor doesn't know the session.
+func (n *Notifier) sessionServer(server, name string) tmuxpkg.Server {
+	if n.monitor != nil {
+		if s := n.monitor.FindSessionOn(server, name); s != nil {
+			return s.Server
 		}
-	}()
+	}
+	return tmuxpkg.DefaultServer()
 }
 
 // sessionHasAttachedClient returns true if any tmux client is attached to the session.
-func sessionHasAttachedClient(sessionName string) bool {
-	out, err := exec.Command("tmux", "list-clients", "-t", sessionName, "-F", "#{client_name}").Output()
+// Control-mode clients (such as the Monitor's own connection) don't count.
+func (n *Notifier) sessionHasAttachedClient(server, sessionName string) bool {
+	srv := n.sessionServer(server, sessionName)
+	if !sameServer(server, srv.Name) {
+		return false // the session is gone; don't ask another server
+	}
+	out, err := srv.Command("list-clients", "-t", sessionName, "-F", "#{client_control_mode}").Output()
 	if err != nil {
 		return false
 	}
-	return len(strings.TrimSpace(string(out))) > 0
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if strings.TrimSpace(line) == "0" {
+			return true
+		}
+	}
+	return false
 }
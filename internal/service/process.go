@@ -0,0 +1,55 @@
+package service
+
+import "github.com/matteo-hertel/tmux-super-powers/internal/proc"
+
+// processTable is a point-in-time snapshot of every process on the machine,
+// loaded once so per-pane child lookups don't spawn processes of their own.
+type processTable struct {
+	*proc.Table
+}
+
+// loadProcessTable snapshots all processes, from /proc where available and
+// with ps otherwise.
+func loadProcessTable() (*processTable, error) {
+	t, err := proc.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &processTable{t}, nil
+}
+
+// parseProcessTable parses `ps -A -o pid=,ppid=,comm=` output.
+func parseProcessTable(out string) *processTable {
+	return &processTable{proc.ParsePS(out)}
+}
+
+// isAgentComm returns true if a process name belongs to a supported agent CLI.
+func isAgentComm(comm string) bool {
+	return comm == "claude" || comm == "aider" || comm == "codex" || isClaudeVersion(comm)
+}
+
+// isClaudeComm returns true if a process name is Claude Code.
+func isClaudeComm(comm string) bool {
+	return comm == "claude" || isClaudeVersion(comm)
+}
+
+// agentChild returns the name of the nearest descendant of pid, at any
+// depth (e.g. under npx or a wrapper script), that isAgent accepts, or "" if
+// there is none.
+func (t *processTable) agentChild(pid int, isAgent func(comm string) bool) string {
+	p, ok := t.Find(pid, func(p proc.Process) bool { return isAgent(p.Comm) })
+	if !ok {
+		return ""
+	}
+	return p.Comm
+}
+
+// findClaudePid returns the claude process for a pane: the pane process
+// itself or its nearest claude descendant. Returns 0 if none is found.
+func (t *processTable) findClaudePid(pid int) int {
+	if p, ok := t.Get(pid); ok && isClaudeComm(p.Comm) {
+		return pid
+	}
+	p, _ := t.Find(pid, func(p proc.Process) bool { return isClaudeComm(p.Comm) })
+	return p.PID
+}
@@ -0,0 +1,45 @@
+package service
+
+import "testing"
+
+const samplePS = `    1     0 launchd
+  100     1 zsh
+  101   100 claude
+  200     1 -bash
+  201   200 node
+  300     1 2.1.71
+  400     1 /Applications/My App.app/Contents/MacOS/aider
+  500     1 zsh
+  501   500 npm
+  502   501 node
+  503   502 claude
+`
+
+func TestProcessTableAgentChild(t *testing.T) {
+	procs := parseProcessTable(samplePS)
+	if got := procs.agentChild(100, isAgentComm); got != "claude" {
+		t.Errorf("agentChild(100) = %q, want claude", got)
+	}
+	if got := procs.agentChild(200, isAgentComm); got != "" {
+		t.Errorf("agentChild(200) = %q, want none for bash running node", got)
+	}
+	if got := procs.agentChild(500, isAgentComm); got != "claude" {
+		t.Errorf("agentChild(500) = %q, want claude three levels down", got)
+	}
+}
+
+func TestProcessTableFindClaudePid(t *testing.T) {
+	procs := parseProcessTable(samplePS)
+	if got := procs.findClaudePid(100); got != 101 {
+		t.Errorf("findClaudePid(100) = %d, want 101", got)
+	}
+	if got := procs.findClaudePid(300); got != 300 {
+		t.Errorf("findClaudePid(300) = %d, want 300 (version-named claude)", got)
+	}
+	if got := procs.findClaudePid(500); got != 503 {
+		t.Errorf("findClaudePid(500) = %d, want 503 (grandchild)", got)
+	}
+	if got := procs.findClaudePid(200); got != 0 {
+		t.Errorf("findClaudePid(200) = %d, want 0", got)
+	}
+}
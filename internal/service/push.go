@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -24,17 +25,20 @@ type PushMessage struct {
 // PushClient sends notifications via the Expo Push Service.
 type PushClient struct {
 	client *http.Client
+	url    string
 }
 
 // NewPushClient creates a new Expo push client.
 func NewPushClient() *PushClient {
 	return &PushClient{
 		client: &http.Client{Timeout: 10 * time.Second},
+		url:    expoPushURL,
 	}
 }
 
-// Send sends one or more push messages to the Expo Push Service.
-func (p *PushClient) Send(messages []PushMessage) error {
+// Send sends one or more push messages to the Expo Push Service. The
+// request is abandoned when ctx is done.
+func (p *PushClient) Send(ctx context.Context, messages []PushMessage) error {
 	if len(messages) == 0 {
 		return nil
 	}
@@ -44,7 +48,7 @@ func (p *PushClient) Send(messages []PushMessage) error {
 		return fmt.Errorf("push: marshal: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", expoPushURL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("push: create request: %w", err)
 	}
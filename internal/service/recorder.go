@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/recording"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+// recordingPruneInterval is how often old recordings are swept.
+const recordingPruneInterval = time.Hour
+
+// Recorder records pane output to rotating log files with tmux pipe-pane.
+// Each pipe runs `tsp record-pane`, a child of the tmux server, so recording
+// carries on while tsp serve restarts.
+type Recorder struct {
+	cfg    config.RecordingConfig
+	root   string
+	exe    string
+	stopCh chan struct{}
+
+	mu   sync.Mutex
+	live map[string]time.Time // current log files of piped panes, by when last seen
+}
+
+// NewRecorder creates a Recorder that writes under root
+// (normally RecordingsDir()).
+func NewRecorder(cfg config.RecordingConfig, root string) *Recorder {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "tsp"
+	}
+	return &Recorder{cfg: cfg, root: root, exe: exe, stopCh: make(chan struct{}), live: make(map[string]time.Time)}
+}
+
+// RecordingsDir returns the directory recordings are written to
+// (~/.tsp/recordings).
+func RecordingsDir() string {
+	return filepath.Join(config.TspDir(), "recordings")
+}
+
+// RecordingPath returns the current log file of a pane's recording. Pane IDs
+// start over when the tmux server restarts, so the file is named after the
+// pane and its session's creation time. Sessions on servers other than the
+// default one get their server name appended, so same-named sessions on
+// different servers don't share a directory.
+func RecordingPath(root string, srv tmuxpkg.Server, session string, created time.Time, paneID string) string {
+	dir := strings.ReplaceAll(session, string(filepath.Separator), "_")
+	if label := srv.Label(); label != tmuxpkg.DefaultServer().Label() {
+		dir += "@" + label
+	}
+	return filepath.Join(root, dir, fmt.Sprintf("%s-%d.log", paneID, created.Unix()))
+}
+
+// Start runs the retention sweep in the background.
+func (r *Recorder) Start() { go r.loop() }
+
+func (r *Recorder) Stop() { close(r.stopCh) }
+
+// loop prunes once an interval has passed, by when the monitor has seen
+// which panes are piped.
+func (r *Recorder) loop() {
+	ticker := time.NewTicker(recordingPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.prune()
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+func (r *Recorder) prune() {
+	if r.cfg.RetentionDays <= 0 {
+		return
+	}
+	if err := recording.Prune(r.root, time.Duration(r.cfg.RetentionDays)*24*time.Hour, r.liveLogs()); err != nil {
+		log.Printf("[recorder] prune %s: %v", r.root, err)
+	}
+}
+
+// Record starts recording a pane that isn't piped yet and reports whether
+// the pane is being recorded. Panes that are not agents are skipped when
+// agents_only is set. A piped pane only counts as recording if tsp started
+// the pipe to its current log file; pipes started by anything else are left
+// alone.
+func (r *Recorder) Record(srv tmuxpkg.Server, session string, info tmuxpkg.PaneInfo, paneType string) bool {
+	if !r.cfg.Enabled {
+		return false
+	}
+	path := RecordingPath(r.root, srv, session, info.SessionCreated, info.PaneID)
+	if info.Piped {
+		if info.Recording != path {
+			return false
+		}
+		r.markLive(path)
+		return true
+	}
+	if r.cfg.AgentsOnly && paneType != "agent" {
+		return false
+	}
+	if err := srv.RecordPane(info.PaneID, r.pipeCommand(path), path); err != nil {
+		log.Printf("[recorder] %s %s: %v", session, info.PaneID, err)
+		return false
+	}
+	r.markLive(path)
+	return true
+}
+
+func (r *Recorder) markLive(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.live[path] = time.Now()
+}
+
+// liveLogs returns the log files of the panes seen recording within the last
+// prune interval, forgetting the rest.
+func (r *Recorder) liveLogs() map[string]bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	live := make(map[string]bool, len(r.live))
+	for path, seen := range r.live {
+		if time.Since(seen) > recordingPruneInterval {
+			delete(r.live, path)
+			continue
+		}
+		live[path] = true
+	}
+	return live
+}
+
+// pipeCommand builds the shell command pipe-pane runs for a recording.
+// '#' is doubled because tmux expands formats in pipe-pane commands.
+func (r *Recorder) pipeCommand(path string) string {
+	cmd := fmt.Sprintf("exec %s record-pane --max-bytes %d --max-files %d %s",
+		shellQuote(r.exe), int64(r.cfg.MaxFileMB)<<20, r.cfg.MaxFiles, shellQuote(path))
+	return strings.ReplaceAll(cmd, "#", "##")
+}
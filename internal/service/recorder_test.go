@@ -0,0 +1,57 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+func TestRecordingPath(t *testing.T) {
+	root := "/home/me/.tsp/recordings"
+	created := time.Unix(1700000000, 0)
+	if got := RecordingPath(root, tmuxpkg.DefaultServer(), "myapp", created, "%12"); got != filepath.Join(root, "myapp", "%12-1700000000.log") {
+		t.Errorf("default server path = %q", got)
+	}
+	other := tmuxpkg.Server{Name: "work", SocketName: "work"}
+	if got := RecordingPath(root, other, "feat/x", created, "%3"); got != filepath.Join(root, "feat_x@work", "%3-1700000000.log") {
+		t.Errorf("other server path = %q", got)
+	}
+}
+
+func TestRecorderPipeCommand(t *testing.T) {
+	r := NewRecorder(config.RecordingConfig{Enabled: true, MaxFileMB: 2, MaxFiles: 3}, "/tmp/rec")
+	r.exe = "/usr/local/bin/tsp"
+	got := r.pipeCommand("/tmp/rec/app#1/%4.log")
+	want := "exec '/usr/local/bin/tsp' record-pane --max-bytes 2097152 --max-files 3 '/tmp/rec/app##1/%4.log'"
+	if got != want {
+		t.Errorf("pipeCommand = %q, want %q", got, want)
+	}
+}
+
+func TestRecorderLiveLogs(t *testing.T) {
+	root := t.TempDir()
+	r := NewRecorder(config.RecordingConfig{Enabled: true}, root)
+	created := time.Unix(1700000000, 0)
+	path := RecordingPath(root, tmuxpkg.DefaultServer(), "myapp", created, "%4")
+	info := tmuxpkg.PaneInfo{PaneID: "%4", Piped: true, Recording: path, SessionCreated: created}
+	if !r.Record(tmuxpkg.DefaultServer(), "myapp", info, "agent") {
+		t.Error("a pane tsp is recording should count as recording")
+	}
+	// A pane piped by something else isn't recorded, and its log isn't live.
+	other := tmuxpkg.PaneInfo{PaneID: "%5", Piped: true, SessionCreated: created}
+	if r.Record(tmuxpkg.DefaultServer(), "myapp", other, "agent") {
+		t.Error("a pane piped elsewhere should not count as recording")
+	}
+	r.live["/gone.log"] = time.Now().Add(-2 * recordingPruneInterval)
+
+	live := r.liveLogs()
+	if !live[path] || len(live) != 1 {
+		t.Errorf("live = %v", live)
+	}
+	if _, ok := r.live["/gone.log"]; ok {
+		t.Error("a pane not seen piped for a while should be forgotten")
+	}
+}
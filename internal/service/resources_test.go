@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// statLine builds a /proc/<pid>/stat line with the fields the sampler reads.
+func statLine(pid, ppid int, comm string, utime, stime, rss int) string {
+	return fmt.Sprintf("%d (%s) S %d 1 1 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 1 0 12345 1000000 %d 18446744073709551615",
+		pid, comm, ppid, utime, stime, rss)
+}
+
+func writeProc(t *testing.T, root string, stats map[int]string) {
+	t.Helper()
+	for pid, line := range stats {
+		dir := filepath.Join(root, fmt.Sprint(pid))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(line), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResourceSamplerTree(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "self"), 0755) // non-PID entries are skipped
+	writeProc(t, root, map[int]string{
+		1:   statLine(1, 0, "init", 0, 0, 10),
+		100: statLine(100, 1, "zsh", 10, 0, 100),
+		101: statLine(101, 100, "go", 100, 0, 200),
+		102: statLine(102, 101, "test binary", 0, 0, 300),
+		200: statLine(200, 1, "other", 500, 0, 1000),
+	})
+	s := newResourceSampler(root)
+	s.pageSize = 4096
+	t0 := time.Now()
+	if err := s.Sample(t0); err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	u := s.Tree(100)
+	if u.Children != 2 || u.RSSBytes != 600*4096 || u.CPUPercent != 0 {
+		t.Errorf("first sample = %+v, want 2 children, 600 pages, no CPU yet", u)
+	}
+
+	// Over 2s: go used 1s of CPU, the test binary 3s (two cores).
+	writeProc(t, root, map[int]string{
+		101: statLine(101, 100, "go", 200, 0, 200),
+		102: statLine(102, 101, "test binary", 200, 100, 300),
+	})
+	if err := s.Sample(t0.Add(2 * time.Second)); err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	if got := s.Tree(100).CPUPercent; got < 199.9 || got > 200.1 {
+		t.Errorf("tree CPU = %.1f%%, want 200%%", got)
+	}
+	if got := s.Tree(999); got != (ResourceUsage{}) {
+		t.Errorf("unknown PID usage = %+v, want zero", got)
+	}
+}
+
+func TestMonitorCheckLimit(t *testing.T) {
+	m := NewMonitor(500, nil, "", nil, NewBus())
+	m.overLimit = make(map[string]bool)
+	if _, ok := m.checkLimit("k", "", "s", "cpu", 150, 100); !ok {
+		t.Error("expected an event when crossing the limit")
+	}
+	if _, ok := m.checkLimit("k", "", "s", "cpu", 180, 100); ok {
+		t.Error("expected no repeat event while over the limit")
+	}
+	m.checkLimit("k", "", "s", "cpu", 50, 100)
+	e, ok := m.checkLimit("k", "", "s", "cpu", 120, 100)
+	if !ok {
+		t.Fatal("expected an event after dropping below and crossing again")
+	}
+	if ev := e.(ResourceThresholdEvent); ev.Session != "s" || ev.Resource != "cpu" || ev.Value != 120 {
+		t.Errorf("event = %+v", ev)
+	}
+	if _, ok := m.checkLimit("k2", "", "s", "cpu", 1e9, 0); ok {
+		t.Error("a zero limit should never fire")
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxIdleInterval caps the backoff of idle sessions.
+	maxIdleInterval = 30 * time.Second
+	// doneInterval is how often finished sessions are polled.
+	doneInterval = 60 * time.Second
+	// listInterval is the longest the monitor goes without listing panes,
+	// so sessions created or killed outside control mode are noticed.
+	listInterval = 5 * time.Second
+)
+
+// pollSchedule is when a session is next due for a full poll.
+type pollSchedule struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// scheduler decides how often each session is polled. Active and waiting
+// sessions are polled every base interval; idle (and errored) ones back off
+// exponentially up to maxIdleInterval; done ones are polled every
+// doneInterval. Reset brings a session back to the base interval.
+type scheduler struct {
+	base time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*pollSchedule // by session key
+}
+
+func newScheduler(base time.Duration) *scheduler {
+	return &scheduler{base: base, sessions: make(map[string]*pollSchedule)}
+}
+
+// Due reports whether a session should be polled now. Sessions not seen
+// before are always due.
+func (s *scheduler) Due(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.sessions[key]
+	return !ok || !now.Before(ps.next)
+}
+
+// Polled schedules a session's next poll from the status it was just found
+// in, and returns the interval chosen.
+func (s *scheduler) Polled(key, status string, now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps := s.sessions[key]
+	if ps == nil {
+		ps = &pollSchedule{}
+		s.sessions[key] = ps
+	}
+	ps.interval = s.nextInterval(ps.interval, status)
+	ps.next = now.Add(ps.interval)
+	return ps.interval
+}
+
+func (s *scheduler) nextInterval(prev time.Duration, status string) time.Duration {
+	switch status {
+	case "idle", "error":
+		if prev < s.base {
+			return s.base
+		}
+		return min(2*prev, max(maxIdleInterval, s.base))
+	case "done":
+		return max(doneInterval, s.base)
+	}
+	return s.base
+}
+
+// Reset brings a session back to the base interval: it is polled within
+// one base interval of now and its backoff starts over.
+func (s *scheduler) Reset(key string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ps, ok := s.sessions[key]; ok {
+		ps.interval = s.base
+		if next := now.Add(s.base); next.Before(ps.next) {
+			ps.next = next
+		}
+	}
+}
+
+// Interval returns a session's current poll interval, or 0 if it hasn't
+// been polled yet.
+func (s *scheduler) Interval(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ps, ok := s.sessions[key]; ok {
+		return ps.interval
+	}
+	return 0
+}
+
+// Next returns when the next session falls due, bounded by listInterval
+// (or the base interval, if longer) from now.
+func (s *scheduler) Next(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := now.Add(max(listInterval, s.base))
+	for _, ps := range s.sessions {
+		if ps.next.Before(next) {
+			next = ps.next
+		}
+	}
+	return next
+}
+
+// Prune forgets sessions not in keep.
+func (s *scheduler) Prune(keep map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.sessions {
+		if !keep[key] {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+// resetsSchedule returns the session a bus event is about, if the event
+// should bring the session back to the base poll interval. Events that
+// restate a status the schedule already reflects (waiting, stuck) don't.
+func resetsSchedule(e Event) (string, bool) {
+	switch e := e.(type) {
+	case StatusChangedEvent:
+		return e.Session, true
+	case AgentCrashedEvent:
+		return e.Session, true
+	case PRDetectedEvent:
+		return e.Session, true
+	case CIStatusChangedEvent:
+		return e.Session, true
+	case ReviewsChangedEvent:
+		return e.Session, true
+	case PRMergedEvent:
+		return e.Session, true
+	case FixAttemptedEvent:
+		return e.Session, true
+	}
+	return "", false
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerBackoff(t *testing.T) {
+	base := 500 * time.Millisecond
+	s := newScheduler(base)
+	now := time.Now()
+
+	if !s.Due("a", now) {
+		t.Fatal("a session never polled should be due")
+	}
+	if got := s.Polled("a", "active", now); got != base {
+		t.Errorf("active interval = %v, want %v", got, base)
+	}
+	if s.Due("a", now.Add(base/2)) {
+		t.Error("session should not be due before its interval")
+	}
+	if !s.Due("a", now.Add(base)) {
+		t.Error("session should be due after its interval")
+	}
+
+	want := []time.Duration{2 * base, 4 * base, 8 * base}
+	for i, w := range want {
+		if got := s.Polled("a", "idle", now); got != w {
+			t.Errorf("idle poll %d: interval = %v, want %v", i, got, w)
+		}
+	}
+	for range 10 {
+		s.Polled("a", "idle", now)
+	}
+	if got := s.Interval("a"); got != maxIdleInterval {
+		t.Errorf("idle interval after backoff = %v, want %v", got, maxIdleInterval)
+	}
+	if got := s.Polled("a", "waiting", now); got != base {
+		t.Errorf("waiting interval = %v, want %v", got, base)
+	}
+	if got := s.Polled("a", "done", now); got != doneInterval {
+		t.Errorf("done interval = %v, want %v", got, doneInterval)
+	}
+}
+
+func TestSchedulerReset(t *testing.T) {
+	base := time.Second
+	s := newScheduler(base)
+	now := time.Now()
+	for range 5 {
+		s.Polled("a", "idle", now)
+	}
+	s.Reset("a", now)
+	if got := s.Interval("a"); got != base {
+		t.Errorf("interval after reset = %v, want %v", got, base)
+	}
+	if !s.Due("a", now.Add(base)) {
+		t.Error("session should be due one base interval after a reset")
+	}
+	if got := s.Polled("a", "idle", now); got != 2*base {
+		t.Errorf("backoff after reset = %v, want %v", got, 2*base)
+	}
+}
+
+func TestSchedulerNext(t *testing.T) {
+	base := time.Second
+	s := newScheduler(base)
+	now := time.Now()
+	if got := s.Next(now); !got.Equal(now.Add(listInterval)) {
+		t.Errorf("Next with no sessions = %v, want %v", got.Sub(now), listInterval)
+	}
+	s.Polled("a", "done", now)
+	s.Polled("b", "active", now)
+	if got := s.Next(now); !got.Equal(now.Add(base)) {
+		t.Errorf("Next = %v, want %v", got.Sub(now), base)
+	}
+	s.Prune(map[string]bool{"a": true})
+	if s.Interval("b") != 0 {
+		t.Error("expected b to be pruned")
+	}
+}
+
+func TestResetsSchedule(t *testing.T) {
+	if name, ok := resetsSchedule(CIStatusChangedEvent{Session: "feat", To: "fail"}); !ok || name != "feat" {
+		t.Errorf("CI event: got %q, %v", name, ok)
+	}
+	if _, ok := resetsSchedule(AgentStuckEvent{Session: "feat"}); ok {
+		t.Error("stuck events should not reset the schedule")
+	}
+}
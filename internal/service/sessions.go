@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,29 +16,53 @@ import (
 // Session represents a tmux session with enriched metadata.
 type Session struct {
 	Name         string    `json:"name"`
+	Server       tmuxpkg.Server `json:"server"` // tmux server the session lives on
 	Status       string    `json:"status"`
 	Branch       string    `json:"branch,omitempty"`
 	IsWorktree   bool      `json:"isWorktree"`
 	IsGitRepo    bool      `json:"isGitRepo"`
 	GitPath      string    `json:"-"`
 	LastChanged  time.Time `json:"lastChanged"`
-	Panes        []Pane    `json:"panes"`
+	Panes        []Pane    `json:"panes"`   // every pane across all windows, in window order
+	Windows      []Window  `json:"windows"` // the same panes grouped by window
 	Diff         *DiffStat `json:"diff,omitempty"`
 	PR           *PRInfo   `json:"pr,omitempty"`
 	PrevContent  string `json:"-"`
 	WorktreePath string `json:"worktreePath,omitempty"`
 	Dir          string `json:"dir,omitempty"`
+	PollIntervalMs int64 `json:"pollIntervalMs,omitempty"` // how often the monitor currently polls the session
+	Resources      ResourceUsage `json:"resources"`         // sum over the panes
+	Created        time.Time     `json:"created"`
+	Usage          *UsageReport  `json:"usage,omitempty"` // tokens and cost of the session's agents
+	Labels         []string      `json:"labels,omitempty"`
+}
+
+// Window represents a tmux window and the panes it contains. Panes are
+// listed by ID; the panes themselves are in Session.Panes.
+type Window struct {
+	Index  int      `json:"index"`
+	Name   string   `json:"name"`
+	Active bool     `json:"active"`
+	Panes  []string `json:"panes"`
 }
 
 // Pane represents a single pane within a tmux session.
 type Pane struct {
 	Index          int    `json:"index"`
+	Window         int    `json:"window"`                      // index of the window containing the pane
+	ID             string `json:"id,omitempty"`                // stable tmux pane ID (e.g. "%12"), when known
 	Type           string `json:"type"`                        // editor, agent, shell, process
 	Process        string `json:"process"`
+	Cwd            string `json:"cwd,omitempty"`
 	Status         string `json:"status,omitempty"`
 	Content        string `json:"content,omitempty"`
 	Prompt         string `json:"prompt,omitempty"`
-	AgentSessionID string `json:"agentSessionId,omitempty"`    // Claude Code JSONL session UUID (resolved via lsof)
+	AgentSessionID string `json:"agentSessionId,omitempty"`    // Claude Code JSONL session UUID (resolved from open files)
+	Recording      bool   `json:"recording,omitempty"`         // output is being recorded by tsp
+	PID            int    `json:"pid,omitempty"`               // the pane's process
+	Resources      ResourceUsage `json:"resources"`            // of the pane's process tree (Linux only)
+	CapturedAt     time.Time `json:"-"`                        // when Content was last captured
+	ChangedAt      time.Time `json:"-"`                        // when Content last changed
 }
 
 // DiffStat holds git diff statistics.
@@ -55,19 +80,52 @@ type PRInfo struct {
 	ReviewCount int    `json:"reviewCount"`
 }
 
+// FindPane returns the pane with the given tmux pane ID, or nil.
+func (s *Session) FindPane(id string) *Pane {
+	for i := range s.Panes {
+		if s.Panes[i].ID == id {
+			return &s.Panes[i]
+		}
+	}
+	return nil
+}
+
+// FindPaneAt returns the pane at the given window and pane index, or nil.
+func (s *Session) FindPaneAt(window, index int) *Pane {
+	for i := range s.Panes {
+		if s.Panes[i].Window == window && s.Panes[i].Index == index {
+			return &s.Panes[i]
+		}
+	}
+	return nil
+}
+
+// AgentPane returns the first agent pane in any window. If none is detected
+// it falls back to pane 1 of the first window, where tsp's two-pane layout
+// starts the agent. Returns nil if neither exists.
+func (s *Session) AgentPane() *Pane {
+	for i := range s.Panes {
+		if s.Panes[i].Type == "agent" {
+			return &s.Panes[i]
+		}
+	}
+	if len(s.Panes) == 0 {
+		return nil
+	}
+	return s.FindPaneAt(s.Panes[0].Window, 1)
+}
+
 // PaneTypeFromProcess classifies a pane's process into a category.
 // Returns "editor", "agent", "shell", or "process".
 func PaneTypeFromProcess(process string) string {
 	switch process {
 	case "nvim", "vim", "emacs", "nano":
 		return "editor"
-	case "claude", "aider", "codex":
-		return "agent"
 	case "bash", "zsh", "fish", "sh", "":
 		return "shell"
 	default:
 		// Claude Code reports its version (e.g. "2.1.71") as the process name.
-		if isClaudeVersion(process) {
+		if isAgentComm(process) {
 			return "agent"
 		}
 		return "process"
@@ -97,7 +155,7 @@ func isClaudeVersion(s string) bool {
 // ListSessions returns all tmux session names.
 // Returns an empty slice (not an error) if tmux server is not running.
 func ListSessions() ([]string, error) {
-	cmd := exec.Command("tmux", "list-sessions", "-F", "#{session_name}")
+	cmd := tmuxpkg.Command("list-sessions", "-F", "#{session_name}")
 	out, err := cmd.Output()
 	if err != nil {
 		// "no server running" is not an error — just means zero sessions.
@@ -119,178 +177,43 @@ func ListSessions() ([]string, error) {
 	return strings.Split(raw, "\n"), nil
 }
 
-// GetAgentPaneCwd returns the working directory of a specific pane.
-func GetAgentPaneCwd(session string, pane int) string {
-	return tmuxpkg.GetPaneCwdByIndex(session, pane)
-}
-
 // GetAgentSessionID resolves the Claude Code JSONL session UUID for a tmux pane
-// by tracing the process tree and checking open file descriptors via lsof.
-// Claude Code keeps ~/.claude/tasks/<session-uuid>/ open while running.
-// Returns "" if the session ID cannot be determined.
-func GetAgentSessionID(session string, pane int) string {
-	target := fmt.Sprintf("%s:0.%d", session, pane)
-	// Step 1: Get pane PID
-	pidCmd := exec.Command("tmux", "display-message", "-t", target, "-p", "#{pane_pid}")
+// (addressed by pane ID) by tracing the process tree and checking open file
+// descriptors. Claude Code keeps ~/.claude/tasks/<session-uuid>/ open while
+// running. Returns "" if the session ID cannot be determined.
+func GetAgentSessionID(paneID string) string {
+	pidCmd := tmuxpkg.Command("display-message", "-t", paneID, "-p", "#{pane_pid}")
 	pidOut, err := pidCmd.Output()
 	if err != nil {
 		return ""
 	}
-	panePid := strings.TrimSpace(string(pidOut))
-	if panePid == "" {
-		return ""
-	}
-
-	// Step 2: Find the claude process (may be the pane itself or a child)
-	claudePid := findClaudePid(panePid)
-	if claudePid == "" {
-		return ""
-	}
-
-	// Step 3: Extract session UUID from lsof — claude keeps ~/.claude/tasks/<uuid>/ open
-	lsofCmd := exec.Command("lsof", "-p", claudePid)
-	lsofOut, err := lsofCmd.Output()
+	panePid, err := strconv.Atoi(strings.TrimSpace(string(pidOut)))
 	if err != nil {
 		return ""
 	}
-	for _, line := range strings.Split(string(lsofOut), "\n") {
-		if strings.Contains(line, ".claude/tasks/") {
-			// Extract the UUID directory name
-			idx := strings.Index(line, ".claude/tasks/")
-			if idx < 0 {
-				continue
-			}
-			rest := line[idx+len(".claude/tasks/"):]
-			// UUID is up to the next / or end of field
-			if slashIdx := strings.IndexByte(rest, '/'); slashIdx > 0 {
-				rest = rest[:slashIdx]
-			}
-			rest = strings.TrimSpace(rest)
-			if rest != "" && len(rest) > 8 { // sanity check: UUID-like
-				return rest
-			}
-		}
-	}
-	return ""
-}
-
-// findClaudePid finds the claude process PID from a shell PID by checking children.
-func findClaudePid(shellPid string) string {
-	// Check if the shell itself is claude
-	commCmd := exec.Command("ps", "-p", shellPid, "-o", "comm=")
-	commOut, err := commCmd.Output()
-	if err == nil {
-		comm := strings.TrimSpace(string(commOut))
-		if comm == "claude" || isClaudeVersion(comm) {
-			return shellPid
-		}
-	}
-	// Check children
-	pgrepCmd := exec.Command("pgrep", "-P", shellPid)
-	pgrepOut, err := pgrepCmd.Output()
+	procs, err := loadProcessTable()
 	if err != nil {
 		return ""
 	}
-	for _, pid := range strings.Split(strings.TrimSpace(string(pgrepOut)), "\n") {
-		pid = strings.TrimSpace(pid)
-		if pid == "" {
-			continue
-		}
-		commCmd := exec.Command("ps", "-p", pid, "-o", "comm=")
-		commOut, err := commCmd.Output()
-		if err != nil {
-			continue
-		}
-		comm := strings.TrimSpace(string(commOut))
-		if comm == "claude" || isClaudeVersion(comm) {
-			return pid
-		}
-	}
-	return ""
+	return agentSessionIDForPid(procs, panePid)
 }
 
-// hasAgentChild checks if a shell pane has an agent (claude/aider/codex) as a child process.
-func hasAgentChild(session string, pane int) bool {
-	target := fmt.Sprintf("%s:0.%d", session, pane)
-	pidCmd := exec.Command("tmux", "display-message", "-t", target, "-p", "#{pane_pid}")
-	pidOut, err := pidCmd.Output()
-	if err != nil {
-		return false
-	}
-	panePid := strings.TrimSpace(string(pidOut))
-	if panePid == "" {
-		return false
-	}
-	pgrepCmd := exec.Command("pgrep", "-P", panePid)
-	pgrepOut, err := pgrepCmd.Output()
-	if err != nil {
-		return false
-	}
-	for _, pid := range strings.Split(strings.TrimSpace(string(pgrepOut)), "\n") {
-		pid = strings.TrimSpace(pid)
-		if pid == "" {
-			continue
-		}
-		commCmd := exec.Command("ps", "-p", pid, "-o", "comm=")
-		commOut, err := commCmd.Output()
-		if err != nil {
-			continue
-		}
-		comm := strings.TrimSpace(string(commOut))
-		if comm == "claude" || comm == "aider" || comm == "codex" || isClaudeVersion(comm) {
-			return true
-		}
-	}
-	return false
-}
-
-// GetPaneProcess returns the current command running in a specific pane.
-func GetPaneProcess(session string, pane int) string {
-	target := fmt.Sprintf("%s:0.%d", session, pane)
-	cmd := exec.Command("tmux", "display-message", "-t", target, "-p", "#{pane_current_command}")
-	out, err := cmd.Output()
-	if err != nil {
+// agentSessionIDForPid resolves the Claude Code session UUID for a pane
+// process using an already-loaded process table.
+func agentSessionIDForPid(procs *processTable, panePid int) string {
+	// Find the claude process (may be the pane itself or a descendant)
+	claudePid := procs.findClaudePid(panePid)
+	if claudePid == 0 {
 		return ""
 	}
-	return strings.TrimSpace(string(out))
-}
-
-// GetPaneCount returns the number of panes in a session's first window.
-func GetPaneCount(session string) int {
-	cmd := exec.Command("tmux", "list-panes", "-t", session, "-F", "#{pane_index}")
-	out, err := cmd.Output()
-	if err != nil {
-		return 0
-	}
-	raw := strings.TrimSpace(string(out))
-	if raw == "" {
-		return 0
-	}
-	return len(strings.Split(raw, "\n"))
+	return procs.ClaudeSessionID(claudePid)
 }
 
-// CapturePaneContent captures the visible content of a pane.
-// Falls back to pane 0 if the requested pane fails.
-func CapturePaneContent(session string, pane int) string {
-	target := fmt.Sprintf("%s:0.%d", session, pane)
-	args := tmuxpkg.BuildCapturePaneArgs(target)
-	cmd := exec.Command("tmux", args...)
-	out, err := cmd.Output()
-	if err != nil {
-		// Fall back to pane 0 if the requested pane failed.
-		if pane != 0 {
-			fallbackTarget := fmt.Sprintf("%s:0.0", session)
-			fallbackArgs := tmuxpkg.BuildCapturePaneArgs(fallbackTarget)
-			fallbackCmd := exec.Command("tmux", fallbackArgs...)
-			fallbackOut, fallbackErr := fallbackCmd.Output()
-			if fallbackErr != nil {
-				return ""
-			}
-			return string(fallbackOut)
-		}
-		return ""
-	}
-	return string(out)
+// CaptureScrollback returns count lines of a pane's scrollback starting at
+// line from, numbered from the oldest line tmux still holds; a negative from
+// returns the last count lines. With ansi false the lines are plain text.
+func CaptureScrollback(srv tmuxpkg.Server, paneID string, from, count int, ansi bool) (*tmuxpkg.History, error) {
+	return srv.CaptureHistory(paneID, from, count, ansi)
 }
 
 // GitInfo holds git repository metadata for a session.
@@ -312,7 +235,12 @@ func DetectSessionGitInfo(sessionName string) (gitPath, branch string) {
 // DetectSessionGitInfoFull checks if a session's working directory is inside a git repo
 // and detects whether it is a git worktree.
 func DetectSessionGitInfoFull(sessionName string) GitInfo {
-	cwd := tmuxpkg.GetPaneCwd(sessionName)
+	return DetectGitInfo(tmuxpkg.GetPaneCwd(sessionName))
+}
+
+// DetectGitInfo checks if a directory is inside a git repo and detects
+// whether it is a git worktree.
+func DetectGitInfo(cwd string) GitInfo {
 	if cwd == "" {
 		return GitInfo{}
 	}
@@ -364,10 +292,10 @@ func DetectSessionGitInfoFull(sessionName string) GitInfo {
 	return info
 }
 
-// KillSession kills a tmux session by name and optionally cleans up an associated git worktree.
+// KillSession kills a tmux session on the given server and optionally cleans up an associated git worktree.
 // gitPath is the main repo path used with -C so git commands run in the correct repo.
-func KillSession(name string, cleanupWorktree bool, worktreePath, branch, gitPath string) error {
-	if err := tmuxpkg.KillSession(name); err != nil {
+func KillSession(srv tmuxpkg.Server, name string, cleanupWorktree bool, worktreePath, branch, gitPath string) error {
+	if err := srv.KillSession(name); err != nil {
 		return fmt.Errorf("kill session %q: %w", name, err)
 	}
 
@@ -425,26 +353,45 @@ func CreateSession(name, dir, leftCmd, rightCmd string) error {
 	if tmuxpkg.SessionExists(name) {
 		return fmt.Errorf("session %q already exists", name)
 	}
-	return tmuxpkg.CreateTwoPaneSession(name, dir, leftCmd, rightCmd)
+	_, _, err := tmuxpkg.CreateTwoPaneSession(name, dir, leftCmd, rightCmd)
+	return err
 }
 
-// SendToPane sends text (followed by Enter) to a specific pane in a session.
-func SendToPane(session string, pane int, text string) error {
-	target := fmt.Sprintf("%s:0.%d", session, pane)
-	return tmuxpkg.SendKeys(target, text)
+// CreateLayoutSession creates a new tmux session laid out as described.
+// Returns an error if the session already exists.
+func CreateLayoutSession(name, dir string, layout tmuxpkg.Layout) error {
+	if tmuxpkg.SessionExists(name) {
+		return fmt.Errorf("session %q already exists", name)
+	}
+	_, _, err := tmuxpkg.CreateLayoutSession(name, dir, layout)
+	return err
+}
+
+// SendToPane sends text (followed by Enter) to a pane on the given server,
+// addressed by pane ID. The text is pasted through a tmux buffer, so
+// multi-line prompts keep their formatting.
+func SendToPane(srv tmuxpkg.Server, paneID string, text string) error {
+	return srv.PasteText(paneID, text)
 }
 
 // AnswerPaneFreeText navigates an interactive AskUserQuestion prompt to "Other",
 // selects it, then types the given text.
-func AnswerPaneFreeText(session string, pane int, optionCount int, text string) error {
-	target := fmt.Sprintf("%s:0.%d", session, pane)
-	return tmuxpkg.AnswerPromptFreeText(target, optionCount, text)
+func AnswerPaneFreeText(srv tmuxpkg.Server, paneID string, optionCount int, text string) error {
+	return srv.AnswerPromptFreeText(paneID, optionCount, text)
 }
 
-// TmuxRunning returns true if the tmux server is running (has at least one session).
-func TmuxRunning() bool {
-	cmd := exec.Command("tmux", "list-sessions")
-	return cmd.Run() == nil
+// TmuxRunning returns true if any of the given tmux servers is running (has
+// at least one session). With no servers it checks the default server.
+func TmuxRunning(servers ...tmuxpkg.Server) bool {
+	if len(servers) == 0 {
+		servers = []tmuxpkg.Server{tmuxpkg.DefaultServer()}
+	}
+	for _, srv := range servers {
+		if srv.Command("list-sessions").Run() == nil {
+			return true
+		}
+	}
+	return false
 }
 
 // GhAvailable returns true if the GitHub CLI (gh) is on $PATH.
@@ -86,7 +86,7 @@ func TestSessionStruct(t *testing.T) {
 	}
 
 	// Verify exported JSON fields are present
-	expectedFields := []string{"name", "status", "branch", "isWorktree", "isGitRepo", "lastChanged", "panes", "diff", "pr"}
+	expectedFields := []string{"name", "status", "branch", "isWorktree", "isGitRepo", "lastChanged", "panes", "windows", "diff", "pr"}
 	for _, field := range expectedFields {
 		if _, ok := m[field]; !ok {
 			t.Errorf("expected JSON field %q not found", field)
@@ -136,6 +136,39 @@ func TestSessionStruct(t *testing.T) {
 	}
 }
 
+func TestSessionFindPane(t *testing.T) {
+	s := Session{Panes: []Pane{
+		{Window: 0, Index: 0, ID: "%1", Type: "editor"},
+		{Window: 0, Index: 1, ID: "%2", Type: "shell"},
+		{Window: 1, Index: 0, ID: "%7", Type: "agent"},
+	}}
+	if p := s.FindPane("%7"); p == nil || p.Window != 1 {
+		t.Errorf("FindPane(%%7) = %+v, want pane in window 1", p)
+	}
+	if p := s.FindPane("%99"); p != nil {
+		t.Errorf("FindPane(%%99) = %+v, want nil", p)
+	}
+	if p := s.FindPaneAt(0, 1); p == nil || p.ID != "%2" {
+		t.Errorf("FindPaneAt(0, 1) = %+v, want %%2", p)
+	}
+	if p := s.AgentPane(); p == nil || p.ID != "%7" {
+		t.Errorf("AgentPane() = %+v, want agent in second window", p)
+	}
+}
+
+func TestSessionAgentPaneFallback(t *testing.T) {
+	s := Session{Panes: []Pane{
+		{Window: 1, Index: 0, ID: "%1", Type: "editor"},
+		{Window: 1, Index: 1, ID: "%2", Type: "shell"},
+	}}
+	if p := s.AgentPane(); p == nil || p.ID != "%2" {
+		t.Errorf("AgentPane() = %+v, want pane 1 of first window", p)
+	}
+	if p := (&Session{}).AgentPane(); p != nil {
+		t.Errorf("AgentPane() on empty session = %+v, want nil", p)
+	}
+}
+
 func TestSessionStructOmitempty(t *testing.T) {
 	s := Session{
 		Name:   "minimal",
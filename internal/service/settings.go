@@ -0,0 +1,278 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/pathutil"
+)
+
+// Thresholds are the timings used to infer a session's status: how long its
+// content must be unchanged to count as idle or done, and how long an agent
+// may stay idle before it is reported stuck.
+type Thresholds struct {
+	IdleAfter  time.Duration
+	DoneAfter  time.Duration
+	StuckAfter time.Duration
+}
+
+// DefaultThresholds apply when nothing is configured.
+var DefaultThresholds = Thresholds{
+	IdleAfter:  30 * time.Second,
+	DoneAfter:  60 * time.Second,
+	StuckAfter: 5 * time.Minute,
+}
+
+// Seconds returns the thresholds as settings in whole seconds.
+func (th Thresholds) Seconds() StatusSettings {
+	return StatusSettings{
+		IdleAfterS:  int(th.IdleAfter / time.Second),
+		DoneAfterS:  int(th.DoneAfter / time.Second),
+		StuckAfterS: int(th.StuckAfter / time.Second),
+	}
+}
+
+// StatusSettings overrides status thresholds for a session or repo, in
+// seconds. Zero fields inherit the value from the next level down.
+type StatusSettings struct {
+	IdleAfterS  int `json:"idleAfterS,omitempty"`
+	DoneAfterS  int `json:"doneAfterS,omitempty"`
+	StuckAfterS int `json:"stuckAfterS,omitempty"`
+}
+
+// apply returns th with the non-zero fields of s set.
+func (s StatusSettings) apply(th Thresholds) Thresholds {
+	if s.IdleAfterS > 0 {
+		th.IdleAfter = time.Duration(s.IdleAfterS) * time.Second
+	}
+	if s.DoneAfterS > 0 {
+		th.DoneAfter = time.Duration(s.DoneAfterS) * time.Second
+	}
+	if s.StuckAfterS > 0 {
+		th.StuckAfter = time.Duration(s.StuckAfterS) * time.Second
+	}
+	return th
+}
+
+// Budget caps the estimated cost of a session's agents, in USD. When it is
+// exceeded a BudgetExceededEvent is published and, with Pause, the agents
+// are interrupted.
+type Budget struct {
+	USD   float64 `json:"usd"`
+	Pause bool    `json:"pause,omitempty"`
+}
+
+// settingsFile is the on-disk JSON format of a SettingsStore.
+type settingsFile struct {
+	Sessions map[string]StatusSettings `json:"sessions,omitempty"`
+	Repos    map[string]StatusSettings `json:"repos,omitempty"`
+	Budgets  map[string]Budget         `json:"budgets,omitempty"`
+}
+
+// SettingsStore holds the status threshold overrides set at runtime through
+// the API, per session and per repo, persisted to a JSON file. It resolves
+// the thresholds for a session on top of the dash config:
+// session override > repo override > dash.repos > dash. Session overrides
+// and budgets are dropped when the session ends, so a new session reusing
+// the name doesn't inherit them.
+type SettingsStore struct {
+	path string
+	dash config.DashConfig
+
+	mu      sync.Mutex
+	file    settingsFile
+	modTime time.Time // of the file when last read or written
+	bad     error     // why the file couldn't be parsed; writing would lose it
+}
+
+// SettingsPath returns the file runtime settings are kept in
+// (~/.tsp/session-settings.json).
+func SettingsPath() string {
+	return filepath.Join(config.TspDir(), "session-settings.json")
+}
+
+// NewSettingsStore creates a store backed by the JSON file at path. The file
+// does not need to exist yet.
+func NewSettingsStore(path string, dash config.DashConfig) *SettingsStore {
+	s := &SettingsStore{path: path, dash: dash}
+	s.reload()
+	return s
+}
+
+// reload re-reads the file if another process (such as tsp spawn) changed
+// it since it was last read or written. Callers must hold s.mu, except in
+// NewSettingsStore.
+func (s *SettingsStore) reload() {
+	info, err := os.Stat(s.path)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+	s.modTime = info.ModTime()
+	var file settingsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("[settings] %s: %v; not saving settings until it is fixed", s.path, err)
+		s.bad = err
+		return
+	}
+	s.file, s.bad = file, nil
+}
+
+// Session returns the overrides set for a session.
+func (s *SettingsStore) Session(name string) StatusSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Sessions[name]
+}
+
+// Repo returns the overrides set at runtime for a repo path.
+func (s *SettingsStore) Repo(repo string) StatusSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Repos[repo]
+}
+
+// SetSession replaces the overrides for a session and saves the store. Empty
+// settings remove the entry.
+func (s *SettingsStore) SetSession(name string, settings StatusSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	s.file.Sessions = setOrDelete(s.file.Sessions, name, settings)
+	return s.write()
+}
+
+// SetRepo replaces the runtime overrides for a repo path and saves the
+// store. Empty settings remove the entry.
+func (s *SettingsStore) SetRepo(repo string, settings StatusSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	s.file.Repos = setOrDelete(s.file.Repos, repo, settings)
+	return s.write()
+}
+
+// Budget returns the budget set for a session, and false if it has none.
+func (s *SettingsStore) Budget(name string) (Budget, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	b, ok := s.file.Budgets[name]
+	return b, ok
+}
+
+// SetBudget replaces the budget of a session and saves the store. A zero
+// budget removes it.
+func (s *SettingsStore) SetBudget(name string, b Budget) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	if b.USD <= 0 {
+		delete(s.file.Budgets, name)
+	} else {
+		if s.file.Budgets == nil {
+			s.file.Budgets = make(map[string]Budget)
+		}
+		s.file.Budgets[name] = b
+	}
+	return s.write()
+}
+
+func setOrDelete(m map[string]StatusSettings, key string, v StatusSettings) map[string]StatusSettings {
+	if v == (StatusSettings{}) {
+		delete(m, key)
+		return m
+	}
+	if m == nil {
+		m = make(map[string]StatusSettings)
+	}
+	m[key] = v
+	return m
+}
+
+// DeleteSession removes the overrides and budget of a session.
+func (s *SettingsStore) DeleteSession(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	_, hasSettings := s.file.Sessions[name]
+	_, hasBudget := s.file.Budgets[name]
+	if !hasSettings && !hasBudget {
+		return nil
+	}
+	delete(s.file.Sessions, name)
+	delete(s.file.Budgets, name)
+	return s.write()
+}
+
+func (s *SettingsStore) write() error {
+	if s.bad != nil {
+		return fmt.Errorf("%s is invalid, not overwriting it: %w", s.path, s.bad)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return err
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// Thresholds resolves the thresholds for a session whose repo (or working
+// directory) is repo. Repo overrides match the repo or any directory above
+// it, the most specific one winning.
+func (s *SettingsStore) Thresholds(session, repo string) Thresholds {
+	th := StatusSettings(s.dash.StatusThresholds).apply(DefaultThresholds)
+
+	cfgRepos := make(map[string]StatusSettings, len(s.dash.Repos))
+	for path, t := range s.dash.Repos {
+		cfgRepos[pathutil.ExpandPath(path)] = StatusSettings(t)
+	}
+	if o, ok := matchRepo(cfgRepos, repo); ok {
+		th = o.apply(th)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o, ok := matchRepo(s.file.Repos, repo); ok {
+		th = o.apply(th)
+	}
+	return s.file.Sessions[session].apply(th)
+}
+
+// matchRepo returns the overrides for the longest path in m that is repo or
+// one of its parents.
+func matchRepo(m map[string]StatusSettings, repo string) (StatusSettings, bool) {
+	var found StatusSettings
+	best, ok := "", false
+	if repo == "" {
+		return found, false
+	}
+	for path, o := range m {
+		path = filepath.Clean(path)
+		if repo != path && !strings.HasPrefix(repo, path+string(filepath.Separator)) {
+			continue
+		}
+		if !ok || len(path) > len(best) {
+			found, best, ok = o, path, true
+		}
+	}
+	return found, ok
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+)
+
+func TestSettingsStoreThresholds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session-settings.json")
+	dash := config.DashConfig{
+		StatusThresholds: config.StatusThresholds{IdleAfterS: 20},
+		Repos: map[string]config.StatusThresholds{
+			"/work/app":      {DoneAfterS: 120},
+			"/work/app/slow": {DoneAfterS: 600},
+		},
+	}
+	s := NewSettingsStore(path, dash)
+
+	th := s.Thresholds("other", "/elsewhere")
+	want := Thresholds{IdleAfter: 20 * time.Second, DoneAfter: 60 * time.Second, StuckAfter: 5 * time.Minute}
+	if th != want {
+		t.Errorf("dash thresholds = %+v, want %+v", th, want)
+	}
+	if th := s.Thresholds("other", "/work/app/sub"); th.DoneAfter != 120*time.Second {
+		t.Errorf("repo DoneAfter = %v, want 2m", th.DoneAfter)
+	}
+	if th := s.Thresholds("other", "/work/app/slow"); th.DoneAfter != 600*time.Second {
+		t.Errorf("nested repo DoneAfter = %v, want 10m", th.DoneAfter)
+	}
+	if th := s.Thresholds("other", "/work/application"); th.DoneAfter != 60*time.Second {
+		t.Errorf("sibling path DoneAfter = %v, want 1m", th.DoneAfter)
+	}
+
+	if err := s.SetRepo("/work/app", StatusSettings{DoneAfterS: 90, StuckAfterS: 900}); err != nil {
+		t.Fatalf("SetRepo: %v", err)
+	}
+	if err := s.SetSession("feat", StatusSettings{StuckAfterS: 60}); err != nil {
+		t.Fatalf("SetSession: %v", err)
+	}
+	th = s.Thresholds("feat", "/work/app")
+	want = Thresholds{IdleAfter: 20 * time.Second, DoneAfter: 90 * time.Second, StuckAfter: time.Minute}
+	if th != want {
+		t.Errorf("overridden thresholds = %+v, want %+v", th, want)
+	}
+
+	// Overrides survive a restart.
+	reloaded := NewSettingsStore(path, dash)
+	if got := reloaded.Thresholds("feat", "/work/app"); got != want {
+		t.Errorf("reloaded thresholds = %+v, want %+v", got, want)
+	}
+
+	// Empty settings remove the override.
+	if err := reloaded.SetSession("feat", StatusSettings{}); err != nil {
+		t.Fatalf("SetSession: %v", err)
+	}
+	if got := NewSettingsStore(path, dash).Session("feat"); got != (StatusSettings{}) {
+		t.Errorf("cleared session settings = %+v", got)
+	}
+}
+
+func TestSettingsStoreBudgets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session-settings.json")
+	s := NewSettingsStore(path, config.DashConfig{})
+	if _, ok := s.Budget("app-feat"); ok {
+		t.Fatal("expected no budget")
+	}
+
+	// Budgets set by another process (tsp spawn) are picked up.
+	other := NewSettingsStore(path, config.DashConfig{})
+	if err := other.SetBudget("app-feat", Budget{USD: 5, Pause: true}); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the mtime differs even on coarse filesystems.
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if b, ok := s.Budget("app-feat"); !ok || b != (Budget{USD: 5, Pause: true}) {
+		t.Errorf("Budget = %+v, %v, want $5 with pause", b, ok)
+	}
+
+	// Saving settings keeps budgets written elsewhere.
+	if err := s.SetSession("app-feat", StatusSettings{IdleAfterS: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := NewSettingsStore(path, config.DashConfig{}).Budget("app-feat"); !ok {
+		t.Error("budget lost when saving session settings")
+	}
+
+	if err := s.SetBudget("app-feat", Budget{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := NewSettingsStore(path, config.DashConfig{}).Budget("app-feat"); ok {
+		t.Error("zero budget should remove it")
+	}
+}
+
+func TestSettingsStoreDeleteSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session-settings.json")
+	s := NewSettingsStore(path, config.DashConfig{})
+	s.SetSession("app-feat", StatusSettings{IdleAfterS: 10})
+	s.SetBudget("app-feat", Budget{USD: 5})
+	s.SetRepo("/src/app", StatusSettings{IdleAfterS: 20})
+
+	if err := s.DeleteSession("app-feat"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewSettingsStore(path, config.DashConfig{})
+	if _, ok := reloaded.Budget("app-feat"); ok || reloaded.Session("app-feat") != (StatusSettings{}) {
+		t.Error("session settings and budget should be gone")
+	}
+	if reloaded.Repo("/src/app").IdleAfterS != 20 {
+		t.Error("repo settings should be kept")
+	}
+}
+
+func TestSettingsStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session-settings.json")
+	os.WriteFile(path, []byte(`{"sessions": {"app-feat": {"idleAfterS": 10}},`), 0600)
+	s := NewSettingsStore(path, config.DashConfig{})
+	if err := s.SetBudget("app-feat", Budget{USD: 5}); err == nil {
+		t.Error("expected an error saving over an invalid file")
+	}
+	if data, _ := os.ReadFile(path); !strings.HasSuffix(string(data), ",") {
+		t.Errorf("invalid file was overwritten: %s", data)
+	}
+
+	// Once fixed, saving works again.
+	os.WriteFile(path, []byte(`{"sessions": {"app-feat": {"idleAfterS": 10}}}`), 0600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if err := s.SetBudget("app-feat", Budget{USD: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewSettingsStore(path, config.DashConfig{}).Session("app-feat"); got.IdleAfterS != 10 {
+		t.Errorf("session settings = %+v, want them kept", got)
+	}
+}
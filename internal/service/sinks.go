@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/device"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+const defaultNtfyURL = "https://ntfy.sh"
+
+// ErrNoPushTokens is returned by an Expo sink when no paired device has
+// registered for push notifications.
+var ErrNoPushTokens = errors.New("no push tokens registered — open the app first")
+
+// notificationCategories are the kinds of notification the Notifier sends.
+var notificationCategories = []string{"done", "waiting", "error"}
+
+// Notification is a message for the user about a session.
+type Notification struct {
+	Category string // done, waiting or error
+	Session  string
+	Title    string
+	Body     string
+	Priority string            // "high" when the user is needed
+	Data     map[string]string // passed on to the mobile app
+	Server   tmuxpkg.Server    // of the session
+}
+
+// NotificationSink delivers notifications to one backend.
+type NotificationSink interface {
+	Name() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotificationRoute sends the notifications of some categories (all of
+// them when empty) to a sink.
+type NotificationRoute struct {
+	Sink       NotificationSink
+	Categories []string
+}
+
+// Accepts reports whether the route takes notifications of a category.
+func (r NotificationRoute) Accepts(category string) bool {
+	return len(r.Categories) == 0 || slices.Contains(r.Categories, category)
+}
+
+// NewNotificationRoutes creates the sinks of the config. Expo sinks send to
+// the push tokens of the paired devices in devices.
+func NewNotificationRoutes(cfgs []config.NotificationSinkConfig, devices *device.Store) ([]NotificationRoute, error) {
+	var routes []NotificationRoute
+	seen := make(map[string]bool)
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			cfg.Name = cfg.Type
+		}
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("notification sink %q: duplicate name", cfg.Name)
+		}
+		seen[cfg.Name] = true
+		for _, c := range cfg.Categories {
+			if !slices.Contains(notificationCategories, c) {
+				return nil, fmt.Errorf("notification sink %q: unknown category %q (want done, waiting or error)", cfg.Name, c)
+			}
+		}
+		sink, err := newNotificationSink(cfg, devices)
+		if err != nil {
+			return nil, fmt.Errorf("notification sink %q: %w", cfg.Name, err)
+		}
+		routes = append(routes, NotificationRoute{Sink: sink, Categories: cfg.Categories})
+	}
+	return routes, nil
+}
+
+func newNotificationSink(cfg config.NotificationSinkConfig, devices *device.Store) (NotificationSink, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	token := expandSecret(cfg.Token)
+	switch cfg.Type {
+	case "expo":
+		push := NewPushClient()
+		if cfg.URL != "" {
+			push.url = cfg.URL
+		}
+		return &ExpoSink{name: cfg.Name, push: push, devices: devices}, nil
+	case "ntfy":
+		if cfg.Topic == "" {
+			return nil, errors.New("topic is required")
+		}
+		url := cmp.Or(cfg.URL, defaultNtfyURL)
+		return &NtfySink{name: cfg.Name, url: strings.TrimRight(url, "/") + "/" + cfg.Topic, token: token, client: client}, nil
+	case "gotify":
+		if cfg.URL == "" || token == "" {
+			return nil, errors.New("url and token are required")
+		}
+		return &GotifySink{name: cfg.Name, url: strings.TrimRight(cfg.URL, "/") + "/message", token: token, client: client}, nil
+	case "http":
+		if cfg.URL == "" {
+			return nil, errors.New("url is required")
+		}
+		switch cfg.Format {
+		case "", "json", "slack", "discord":
+		default:
+			return nil, fmt.Errorf("unknown format %q (want json, slack or discord)", cfg.Format)
+		}
+		return &HTTPSink{name: cfg.Name, url: cfg.URL, format: cmp.Or(cfg.Format, "json"), client: client}, nil
+	case "desktop":
+		return &DesktopSink{name: cfg.Name, command: cmp.Or(cfg.Command, "notify-send")}, nil
+	case "tmux":
+		return &TmuxSink{name: cfg.Name, popup: cfg.Popup}, nil
+	}
+	return nil, fmt.Errorf("unknown type %q (want expo, ntfy, gotify, http, desktop or tmux)", cfg.Type)
+}
+
+// expandSecret returns a secret from the config, reading "$VAR" from the
+// environment.
+func expandSecret(s string) string {
+	if strings.HasPrefix(s, "$") {
+		return os.Getenv(s[1:])
+	}
+	return s
+}
+
+// ExpoSink sends push notifications to the paired devices through the Expo
+// Push Service.
+type ExpoSink struct {
+	name    string
+	push    *PushClient
+	devices *device.Store
+}
+
+// NewExpoSink creates the sink used when no sinks are configured.
+func NewExpoSink(push *PushClient, devices *device.Store) *ExpoSink {
+	return &ExpoSink{name: "expo", push: push, devices: devices}
+}
+
+func (s *ExpoSink) Name() string { return s.name }
+
+func (s *ExpoSink) Notify(ctx context.Context, n Notification) error {
+	var messages []PushMessage
+	for _, token := range s.devices.PushTokens() {
+		messages = append(messages, PushMessage{
+			To:         token,
+			Title:      n.Title,
+			Body:       n.Body,
+			Data:       n.Data,
+			Sound:      "default",
+			Priority:   n.Priority,
+			CategoryID: n.Category,
+		})
+	}
+	if len(messages) == 0 {
+		return ErrNoPushTokens
+	}
+	return s.push.Send(ctx, messages)
+}
+
+// NtfySink publishes to an ntfy topic.
+type NtfySink struct {
+	name   string
+	url    string // of the topic
+	token  string
+	client *http.Client
+}
+
+func (s *NtfySink) Name() string { return s.name }
+
+func (s *NtfySink) Notify(ctx context.Context, n Notification) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, strings.NewReader(n.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", n.Title)
+	req.Header.Set("Tags", "tsp,"+n.Category)
+	if n.Priority == "high" {
+		req.Header.Set("Priority", "high")
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return doNotify(s.client, req)
+}
+
+// GotifySink sends to a Gotify server as an application.
+type GotifySink struct {
+	name   string
+	url    string // of the message endpoint
+	token  string
+	client *http.Client
+}
+
+func (s *GotifySink) Name() string { return s.name }
+
+func (s *GotifySink) Notify(ctx context.Context, n Notification) error {
+	priority := 5
+	if n.Priority == "high" {
+		priority = 8
+	}
+	req, err := newJSONRequest(ctx, s.url, map[string]any{
+		"title":    n.Title,
+		"message":  n.Body,
+		"priority": priority,
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Gotify-Key", s.token)
+	return doNotify(s.client, req)
+}
+
+// HTTPSink POSTs notifications as JSON: the notification itself, or a
+// Slack or Discord incoming webhook message.
+type HTTPSink struct {
+	name   string
+	url    string
+	format string // json, slack or discord
+	client *http.Client
+}
+
+func (s *HTTPSink) Name() string { return s.name }
+
+func (s *HTTPSink) Notify(ctx context.Context, n Notification) error {
+	var body any
+	switch s.format {
+	case "slack":
+		body = map[string]string{"text": "*" + n.Title + "*\n" + n.Body}
+	case "discord":
+		body = map[string]string{"content": "**" + n.Title + "**\n" + n.Body}
+	default:
+		body = map[string]any{
+			"category": n.Category,
+			"session":  n.Session,
+			"title":    n.Title,
+			"body":     n.Body,
+			"priority": n.Priority,
+			"data":     n.Data,
+		}
+	}
+	req, err := newJSONRequest(ctx, s.url, body)
+	if err != nil {
+		return err
+	}
+	return doNotify(s.client, req)
+}
+
+func newJSONRequest(ctx context.Context, url string, body any) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// doNotify sends a request, turning a non-2xx response into an error.
+func doNotify(client *http.Client, req *http.Request) error {
+	req.Header.Set("User-Agent", "tsp")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return &webhookStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+// DesktopSink shows notifications on the Linux desktop with notify-send.
+type DesktopSink struct {
+	name    string
+	command string
+}
+
+func (s *DesktopSink) Name() string { return s.name }
+
+func (s *DesktopSink) Notify(ctx context.Context, n Notification) error {
+	urgency := "normal"
+	if n.Priority == "high" {
+		urgency = "critical"
+	}
+	out, err := exec.CommandContext(ctx, s.command, "-a", "tsp", "-u", urgency, n.Title, n.Body).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", s.command, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// TmuxSink shows notifications on every client attached to the session's
+// tmux server, in the status line or in a popup.
+type TmuxSink struct {
+	name  string
+	popup bool
+}
+
+func (s *TmuxSink) Name() string { return s.name }
+
+func (s *TmuxSink) Notify(ctx context.Context, n Notification) error {
+	out, err := n.Server.Command("list-clients", "-F", "#{client_control_mode} #{client_name}").Output()
+	if err != nil {
+		return fmt.Errorf("list-clients: %w", err)
+	}
+	var errs []error
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		control, client, ok := strings.Cut(line, " ")
+		if !ok || control != "0" {
+			continue // the monitor's own control-mode clients
+		}
+		if err := n.Server.Command(tmuxNotifyArgs(client, n, s.popup)...).Run(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", client, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tmuxNotifyArgs returns the tmux args that show a notification on a
+// client.
+func tmuxNotifyArgs(client string, n Notification, popup bool) []string {
+	if popup {
+		text := n.Title + "\n\n" + n.Body + "\n\n(press enter)"
+		return []string{
+			"display-popup", "-c", client, "-w", "60", "-h", "8",
+			"-E", "printf '%s\\n' " + shellQuote(text) + "; read _",
+		}
+	}
+	// display-message expands formats, so # must be escaped.
+	msg := strings.ReplaceAll(n.Title+": "+n.Body, "#", "##")
+	return []string{"display-message", "-c", client, "-d", "8000", msg}
+}
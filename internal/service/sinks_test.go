@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/device"
+)
+
+// sinkRequest is a request received by a stand-in notification server.
+type sinkRequest struct {
+	path   string
+	header http.Header
+	body   string
+}
+
+func newSinkServer(t *testing.T) (*httptest.Server, func() sinkRequest) {
+	t.Helper()
+	got := make(chan sinkRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- sinkRequest{path: r.URL.Path, header: r.Header, body: string(body)}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() sinkRequest {
+		select {
+		case r := <-got:
+			return r
+		case <-time.After(5 * time.Second):
+			t.Fatal("no request received")
+			return sinkRequest{}
+		}
+	}
+}
+
+func newTestSink(t *testing.T, cfg config.NotificationSinkConfig, devices *device.Store) NotificationSink {
+	t.Helper()
+	routes, err := NewNotificationRoutes([]config.NotificationSinkConfig{cfg}, devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return routes[0].Sink
+}
+
+var testNotification = Notification{
+	Category: "waiting",
+	Session:  "api-auth",
+	Title:    "Input needed: api-auth",
+	Body:     "Do you want to proceed? (y/n)",
+	Priority: "high",
+	Data:     map[string]string{"sessionName": "api-auth"},
+}
+
+func TestNotificationSinks(t *testing.T) {
+	srv, next := newSinkServer(t)
+	ctx := context.Background()
+
+	t.Run("expo", func(t *testing.T) {
+		devices := device.NewStore(filepath.Join(t.TempDir(), "devices.json"))
+		devices.Add(device.Device{ID: "d1", Token: "auth", PushToken: "ExponentPushToken[x]"})
+		sink := newTestSink(t, config.NotificationSinkConfig{Type: "expo", URL: srv.URL + "/push"}, devices)
+		if err := sink.Notify(ctx, testNotification); err != nil {
+			t.Fatal(err)
+		}
+		var msgs []PushMessage
+		if err := json.Unmarshal([]byte(next().body), &msgs); err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 1 || msgs[0].To != "ExponentPushToken[x]" || msgs[0].CategoryID != "waiting" || msgs[0].Priority != "high" {
+			t.Errorf("messages = %+v", msgs)
+		}
+	})
+
+	t.Run("ntfy", func(t *testing.T) {
+		sink := newTestSink(t, config.NotificationSinkConfig{Type: "ntfy", URL: srv.URL, Topic: "agents", Token: "tk"}, nil)
+		if err := sink.Notify(ctx, testNotification); err != nil {
+			t.Fatal(err)
+		}
+		r := next()
+		if r.path != "/agents" || r.body != testNotification.Body || r.header.Get("Title") != testNotification.Title ||
+			r.header.Get("Priority") != "high" || r.header.Get("Authorization") != "Bearer tk" {
+			t.Errorf("request = %+v", r)
+		}
+	})
+
+	t.Run("gotify", func(t *testing.T) {
+		t.Setenv("TSP_TEST_GOTIFY", "app-token")
+		sink := newTestSink(t, config.NotificationSinkConfig{Type: "gotify", URL: srv.URL, Token: "$TSP_TEST_GOTIFY"}, nil)
+		if err := sink.Notify(ctx, testNotification); err != nil {
+			t.Fatal(err)
+		}
+		r := next()
+		var body struct {
+			Title    string `json:"title"`
+			Message  string `json:"message"`
+			Priority int    `json:"priority"`
+		}
+		json.Unmarshal([]byte(r.body), &body)
+		if r.path != "/message" || r.header.Get("X-Gotify-Key") != "app-token" || body.Title != testNotification.Title || body.Priority != 8 {
+			t.Errorf("request = %+v", r)
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		for format, want := range map[string]string{
+			"json":    `"category":"waiting"`,
+			"slack":   `{"text":"*Input needed: api-auth*\nDo you want to proceed? (y/n)"}`,
+			"discord": `{"content":"**Input needed: api-auth**\nDo you want to proceed? (y/n)"}`,
+		} {
+			sink := newTestSink(t, config.NotificationSinkConfig{Type: "http", URL: srv.URL, Format: format}, nil)
+			if err := sink.Notify(ctx, testNotification); err != nil {
+				t.Fatal(err)
+			}
+			if r := next(); !strings.Contains(r.body, want) {
+				t.Errorf("%s body = %s, want %s", format, r.body, want)
+			}
+		}
+	})
+
+	t.Run("desktop", func(t *testing.T) {
+		dir := t.TempDir()
+		script := filepath.Join(dir, "notify-send")
+		out := filepath.Join(dir, "args")
+		os.WriteFile(script, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" > "+out+"\n"), 0755)
+		sink := newTestSink(t, config.NotificationSinkConfig{Type: "desktop", Command: script}, nil)
+		if err := sink.Notify(ctx, testNotification); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(out)
+		want := "-a\ntsp\n-u\ncritical\nInput needed: api-auth\nDo you want to proceed? (y/n)\n"
+		if string(data) != want {
+			t.Errorf("args = %q, want %q", data, want)
+		}
+	})
+}
+
+func TestNotificationSinkErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	sink := newTestSink(t, config.NotificationSinkConfig{Type: "gotify", URL: srv.URL, Token: "x"}, nil)
+	if err := sink.Notify(context.Background(), testNotification); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want a 401", err)
+	}
+
+	for _, cfg := range []config.NotificationSinkConfig{
+		{Type: "pager"},
+		{Type: "ntfy"},
+		{Type: "gotify", URL: "http://localhost"},
+		{Type: "http"},
+		{Type: "http", URL: "http://localhost", Format: "xml"},
+		{Type: "tmux", Categories: []string{"finished"}},
+	} {
+		if _, err := NewNotificationRoutes([]config.NotificationSinkConfig{cfg}, nil); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+	if _, err := NewNotificationRoutes([]config.NotificationSinkConfig{{Type: "tmux"}, {Type: "tmux"}}, nil); err == nil {
+		t.Error("expected an error for duplicate names")
+	}
+}
+
+func TestTmuxNotifyArgs(t *testing.T) {
+	n := Notification{Title: "CI failing: web", Body: "PR #12 checks failing"}
+	got := tmuxNotifyArgs("/dev/pts/3", n, false)
+	want := []string{"display-message", "-c", "/dev/pts/3", "-d", "8000", "CI failing: web: PR ##12 checks failing"}
+	if !slices.Equal(got, want) {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+	got = tmuxNotifyArgs("/dev/pts/3", Notification{Title: "it's done", Body: "ok"}, true)
+	if got[0] != "display-popup" || !strings.Contains(got[len(got)-1], `'it'\''s done`) {
+		t.Errorf("popup args = %q", got)
+	}
+}
+
+// recordingSink records the notifications it gets.
+type recordingSink struct {
+	name string
+	mu   sync.Mutex
+	got  []string
+}
+
+func (s *recordingSink) Name() string { return s.name }
+
+func (s *recordingSink) Notify(ctx context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, n.Category)
+	return nil
+}
+
+func TestNotifierRoutes(t *testing.T) {
+	phone := &recordingSink{name: "phone"}
+	desk := &recordingSink{name: "desk"}
+	n := NewNotifier(nil, nil, nil)
+	n.SetRoutes([]NotificationRoute{
+		{Sink: phone, Categories: []string{"waiting", "error"}},
+		{Sink: desk},
+	})
+	if !n.wants("done") {
+		t.Error("done should be wanted by the catch-all route")
+	}
+	n.send("", Notification{Category: "done"})
+	n.send("", Notification{Category: "error"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		phone.mu.Lock()
+		p := slices.Clone(phone.got)
+		phone.mu.Unlock()
+		desk.mu.Lock()
+		d := slices.Clone(desk.got)
+		desk.mu.Unlock()
+		slices.Sort(d)
+		if len(p) == 1 && len(d) == 2 {
+			if p[0] != "error" || !slices.Equal(d, []string{"done", "error"}) {
+				t.Errorf("phone got %v, desk got %v", p, d)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("phone got %v, desk got %v", p, d)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNotifierTest(t *testing.T) {
+	phone := &recordingSink{name: "phone"}
+	desk := &recordingSink{name: "desk"}
+	devices := device.NewStore(filepath.Join(t.TempDir(), "devices.json"))
+	n := NewNotifier(nil, nil, nil)
+	n.SetRoutes([]NotificationRoute{
+		{Sink: phone, Categories: []string{"waiting"}},
+		{Sink: desk},
+		{Sink: NewExpoSink(NewPushClient(), devices)},
+	})
+
+	results, err := n.Test(context.Background(), Notification{Category: "done", Title: "Test notification"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SinkResult{{Sink: "desk"}, {Sink: "expo", Error: ErrNoPushTokens.Error()}}
+	if !slices.Equal(results, want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+	if len(phone.got) != 0 || !slices.Equal(desk.got, []string{"done"}) {
+		t.Errorf("phone got %v, desk got %v", phone.got, desk.got)
+	}
+
+	if _, err := n.Test(context.Background(), Notification{Category: "finished"}); err == nil {
+		t.Error("expected an error for an unknown category")
+	}
+	n.SetRoutes([]NotificationRoute{{Sink: phone, Categories: []string{"waiting"}}})
+	if _, err := n.Test(context.Background(), Notification{Category: "done"}); err == nil {
+		t.Error("expected an error when no sink takes the category")
+	}
+}
+
+func TestExpoSinkContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+	devices := device.NewStore(filepath.Join(t.TempDir(), "devices.json"))
+	devices.Add(device.Device{ID: "d1", Token: "auth", PushToken: "ExponentPushToken[x]"})
+	sink := newTestSink(t, config.NotificationSinkConfig{Type: "expo", URL: srv.URL}, devices)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := sink.Notify(ctx, testNotification); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's deadline", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Notify took %v, ignoring its context", d)
+	}
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+// Snapshot is a saved copy of the sessions on a tmux server, written by
+// `tsp snapshot save` so they can be rebuilt after a reboot or tmux crash.
+type Snapshot struct {
+	CreatedAt time.Time         `json:"createdAt"`
+	Sessions  []SessionSnapshot `json:"sessions"`
+}
+
+// SessionSnapshot is one saved session.
+type SessionSnapshot struct {
+	Name    string           `json:"name"`
+	Server  tmuxpkg.Server   `json:"server"`
+	Windows []WindowSnapshot `json:"windows"`
+}
+
+// WindowSnapshot is one saved window, with the tmux layout string that
+// restores its pane geometry.
+type WindowSnapshot struct {
+	Index  int            `json:"index"`
+	Name   string         `json:"name"`
+	Active bool           `json:"active"`
+	Layout string         `json:"layout"`
+	Panes  []PaneSnapshot `json:"panes"`
+}
+
+// PaneSnapshot is one saved pane.
+type PaneSnapshot struct {
+	Index          int    `json:"index"`
+	Active         bool   `json:"active"`
+	Cwd            string `json:"cwd"`
+	Process        string `json:"process"`                // pane_current_command
+	StartCommand   string `json:"startCommand,omitempty"` // command the pane was created with
+	Type           string `json:"type"`
+	AgentSessionID string `json:"agentSessionId,omitempty"`
+}
+
+// RestoreResult reports what happened to one session during a restore.
+type RestoreResult struct {
+	Session string `json:"session"`
+	Status  string `json:"status"`  // restored, exists, error
+	Resumed int    `json:"resumed"` // agent panes relaunched with --resume
+	Error   string `json:"error,omitempty"`
+}
+
+// SnapshotDir returns the directory snapshots are saved in (~/.tsp/snapshots).
+func SnapshotDir() string {
+	return filepath.Join(config.TspDir(), "snapshots")
+}
+
+// TakeSnapshot captures every session on the given tmux server, resolving
+// the Claude session ID of each agent pane so it can be resumed later.
+func TakeSnapshot(srv tmuxpkg.Server) (*Snapshot, error) {
+	panes, err := srv.ListAllPanes()
+	if err != nil {
+		return nil, err
+	}
+	// Load the process table only if there is an agent pane to resolve.
+	var procs *processTable
+	agentID := func(pid int) string {
+		if procs == nil {
+			var err error
+			if procs, err = loadProcessTable(); err != nil {
+				procs = parseProcessTable("")
+			}
+		}
+		return agentSessionIDForPid(procs, pid)
+	}
+	snap := buildSnapshot(srv.Named(), panes, agentID)
+	snap.CreatedAt = time.Now()
+	return snap, nil
+}
+
+// buildSnapshot groups a server-wide pane list into session snapshots.
+// agentID resolves the Claude session ID for an agent pane's PID.
+func buildSnapshot(srv tmuxpkg.Server, panes []tmuxpkg.PaneInfo, agentID func(pid int) string) *Snapshot {
+	snap := &Snapshot{}
+	names, bySession := tmuxpkg.GroupPanesBySession(panes)
+	for _, name := range names {
+		sess := SessionSnapshot{Name: name, Server: srv}
+		for _, p := range bySession[name] {
+			n := len(sess.Windows)
+			if n == 0 || sess.Windows[n-1].Index != p.WindowIndex {
+				sess.Windows = append(sess.Windows, WindowSnapshot{
+					Index:  p.WindowIndex,
+					Name:   p.WindowName,
+					Active: p.WindowActive,
+					Layout: p.WindowLayout,
+				})
+				n++
+			}
+			pane := PaneSnapshot{
+				Index:        p.PaneIndex,
+				Active:       p.PaneActive,
+				Cwd:          p.Cwd,
+				Process:      p.Command,
+				StartCommand: p.StartCommand,
+				Type:         PaneTypeFromProcess(p.Command),
+			}
+			if pane.Type == "agent" {
+				pane.AgentSessionID = agentID(p.PID)
+			}
+			sess.Windows[n-1].Panes = append(sess.Windows[n-1].Panes, pane)
+		}
+		snap.Sessions = append(snap.Sessions, sess)
+	}
+	return snap
+}
+
+// SaveSnapshot writes a snapshot to dir as <name>.json. An empty name uses
+// the snapshot's creation time. Returns the path written.
+func SaveSnapshot(dir string, snap *Snapshot, name string) (string, error) {
+	if name == "" {
+		name = snap.CreatedAt.Format("20060102-150405")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+".json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ListSnapshots returns the names of the snapshots in dir, newest first.
+func ListSnapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	type named struct {
+		name string
+		mod  time.Time
+	}
+	var snaps []named
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, named{strings.TrimSuffix(e.Name(), ".json"), info.ModTime()})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].mod.After(snaps[j].mod) })
+	names := make([]string, len(snaps))
+	for i, s := range snaps {
+		names[i] = s.name
+	}
+	return names, nil
+}
+
+// LoadSnapshot reads a snapshot by name from dir, or from a path if name
+// ends in .json. An empty name loads the newest snapshot.
+func LoadSnapshot(dir, name string) (*Snapshot, error) {
+	path := name
+	if name == "" {
+		names, err := ListSnapshots(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no snapshots in %s", dir)
+		}
+		name = names[0]
+	}
+	if !strings.HasSuffix(name, ".json") {
+		path = filepath.Join(dir, name+".json")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &snap, nil
+}
+
+// RestoreSnapshot recreates the sessions of a snapshot. Sessions that already
+// exist are left alone. If only is non-empty, just those sessions are
+// restored. Agent panes are relaunched with `claude --resume <id>` when their
+// session ID was saved, and `claude --continue` otherwise.
+func RestoreSnapshot(snap *Snapshot, cfg *config.Config, only []string) []RestoreResult {
+	var results []RestoreResult
+	for _, sess := range snap.Sessions {
+		if len(only) > 0 && !slices.Contains(only, sess.Name) {
+			continue
+		}
+		result := RestoreResult{Session: sess.Name}
+		srv := sess.Server
+		if srv.Command("has-session", "-t", "="+sess.Name).Run() == nil {
+			result.Status = "exists"
+			results = append(results, result)
+			continue
+		}
+
+		layout, dir := restoreLayout(sess, cfg.Spawn.AgentCommand)
+		panes, _, err := srv.CreateLayoutSession(sess.Name, dir, layout)
+		if err != nil {
+			result.Status = "error"
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		// Re-select the panes and window that were active when saved.
+		i := 0
+		activeWindow := ""
+		for _, w := range sess.Windows {
+			for _, p := range w.Panes {
+				if i < len(panes) {
+					if p.Active {
+						srv.Command("select-pane", "-t", panes[i]).Run()
+					}
+					if w.Active && activeWindow == "" {
+						activeWindow = panes[i]
+					}
+				}
+				if p.AgentSessionID != "" {
+					result.Resumed++
+				}
+				i++
+			}
+		}
+		if activeWindow != "" {
+			srv.Command("select-window", "-t", activeWindow).Run()
+		}
+
+		result.Status = "restored"
+		results = append(results, result)
+	}
+	return results
+}
+
+// restoreLayout turns a saved session into a tmux layout that recreates its
+// windows, panes and geometry. Returns the layout and the session directory
+// (the first pane's cwd).
+func restoreLayout(sess SessionSnapshot, agentCmd string) (tmuxpkg.Layout, string) {
+	var layout tmuxpkg.Layout
+	dir := ""
+	for _, w := range sess.Windows {
+		win := tmuxpkg.LayoutWindow{Name: w.Name, Arrange: w.Layout}
+		for _, p := range w.Panes {
+			if dir == "" {
+				dir = p.Cwd
+			}
+			win.Panes = append(win.Panes, tmuxpkg.LayoutPane{
+				Command: restoreCommand(p, agentCmd),
+				Dir:     p.Cwd,
+			})
+		}
+		if len(win.Panes) > 0 {
+			layout.Windows = append(layout.Windows, win)
+		}
+	}
+	if dir == "" {
+		dir, _ = os.UserHomeDir()
+	}
+	return layout, dir
+}
+
+// restoreCommand picks the command a restored pane should run. Claude agents
+// resume their saved conversation; other panes rerun the command they were
+// started with, editors reopen, and anything else comes back as a shell.
+func restoreCommand(p PaneSnapshot, agentCmd string) string {
+	if p.Type == "agent" && (p.Process == "claude" || isClaudeVersion(p.Process)) {
+		if p.AgentSessionID != "" {
+			return claudeCommand(agentCmd) + " --resume " + shellQuote(p.AgentSessionID)
+		}
+		return claudeCommand(agentCmd) + " --continue"
+	}
+	if p.StartCommand != "" {
+		return p.StartCommand
+	}
+	if p.Type == "editor" || p.Type == "agent" {
+		return p.Process
+	}
+	return ""
+}
+
+// claudeCommand returns the configured agent command if it launches claude
+// (keeping flags like --dangerously-skip-permissions), otherwise plain claude.
+func claudeCommand(agentCmd string) string {
+	if fields := strings.Fields(agentCmd); len(fields) > 0 && filepath.Base(fields[0]) == "claude" {
+		return agentCmd
+	}
+	return "claude"
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+func TestBuildSnapshot(t *testing.T) {
+	panes := []tmuxpkg.PaneInfo{
+		{Session: "app", WindowIndex: 0, WindowName: "main", WindowLayout: "abcd,80x24,0,0", PaneIndex: 0, PID: 10, Command: "nvim", Cwd: "/src/app", StartCommand: "nvim"},
+		{Session: "app", WindowIndex: 0, WindowName: "main", WindowLayout: "abcd,80x24,0,0", PaneIndex: 1, PID: 11, Command: "2.1.71", Cwd: "/src/app", PaneActive: true},
+		{Session: "app", WindowIndex: 2, WindowName: "logs", WindowActive: true, PaneIndex: 0, PID: 12, Command: "zsh", Cwd: "/var/log"},
+		{Session: "other", WindowIndex: 0, PaneIndex: 0, PID: 20, Command: "zsh", Cwd: "/tmp"},
+	}
+	var resolved []int
+	agentID := func(pid int) string {
+		resolved = append(resolved, pid)
+		return "uuid-1234-5678"
+	}
+
+	snap := buildSnapshot(tmuxpkg.Server{Name: "default"}, panes, agentID)
+	if len(snap.Sessions) != 2 || snap.Sessions[0].Name != "app" || snap.Sessions[1].Name != "other" {
+		t.Fatalf("unexpected sessions: %+v", snap.Sessions)
+	}
+	app := snap.Sessions[0]
+	if len(app.Windows) != 2 || app.Windows[0].Layout != "abcd,80x24,0,0" || app.Windows[1].Index != 2 || !app.Windows[1].Active {
+		t.Fatalf("unexpected windows: %+v", app.Windows)
+	}
+	agent := app.Windows[0].Panes[1]
+	if agent.Type != "agent" || agent.AgentSessionID != "uuid-1234-5678" || !agent.Active {
+		t.Errorf("unexpected agent pane: %+v", agent)
+	}
+	if len(resolved) != 1 || resolved[0] != 11 {
+		t.Errorf("expected only the agent pane to be resolved, got %v", resolved)
+	}
+}
+
+func TestRestoreCommand(t *testing.T) {
+	const agentCmd = "claude --dangerously-skip-permissions"
+	tests := []struct {
+		pane PaneSnapshot
+		want string
+	}{
+		{PaneSnapshot{Type: "agent", Process: "2.1.71", AgentSessionID: "abc-123", StartCommand: "claude 'task'"}, agentCmd + " --resume 'abc-123'"},
+		{PaneSnapshot{Type: "agent", Process: "claude"}, agentCmd + " --continue"},
+		{PaneSnapshot{Type: "agent", Process: "aider"}, "aider"},
+		{PaneSnapshot{Type: "editor", Process: "nvim"}, "nvim"},
+		{PaneSnapshot{Type: "process", Process: "node", StartCommand: "npm run dev"}, "npm run dev"},
+		{PaneSnapshot{Type: "process", Process: "node"}, ""},
+		{PaneSnapshot{Type: "shell", Process: "zsh"}, ""},
+	}
+	for _, tt := range tests {
+		if got := restoreCommand(tt.pane, agentCmd); got != tt.want {
+			t.Errorf("restoreCommand(%+v) = %q, want %q", tt.pane, got, tt.want)
+		}
+	}
+	if got := restoreCommand(PaneSnapshot{Type: "agent", Process: "claude", AgentSessionID: "x"}, "aider"); got != "claude --resume 'x'" {
+		t.Errorf("non-claude agent command: got %q", got)
+	}
+}
+
+func TestRestoreLayout(t *testing.T) {
+	sess := SessionSnapshot{Name: "app", Windows: []WindowSnapshot{
+		{Name: "main", Layout: "abcd,80x24,0,0", Panes: []PaneSnapshot{
+			{Cwd: "/src/app", Type: "editor", Process: "nvim"},
+			{Cwd: "/src/app/web", Type: "shell", Process: "zsh"},
+		}},
+		{Name: "empty"},
+	}}
+	layout, dir := restoreLayout(sess, "claude")
+	if dir != "/src/app" {
+		t.Errorf("dir = %q, want /src/app", dir)
+	}
+	if len(layout.Windows) != 1 {
+		t.Fatalf("expected empty windows to be dropped, got %+v", layout.Windows)
+	}
+	w := layout.Windows[0]
+	if w.Name != "main" || w.Arrange != "abcd,80x24,0,0" || len(w.Panes) != 2 {
+		t.Fatalf("unexpected window: %+v", w)
+	}
+	if w.Panes[0].Command != "nvim" || w.Panes[1].Dir != "/src/app/web" {
+		t.Errorf("unexpected panes: %+v", w.Panes)
+	}
+	if err := layout.Validate(); err != nil {
+		t.Errorf("restored layout invalid: %v", err)
+	}
+}
+
+func TestSaveLoadSnapshot(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	if _, err := LoadSnapshot(dir, ""); err == nil {
+		t.Error("expected error with no snapshots")
+	}
+
+	older := &Snapshot{CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Sessions: []SessionSnapshot{{Name: "a"}}}
+	path, err := SaveSnapshot(dir, older, "")
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if filepath.Base(path) != "20260102-030405.json" {
+		t.Errorf("unexpected path %s", path)
+	}
+	os.Chtimes(path, older.CreatedAt, older.CreatedAt)
+
+	newer := &Snapshot{CreatedAt: time.Now(), Sessions: []SessionSnapshot{{Name: "b"}}}
+	if _, err := SaveSnapshot(dir, newer, "latest"); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	names, _ := ListSnapshots(dir)
+	if len(names) != 2 || names[0] != "latest" {
+		t.Errorf("names = %v, want latest first", names)
+	}
+	snap, err := LoadSnapshot(dir, "")
+	if err != nil || snap.Sessions[0].Name != "b" {
+		t.Errorf("load newest: %+v, %v", snap, err)
+	}
+	snap, err = LoadSnapshot(dir, "20260102-030405")
+	if err != nil || snap.Sessions[0].Name != "a" {
+		t.Errorf("load by name: %+v, %v", snap, err)
+	}
+	snap, err = LoadSnapshot(dir, path)
+	if err != nil || snap.Sessions[0].Name != "a" {
+		t.Errorf("load by path: %+v, %v", snap, err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/matteo-hertel/tmux-super-powers/config"
 	"github.com/matteo-hertel/tmux-super-powers/internal/pathutil"
@@ -72,12 +73,39 @@ type SpawnResult struct {
 	GitPath      string `json:"gitPath,omitempty"`
 }
 
+// SpawnLabels returns the labels a spawned session gets automatically:
+// "spawned", its repo (from the repo root) and the layout it was spawned
+// with, when not the default.
+func SpawnLabels(repoRoot, layoutName string) []string {
+	labels := []string{"spawned"}
+	if repoRoot != "" {
+		labels = append(labels, "repo:"+labelValue(filepath.Base(repoRoot)))
+	}
+	if layoutName != "" {
+		labels = append(labels, "layout:"+labelValue(layoutName))
+	}
+	return labels
+}
+
+// labelValue makes a name usable in a label.
+func labelValue(name string) string {
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }), "-")
+}
+
 // SpawnAgents deploys agents with tasks into worktrees (git repos) or
 // directly in the target directory (non-git directories).
 // If repoDir is non-empty, it is used to find the git repo root; otherwise the server's cwd is used.
-func SpawnAgents(tasks []string, baseBranch string, noInstall bool, cfg *config.Config, repoDir string) ([]SpawnResult, error) {
+// layoutName picks a configured layout ("" for the default); it must have an agent pane.
+func SpawnAgents(tasks []string, baseBranch string, noInstall bool, cfg *config.Config, repoDir, layoutName string) ([]SpawnResult, error) {
+	layout, err := ResolveLayout(cfg, layoutName)
+	if err != nil {
+		return nil, err
+	}
+	if !layout.HasAgent() {
+		return nil, fmt.Errorf("layout has no agent pane to run the tasks in")
+	}
+
 	var repoRoot string
-	var err error
 	if repoDir != "" {
 		repoRoot, err = spawnGetRepoRootFrom(repoDir)
 	} else {
@@ -91,7 +119,7 @@ func SpawnAgents(tasks []string, baseBranch string, noInstall bool, cfg *config.
 			dir, _ = os.Getwd()
 		}
 		dir = pathutil.ExpandPath(dir)
-		return spawnDirect(tasks, dir, cfg)
+		return spawnDirect(tasks, dir, layout)
 	}
 
 	repoName := filepath.Base(repoRoot)
@@ -104,7 +132,6 @@ func SpawnAgents(tasks []string, baseBranch string, noInstall bool, cfg *config.
 	}
 
 	worktreeBase := pathutil.ExpandPath(cfg.Spawn.WorktreeBase)
-	agentCmd := cfg.Spawn.AgentCommand
 
 	var results []SpawnResult
 	for _, task := range tasks {
@@ -147,8 +174,12 @@ func SpawnAgents(tasks []string, baseBranch string, noInstall bool, cfg *config.
 		}
 		// Pass the task as a CLI argument to the agent command so it starts
 		// working immediately — avoids all send-keys/Enter issues.
-		agentWithTask := agentCmd + " " + shellQuote(task)
-		tmuxpkg.CreateTwoPaneSession(sessionName, worktreePath, "nvim", agentWithTask)
+		if _, _, err := tmuxpkg.CreateLayoutSession(sessionName, worktreePath, withAgentTask(layout, task)); err != nil {
+			result.Status = "error"
+			result.Error = fmt.Sprintf("session creation failed: %v", err)
+			results = append(results, result)
+			continue
+		}
 
 		result.Status = "ok"
 		results = append(results, result)
@@ -159,9 +190,8 @@ func SpawnAgents(tasks []string, baseBranch string, noInstall bool, cfg *config.
 
 // spawnDirect creates agents directly in a directory without git worktrees.
 // Each task gets its own tmux session running the agent command in the target dir.
-func spawnDirect(tasks []string, dir string, cfg *config.Config) ([]SpawnResult, error) {
+func spawnDirect(tasks []string, dir string, layout tmuxpkg.Layout) ([]SpawnResult, error) {
 	dirName := filepath.Base(dir)
-	agentCmd := cfg.Spawn.AgentCommand
 
 	var results []SpawnResult
 	for _, task := range tasks {
@@ -176,8 +206,7 @@ func spawnDirect(tasks []string, dir string, cfg *config.Config) ([]SpawnResult,
 			tmuxpkg.KillSession(sessionName)
 		}
 
-		agentWithTask := agentCmd + " " + shellQuote(task)
-		if err := tmuxpkg.CreateTwoPaneSession(sessionName, dir, "nvim", agentWithTask); err != nil {
+		if _, _, err := tmuxpkg.CreateLayoutSession(sessionName, dir, withAgentTask(layout, task)); err != nil {
 			result.Status = "error"
 			result.Error = fmt.Sprintf("session creation failed: %v", err)
 		}
@@ -13,6 +13,12 @@ var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]|\x1b\][^\x07]*\x07|\x1b[(
 // InferStatus determines session status from pane content changes.
 // Priority: error > active (content changed) > done (>60s, prompt visible) > idle (>30s) > active
 func InferStatus(prev, current string, lastChanged, now time.Time, errorPatterns []string, promptPattern string) string {
+	return InferStatusWith(prev, current, lastChanged, now, errorPatterns, promptPattern, DefaultThresholds)
+}
+
+// InferStatusWith is InferStatus with the idle and done thresholds taken
+// from th instead of the defaults.
+func InferStatusWith(prev, current string, lastChanged, now time.Time, errorPatterns []string, promptPattern string, th Thresholds) string {
 	// Check for error patterns in the last few lines only (not the entire buffer,
 	// which may contain historical output mentioning errors).
 	lines := strings.Split(strings.TrimRight(current, "\n"), "\n")
@@ -35,7 +41,7 @@ func InferStatus(prev, current string, lastChanged, now time.Time, errorPatterns
 	elapsed := now.Sub(lastChanged)
 	// Check for shell prompt (done state)
 	// Check last several lines to handle status bars below the prompt (e.g. Claude Code).
-	if elapsed > 60*time.Second && promptPattern != "" {
+	if elapsed > th.DoneAfter && promptPattern != "" {
 		if re, err := regexp.Compile(promptPattern); err == nil {
 			check := lines
 			if len(check) > 10 {
@@ -50,8 +56,8 @@ func InferStatus(prev, current string, lastChanged, now time.Time, errorPatterns
 			}
 		}
 	}
-	// Unchanged for >30s -> idle
-	if elapsed > 30*time.Second {
+	// Unchanged for a while -> idle
+	if elapsed > th.IdleAfter {
 		return "idle"
 	}
 	return "active"
@@ -60,45 +66,25 @@ func InferStatus(prev, current string, lastChanged, now time.Time, errorPatterns
 // WaitingPane records which pane is waiting and its prompt text.
 type WaitingPane struct {
 	Index  int
+	ID     string
 	Prompt string
 }
 
 // DetectWaitingPanes checks each agent pane individually for input prompt patterns.
 func DetectWaitingPanes(panes []Pane, inputPatterns []string) []WaitingPane {
+	var res []*regexp.Regexp
+	for _, pattern := range inputPatterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			res = append(res, re)
+		}
+	}
 	var result []WaitingPane
 	for _, pane := range panes {
-		if pane.Type != "agent" || pane.Content == "" {
+		if pane.Type != "agent" {
 			continue
 		}
-		lines := strings.Split(strings.TrimRight(pane.Content, "\n"), "\n")
-		check := lines
-		if len(check) > 10 {
-			check = check[len(check)-10:]
-		}
-		matched := false
-		for _, pattern := range inputPatterns {
-			re, err := regexp.Compile(pattern)
-			if err != nil {
-				continue
-			}
-			for _, line := range check {
-				cleaned := ansiRe.ReplaceAllString(line, "")
-				if re.MatchString(cleaned) {
-					prompt := lines
-					if len(prompt) > 3 {
-						prompt = prompt[len(prompt)-3:]
-					}
-					result = append(result, WaitingPane{
-						Index:  pane.Index,
-						Prompt: strings.Join(prompt, "\n"),
-					})
-					matched = true
-					break
-				}
-			}
-			if matched {
-				break
-			}
+		if prompt, ok := matchInputPrompt(pane.Content, res); ok {
+			result = append(result, WaitingPane{Index: pane.Index, ID: pane.ID, Prompt: prompt})
 		}
 	}
 	return result
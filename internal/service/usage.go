@@ -0,0 +1,182 @@
+package service
+
+import (
+	"maps"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
+	tmuxpkg "github.com/matteo-hertel/tmux-super-powers/internal/tmux"
+)
+
+const (
+	// usageInterval is how often the monitor refreshes token usage.
+	usageInterval = 15 * time.Second
+	// usageTrackerTTL is how long a log's usage tracker is kept once no
+	// report needs it.
+	usageTrackerTTL = time.Hour
+)
+
+// DefaultPrices are the built-in model prices, in USD per million tokens.
+// Keys match any model whose name contains them; the longest match wins.
+var DefaultPrices = map[string]config.ModelPrice{
+	"opus":             {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+	"claude-opus-4-5":  {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50},
+	"sonnet":           {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"haiku":            {Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+	"claude-haiku-4-5": {Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.10},
+}
+
+// PriceTable estimates what tokens cost, by model.
+type PriceTable map[string]config.ModelPrice
+
+// NewPriceTable returns DefaultPrices with the prices from config on top.
+func NewPriceTable(cfg config.UsageConfig) PriceTable {
+	p := maps.Clone(DefaultPrices)
+	maps.Copy(p, cfg.Prices)
+	return p
+}
+
+// Price returns the price of a model, and false if no key matches it.
+func (p PriceTable) Price(model string) (config.ModelPrice, bool) {
+	model = strings.ToLower(model)
+	best, found := "", false
+	for key := range p {
+		if strings.Contains(model, strings.ToLower(key)) && (!found || len(key) > len(best)) {
+			best, found = key, true
+		}
+	}
+	return p[best], found
+}
+
+// Cost estimates the cost in USD of tokens used with a model. Models
+// without a price cost nothing.
+func (p PriceTable) Cost(model string, t agentlog.Tokens) float64 {
+	price, ok := p.Price(model)
+	if !ok {
+		return 0
+	}
+	return (float64(t.Input)*price.Input +
+		float64(t.Output)*price.Output +
+		float64(t.CacheWrite)*price.CacheWrite +
+		float64(t.CacheRead)*price.CacheRead) / 1e6
+}
+
+// LogUsage is the usage of one Claude Code session log.
+type LogUsage struct {
+	ID string `json:"id"`
+	agentlog.Tokens
+	CostUSD float64  `json:"costUsd"`
+	Models  []string `json:"models,omitempty"`
+}
+
+// UsageReport is the token usage of a tmux session: the total over the
+// Claude Code logs of its agent panes, counting only what was used since
+// the session was created.
+type UsageReport struct {
+	agentlog.Tokens
+	CostUSD   float64    `json:"costUsd"`
+	BudgetUSD float64    `json:"budgetUsd,omitempty"`
+	Logs      []LogUsage `json:"logs,omitempty"`
+}
+
+// usageEntry is a log's tracker and when a report last needed it.
+type usageEntry struct {
+	tracker *agentlog.UsageTracker
+	used    time.Time
+}
+
+// UsageCache keeps a usage tracker per log, so each report only reads what
+// was appended since the last one.
+type UsageCache struct {
+	prices PriceTable
+
+	mu       sync.Mutex
+	trackers map[string]*usageEntry // by log path and since
+}
+
+// NewUsageCache creates a cache that prices usage with prices.
+func NewUsageCache(prices PriceTable) *UsageCache {
+	return &UsageCache{prices: prices, trackers: make(map[string]*usageEntry)}
+}
+
+// Report adds up the usage in Claude Code session logs of the entries
+// timestamped at or after since. Logs that do not exist are skipped.
+func (c *UsageCache) Report(logs []string, since time.Time) UsageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+
+	var r UsageReport
+	seen := make(map[string]bool)
+	for _, path := range logs {
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		key := path + "\x00" + since.UTC().Format(time.RFC3339Nano)
+		e := c.trackers[key]
+		if e == nil {
+			e = &usageEntry{tracker: agentlog.NewUsageTracker(path, since)}
+			c.trackers[key] = e
+		}
+		e.used = now
+		byModel, err := e.tracker.Update()
+		if err != nil && len(byModel) == 0 {
+			continue
+		}
+		lu := LogUsage{ID: strings.TrimSuffix(filepath.Base(path), ".jsonl")}
+		for model, t := range byModel {
+			lu.Tokens = lu.Tokens.Add(t)
+			lu.CostUSD += c.prices.Cost(model, t)
+			lu.Models = append(lu.Models, model)
+		}
+		r.Tokens = r.Tokens.Add(lu.Tokens)
+		r.CostUSD += lu.CostUSD
+		r.Logs = append(r.Logs, lu)
+	}
+	for key, e := range c.trackers {
+		if now.Sub(e.used) > usageTrackerTTL {
+			delete(c.trackers, key)
+		}
+	}
+	return r
+}
+
+// sessionUsageLogs returns the Claude Code logs of a session's agent panes.
+// Logs in the same directory belong to whichever session runs them, so
+// usage is only attributed by the pane's own agent session ID.
+func sessionUsageLogs(s *Session) []string {
+	var logs []string
+	for _, p := range s.Panes {
+		if p.Type == "agent" && p.AgentSessionID != "" {
+			logs = append(logs, agentlog.JSONLPath(p.Cwd, p.AgentSessionID))
+		}
+	}
+	return logs
+}
+
+// PaneUsageLogs returns the Claude Code logs of the agents running in
+// panes, for reporting usage without the monitor.
+func PaneUsageLogs(panes []tmuxpkg.PaneInfo) []string {
+	var procs *processTable
+	var logs []string
+	for _, p := range panes {
+		if PaneTypeFromProcess(p.Command) != "agent" {
+			continue
+		}
+		if procs == nil {
+			var err error
+			if procs, err = loadProcessTable(); err != nil {
+				return nil
+			}
+		}
+		if id := agentSessionIDForPid(procs, p.PID); id != "" {
+			logs = append(logs, agentlog.JSONLPath(p.Cwd, id))
+		}
+	}
+	return logs
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+	"github.com/matteo-hertel/tmux-super-powers/internal/agentlog"
+)
+
+func TestPriceTable(t *testing.T) {
+	p := NewPriceTable(config.UsageConfig{Prices: map[string]config.ModelPrice{
+		"sonnet":      {Input: 1, Output: 2},
+		"my-finetune": {Input: 10},
+	}})
+
+	tests := []struct {
+		model string
+		want  float64 // Input price
+		ok    bool
+	}{
+		{"claude-opus-4-1-20250805", 15, true},
+		{"claude-opus-4-5-20251101", 5, true}, // longest key wins
+		{"claude-sonnet-4-5-20250929", 1, true},
+		{"my-finetune-v2", 10, true},
+		{"gpt-4", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := p.Price(tt.model)
+		if ok != tt.ok || price.Input != tt.want {
+			t.Errorf("Price(%q) = %v, %v, want input %v, %v", tt.model, price, ok, tt.want, tt.ok)
+		}
+	}
+	if DefaultPrices["sonnet"].Input != 3 {
+		t.Error("config prices should not change the defaults")
+	}
+
+	tokens := agentlog.Tokens{Input: 1_000_000, Output: 500_000, CacheWrite: 200_000, CacheRead: 2_000_000}
+	// 3 + 7.5 + 0.75 + 0.6
+	if got := NewPriceTable(config.UsageConfig{}).Cost("claude-sonnet-4", tokens); math.Abs(got-11.85) > 1e-9 {
+		t.Errorf("Cost = %v, want 11.85", got)
+	}
+	if got := p.Cost("gpt-4", tokens); got != 0 {
+		t.Errorf("unpriced Cost = %v, want 0", got)
+	}
+}
+
+func TestUsageCacheReport(t *testing.T) {
+	dir := t.TempDir()
+	resumed := filepath.Join(dir, "resumed.jsonl")
+	os.WriteFile(resumed, []byte(
+		`{"type":"assistant","timestamp":"2025-06-01T10:00:00Z","message":{"id":"m1","model":"claude-sonnet-4","usage":{"input_tokens":1000000}}}`+"\n"+
+			`{"type":"assistant","timestamp":"2025-06-02T10:00:00Z","message":{"id":"m2","model":"claude-sonnet-4","usage":{"output_tokens":1000000}}}`+"\n"), 0600)
+	// Another session's log in the same directory is not passed in.
+	os.WriteFile(filepath.Join(dir, "other.jsonl"), []byte(
+		`{"type":"assistant","timestamp":"2025-06-02T10:00:00Z","message":{"id":"m3","model":"claude-sonnet-4","usage":{"output_tokens":5000000}}}`+"\n"), 0600)
+
+	c := NewUsageCache(NewPriceTable(config.UsageConfig{}))
+	created := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	r := c.Report([]string{resumed, resumed, filepath.Join(dir, "missing.jsonl")}, created)
+	if r.Input != 0 || r.Output != 1_000_000 || math.Abs(r.CostUSD-15) > 1e-9 {
+		t.Errorf("report = %+v, want only the output since creation", r)
+	}
+	if len(r.Logs) != 1 || r.Logs[0].ID != "resumed" {
+		t.Errorf("logs = %+v", r.Logs)
+	}
+}
@@ -18,6 +18,7 @@ import (
 // trackedSession holds the lifecycle state for a single spawned session.
 type trackedSession struct {
 	state        string // working, done, pr_polling, watching, fixing_ci, fixing_reviews, green, gave_up, merged, cleanup_done
+	server       string // label of the session's tmux server; empty matches any
 	branch       string
 	worktreePath string
 	gitPath      string
@@ -81,12 +82,14 @@ func (w *Watcher) Stop() {
 	w.saveState()
 }
 
-// Track starts tracking a spawned session.
-func (w *Watcher) Track(sessionName, branch, worktreePath, gitPath string) {
+// Track starts tracking a spawned session on the server with the given
+// label.
+func (w *Watcher) Track(server, sessionName, branch, worktreePath, gitPath string) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	w.tracked[sessionName] = &trackedSession{
 		state:        "working",
+		server:       server,
 		branch:       branch,
 		worktreePath: worktreePath,
 		gitPath:      gitPath,
@@ -118,7 +121,7 @@ func (w *Watcher) HandleEvent(e Event) {
 	switch ev := e.(type) {
 	case StatusChangedEvent:
 		ts, ok := w.tracked[ev.Session]
-		if !ok {
+		if !ok || !sameServer(ts.server, ev.Server) {
 			return
 		}
 		switch ts.state {
@@ -136,11 +139,21 @@ func (w *Watcher) HandleEvent(e Event) {
 		}
 
 	case SessionRemovedEvent:
-		delete(w.tracked, ev.Name)
-		w.saveStateLocked()
+		// A session of the same name on another server is a different one.
+		if ts, ok := w.tracked[ev.Name]; ok && sameServer(ts.server, ev.Server) {
+			delete(w.tracked, ev.Name)
+			w.saveStateLocked()
+		}
 	}
 }
 
+// sameServer reports whether two server labels can be the same server. An
+// empty label (state saved before servers were tracked, or an event from a
+// publisher that doesn't know) matches any.
+func sameServer(a, b string) bool {
+	return a == "" || b == "" || a == b
+}
+
 // pollLoop runs periodic checks for PR/CI/merge status.
 func (w *Watcher) pollLoop() {
 	interval := time.Duration(w.cfg.PollIntervalS) * time.Second
@@ -202,7 +215,7 @@ func (w *Watcher) pollForPR(name string, ts *trackedSession) {
 		ts.pollErrors = 0
 		w.saveStateLocked()
 		w.mu.Unlock()
-		w.bus.Publish(PRDetectedEvent{Session: name, PRNumber: prNumber, URL: prURL})
+		w.bus.Publish(PRDetectedEvent{Session: name, Server: ts.server, PRNumber: prNumber, URL: prURL})
 	} else {
 		w.mu.Lock()
 		if ts.state == "done" {
@@ -237,14 +250,14 @@ func (w *Watcher) pollCIAndReviews(name string, ts *trackedSession) {
 			ts.state = "gave_up"
 			w.saveStateLocked()
 			w.mu.Unlock()
-			w.bus.Publish(CIStatusChangedEvent{Session: name, PRNumber: ts.prNumber, From: prevCI, To: "fail"})
+			w.bus.Publish(CIStatusChangedEvent{Session: name, Server: ts.server, PRNumber: ts.prNumber, From: prevCI, To: "fail"})
 			return
 		}
 		ts.state = "fixing_ci"
 		ts.ciRetries++
 		w.saveStateLocked()
 		w.mu.Unlock()
-		w.bus.Publish(CIStatusChangedEvent{Session: name, PRNumber: ts.prNumber, From: prevCI, To: "fail"})
+		w.bus.Publish(CIStatusChangedEvent{Session: name, Server: ts.server, PRNumber: ts.prNumber, From: prevCI, To: "fail"})
 		w.sendFixCI(name, ts)
 		return
 	}
@@ -257,7 +270,7 @@ func (w *Watcher) pollCIAndReviews(name string, ts *trackedSession) {
 		w.saveStateLocked()
 		w.mu.Unlock()
 		if prevCI != "pass" {
-			w.bus.Publish(CIStatusChangedEvent{Session: name, PRNumber: ts.prNumber, From: prevCI, To: "pass"})
+			w.bus.Publish(CIStatusChangedEvent{Session: name, Server: ts.server, PRNumber: ts.prNumber, From: prevCI, To: "pass"})
 		}
 		// Check for new reviews
 		w.checkReviews(name, ts)
@@ -275,7 +288,7 @@ func (w *Watcher) checkReviews(name string, ts *trackedSession) {
 		ts.state = "fixing_reviews"
 		w.saveStateLocked()
 		w.mu.Unlock()
-		w.bus.Publish(ReviewsChangedEvent{Session: name, PRNumber: ts.prNumber, Count: count, PrevCount: prevCount})
+		w.bus.Publish(ReviewsChangedEvent{Session: name, Server: ts.server, PRNumber: ts.prNumber, Count: count, PrevCount: prevCount})
 		w.sendFixReviews(name, ts)
 		return
 	}
@@ -306,7 +319,7 @@ func (w *Watcher) handleMerged(name string, ts *trackedSession) {
 	w.saveStateLocked()
 	w.mu.Unlock()
 
-	w.bus.Publish(PRMergedEvent{Session: name, PRNumber: ts.prNumber})
+	w.bus.Publish(PRMergedEvent{Session: name, Server: ts.server, PRNumber: ts.prNumber})
 
 	if w.cfg.AutoCleanup {
 		w.cleanup(name, ts)
@@ -314,7 +327,9 @@ func (w *Watcher) handleMerged(name string, ts *trackedSession) {
 }
 
 func (w *Watcher) cleanup(name string, ts *trackedSession) {
-	tmuxpkg.KillSession(name)
+	if srv, ok := w.sessionServer(name, ts); ok {
+		srv.KillSession(name)
+	}
 	if err := exec.Command("git", "-C", ts.gitPath, "worktree", "remove", ts.worktreePath, "--force").Run(); err != nil {
 		exec.Command("git", "-C", ts.gitPath, "worktree", "prune").Run()
 	}
@@ -326,7 +341,7 @@ func (w *Watcher) cleanup(name string, ts *trackedSession) {
 	w.saveStateLocked()
 	w.mu.Unlock()
 
-	w.bus.Publish(CleanupCompletedEvent{Session: name, WorktreePath: ts.worktreePath, Branch: ts.branch})
+	w.bus.Publish(CleanupCompletedEvent{Session: name, Server: ts.server, WorktreePath: ts.worktreePath, Branch: ts.branch})
 }
 
 func (w *Watcher) sendFixCI(name string, ts *trackedSession) {
@@ -339,11 +354,15 @@ func (w *Watcher) sendFixCI(name string, ts *trackedSession) {
 	if len(prompt) > 4000 {
 		prompt = prompt[:4000] + "\n\n[truncated]"
 	}
-	agentPane := w.findAgentPane(name)
-	if err := SendToPane(name, agentPane, prompt); err != nil {
+	srv, agentPane := w.findAgentPane(name, ts)
+	if agentPane == "" {
+		log.Printf("watcher: no agent pane in %s for fix-ci", name)
+		return
+	}
+	if err := SendToPane(srv, agentPane, prompt); err != nil {
 		log.Printf("watcher: failed to send fix-ci to %s: %v", name, err)
 	}
-	w.bus.Publish(FixAttemptedEvent{Session: name, FixType: "ci", Attempt: ts.ciRetries, MaxAttempts: w.cfg.MaxCIRetries})
+	w.bus.Publish(FixAttemptedEvent{Session: name, Server: ts.server, FixType: "ci", Attempt: ts.ciRetries, MaxAttempts: w.cfg.MaxCIRetries})
 }
 
 func (w *Watcher) sendFixReviews(name string, ts *trackedSession) {
@@ -354,27 +373,44 @@ func (w *Watcher) sendFixReviews(name string, ts *trackedSession) {
 	}
 	formatted := FormatPRComments(comments)
 	prompt := "Please address these PR review comments:\n\n" + formatted
-	agentPane := w.findAgentPane(name)
-	if err := SendToPane(name, agentPane, prompt); err != nil {
+	srv, agentPane := w.findAgentPane(name, ts)
+	if agentPane == "" {
+		log.Printf("watcher: no agent pane in %s for fix-reviews", name)
+		return
+	}
+	if err := SendToPane(srv, agentPane, prompt); err != nil {
 		log.Printf("watcher: failed to send fix-reviews to %s: %v", name, err)
 	}
-	w.bus.Publish(FixAttemptedEvent{Session: name, FixType: "reviews", Attempt: 1, MaxAttempts: 1})
+	w.bus.Publish(FixAttemptedEvent{Session: name, Server: ts.server, FixType: "reviews", Attempt: 1, MaxAttempts: 1})
 }
 
-func (w *Watcher) findAgentPane(name string) int {
+// findAgentPane returns the server and pane ID of the session's agent pane,
+// or an empty pane ID if the monitor has no such pane.
+func (w *Watcher) findAgentPane(name string, ts *trackedSession) (tmuxpkg.Server, string) {
 	if w.monitor == nil {
-		return 1
+		return tmuxpkg.DefaultServer(), ""
 	}
-	s := w.monitor.FindSession(name)
+	s := w.monitor.FindSessionOn(ts.server, name)
 	if s == nil {
-		return 1
+		return tmuxpkg.DefaultServer(), ""
 	}
-	for _, p := range s.Panes {
-		if p.Type == "agent" {
-			return p.Index
+	if p := s.AgentPane(); p != nil {
+		return s.Server, p.ID
+	}
+	return s.Server, ""
+}
+
+// sessionServer returns the tmux server a session lives on. If the monitor
+// doesn't know the session it falls back to the default server, but only
+// when that is the server the session was tracked on.
+func (w *Watcher) sessionServer(name string, ts *trackedSession) (tmuxpkg.Server, bool) {
+	if w.monitor != nil {
+		if s := w.monitor.FindSessionOn(ts.server, name); s != nil {
+			return s.Server, true
 		}
 	}
-	return 1
+	srv := tmuxpkg.DefaultServer()
+	return srv, sameServer(ts.server, srv.Name)
 }
 
 // --- State persistence ---
@@ -385,6 +421,7 @@ type watcherPersist struct {
 
 type persistedSession struct {
 	State        string `json:"state"`
+	Server       string `json:"server,omitempty"`
 	Branch       string `json:"branch"`
 	WorktreePath string `json:"worktreePath"`
 	GitPath      string `json:"gitPath"`
@@ -398,6 +435,7 @@ func (w *Watcher) saveStateLocked() {
 	for name, ts := range w.tracked {
 		p.Sessions[name] = persistedSession{
 			State:        ts.state,
+			Server:       ts.server,
 			Branch:       ts.branch,
 			WorktreePath: ts.worktreePath,
 			GitPath:      ts.gitPath,
@@ -435,6 +473,7 @@ func (w *Watcher) loadState() {
 	for name, ps := range p.Sessions {
 		ts := &trackedSession{
 			state:        ps.State,
+			server:       ps.Server,
 			branch:       ps.Branch,
 			worktreePath: ps.WorktreePath,
 			gitPath:      ps.GitPath,
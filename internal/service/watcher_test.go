@@ -16,7 +16,7 @@ func TestWatcherStateTransitions(t *testing.T) {
 	})
 
 	// Simulate adding a tracked session
-	w.Track("test-session", "feature/test", "/tmp/wt", "/tmp/repo")
+	w.Track("", "test-session", "feature/test", "/tmp/wt", "/tmp/repo")
 
 	state := w.State("test-session")
 	if state != "working" {
@@ -35,7 +35,7 @@ func TestWatcherSessionRemoval(t *testing.T) {
 	bus := NewBus()
 	w := NewWatcher(bus, config.WatcherConfig{Enabled: true, PollIntervalS: 1, MaxCIRetries: 3})
 
-	w.Track("test", "branch", "/path", "/repo")
+	w.Track("", "test", "branch", "/path", "/repo")
 	if w.State("test") != "working" {
 		t.Fatal("expected working")
 	}
@@ -61,7 +61,7 @@ func TestWatcherFixingCIWaitsForDone(t *testing.T) {
 	bus := NewBus()
 	w := NewWatcher(bus, config.WatcherConfig{Enabled: true, PollIntervalS: 1, MaxCIRetries: 3})
 
-	w.Track("s", "b", "/wt", "/repo")
+	w.Track("", "s", "b", "/wt", "/repo")
 	ts := w.getTracked("s")
 	ts.state = "fixing_ci"
 	ts.prNumber = 1
@@ -78,3 +78,26 @@ func TestWatcherFixingCIWaitsForDone(t *testing.T) {
 		t.Errorf("expected watching after real done, got %s", w.State("s"))
 	}
 }
+
+func TestWatcherIgnoresOtherServers(t *testing.T) {
+	bus := NewBus()
+	w := NewWatcher(bus, config.WatcherConfig{Enabled: true, PollIntervalS: 1, MaxCIRetries: 3})
+
+	w.Track("default", "s", "b", "/wt", "/repo")
+
+	// A session with the same name on another server is a different session.
+	w.HandleEvent(StatusChangedEvent{Session: "s", Server: "personal", From: "active", To: "done"})
+	w.HandleEvent(SessionRemovedEvent{Name: "s", Server: "personal"})
+	if w.State("s") != "working" {
+		t.Errorf("expected working, got %s", w.State("s"))
+	}
+
+	w.HandleEvent(StatusChangedEvent{Session: "s", Server: "default", From: "active", To: "done"})
+	if w.State("s") != "done" {
+		t.Errorf("expected done, got %s", w.State("s"))
+	}
+	w.HandleEvent(SessionRemovedEvent{Name: "s", Server: "default"})
+	if w.State("s") != "" {
+		t.Errorf("expected empty state after removal, got %s", w.State("s"))
+	}
+}
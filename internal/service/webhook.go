@@ -0,0 +1,322 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+)
+
+const (
+	// defaultWebhookAttempts is how often a delivery is tried unless the
+	// webhook sets max_attempts.
+	defaultWebhookAttempts = 5
+	// webhookBackoff is the wait before the first retry, doubling after each.
+	webhookBackoff = time.Second
+
+	// WebhookSignatureHeader carries "t=<unix time>,sha256=<hex HMAC>": the
+	// time the request was signed and the HMAC-SHA256 of "<unix time>.<body>",
+	// keyed with the webhook's secret. Signing the time lets receivers
+	// reject replayed requests.
+	WebhookSignatureHeader = "X-Tsp-Signature"
+)
+
+// WebhookEnvelope is the JSON body POSTed for an event. ID is the same
+// across retries of one delivery, so receivers can deduplicate.
+type WebhookEnvelope struct {
+	ID      string          `json:"id"`
+	Webhook string          `json:"webhook"`
+	Type    string          `json:"type"`
+	Session string          `json:"session,omitempty"`
+	Time    time.Time       `json:"ts"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// NewWebhookEnvelope wraps an event for the named webhook.
+func NewWebhookEnvelope(webhook string, e Event, now time.Time) (WebhookEnvelope, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return WebhookEnvelope{}, err
+	}
+	id := make([]byte, 12)
+	rand.Read(id)
+	return WebhookEnvelope{
+		ID:      hex.EncodeToString(id),
+		Webhook: webhook,
+		Type:    e.EventType(),
+		Session: EventSession(e),
+		Time:    now,
+		Data:    data,
+	}, nil
+}
+
+// SignWebhook returns the signature header value of a body signed at t.
+func SignWebhook(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookDeadLetterPath returns the file deliveries that kept failing are
+// appended to (~/.tsp/webhooks/dead-letter.jsonl).
+func WebhookDeadLetterPath() string {
+	return filepath.Join(config.TspDir(), "webhooks", "dead-letter.jsonl")
+}
+
+// DeadLetter is a delivery given up on.
+type DeadLetter struct {
+	Time     time.Time       `json:"ts"`
+	Webhook  string          `json:"webhook"`
+	URL      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Envelope WebhookEnvelope `json:"envelope"`
+}
+
+// Webhook POSTs the bus events that pass its filters to a URL. It is its
+// own bus subscriber, so it sees events in order and retries each delivery
+// before moving on to the next; a webhook that is down falls behind
+// without holding up the others.
+type Webhook struct {
+	cfg        config.WebhookConfig
+	secret     string
+	client     *http.Client
+	backoff    time.Duration
+	deadLetter string
+	bus        *Bus
+	unsub      UnsubscribeFunc
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// deadLetterMu serializes writes to the dead-letter file, which every
+// webhook shares.
+var deadLetterMu sync.Mutex
+
+// NewWebhooks creates the webhooks of the config, writing deliveries that
+// keep failing to deadLetter (normally WebhookDeadLetterPath()).
+func NewWebhooks(cfgs []config.WebhookConfig, deadLetter string, bus *Bus) ([]*Webhook, error) {
+	var out []*Webhook
+	seen := make(map[string]bool)
+	for _, cfg := range cfgs {
+		w, err := NewWebhook(cfg, deadLetter, bus)
+		if err != nil {
+			return nil, err
+		}
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("webhook %q: duplicate name", cfg.Name)
+		}
+		seen[cfg.Name] = true
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+// NewWebhook creates a webhook from its config.
+func NewWebhook(cfg config.WebhookConfig, deadLetter string, bus *Bus) (*Webhook, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("webhook without a name")
+	}
+	if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+		return nil, fmt.Errorf("webhook %q: url must be http:// or https://", cfg.Name)
+	}
+	for _, glob := range cfg.Sessions {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("webhook %q: bad session glob %q", cfg.Name, glob)
+		}
+	}
+	secret := expandSecret(cfg.Secret)
+	if cfg.Secret != "" && secret == "" {
+		// Sending unsigned would look like a working setup to the sender
+		// and fail (or worse, pass) unverified at the receiver.
+		return nil, fmt.Errorf("webhook %q: secret %s is empty", cfg.Name, cfg.Secret)
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultWebhookAttempts
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Webhook{
+		cfg:        cfg,
+		secret:     secret,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		backoff:    webhookBackoff,
+		deadLetter: deadLetter,
+		bus:        bus,
+		ctx:        ctx,
+		cancel:     cancel,
+	}, nil
+}
+
+// Name returns the webhook's name.
+func (w *Webhook) Name() string { return w.cfg.Name }
+
+// Start subscribes to the bus.
+func (w *Webhook) Start() {
+	w.unsub = w.bus.Subscribe(w.HandleEvent)
+}
+
+// Stop unsubscribes from the bus and abandons the delivery in progress.
+func (w *Webhook) Stop() {
+	if w.unsub != nil {
+		w.unsub()
+	}
+	w.cancel()
+}
+
+// Match reports whether an event passes the webhook's filters.
+func (w *Webhook) Match(e Event) bool {
+	if len(w.cfg.Events) > 0 && !slices.Contains(w.cfg.Events, e.EventType()) {
+		return false
+	}
+	if len(w.cfg.Sessions) == 0 {
+		return true
+	}
+	session := EventSession(e)
+	return slices.ContainsFunc(w.cfg.Sessions, func(glob string) bool {
+		ok, _ := path.Match(glob, session)
+		return ok
+	})
+}
+
+// HandleEvent delivers an event if it passes the filters.
+func (w *Webhook) HandleEvent(e Event) {
+	if !w.Match(e) {
+		return
+	}
+	env, err := NewWebhookEnvelope(w.cfg.Name, e, time.Now())
+	if err != nil {
+		log.Printf("[webhook] %s: %v", w.cfg.Name, err)
+		return
+	}
+	w.Deliver(w.ctx, env)
+}
+
+// Deliver sends an envelope, retrying with exponential backoff, and
+// records it in the dead-letter file if every attempt fails.
+func (w *Webhook) Deliver(ctx context.Context, env WebhookEnvelope) error {
+	var err error
+	attempt, wait := 0, w.backoff
+	for attempt < w.cfg.MaxAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err() // shutting down; not the receiver's fault
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+		attempt++
+		if err = w.Send(ctx, env); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var se *webhookStatusError
+		if errors.As(err, &se) && !se.retryable() {
+			break
+		}
+	}
+	log.Printf("[webhook] %s: giving up on %s after %d attempts: %v", w.cfg.Name, env.Type, attempt, err)
+	w.writeDeadLetter(DeadLetter{
+		Time:     time.Now(),
+		Webhook:  w.cfg.Name,
+		URL:      w.cfg.URL,
+		Attempts: attempt,
+		Error:    err.Error(),
+		Envelope: env,
+	})
+	return err
+}
+
+// webhookStatusError is a response with a non-2xx status.
+type webhookStatusError struct {
+	status int
+	body   string
+}
+
+func (e *webhookStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("server returned %d", e.status)
+	}
+	return fmt.Sprintf("server returned %d: %s", e.status, e.body)
+}
+
+// retryable reports whether the receiver might accept the delivery later:
+// server errors, rate limiting and timeouts.
+func (e *webhookStatusError) retryable() bool {
+	return e.status >= 500 || e.status == http.StatusTooManyRequests || e.status == http.StatusRequestTimeout
+}
+
+// Send makes a single delivery attempt.
+func (w *Webhook) Send(ctx context.Context, env WebhookEnvelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tsp-webhook")
+	req.Header.Set("X-Tsp-Event", env.Type)
+	req.Header.Set("X-Tsp-Delivery", env.ID)
+	if w.secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(w.secret, time.Now(), body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return &webhookStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+func (w *Webhook) writeDeadLetter(d DeadLetter) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return
+	}
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(w.deadLetter), 0700); err != nil {
+		log.Printf("[webhook] dead letter: %v", err)
+		return
+	}
+	f, err := os.OpenFile(w.deadLetter, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		log.Printf("[webhook] dead letter: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("[webhook] dead letter: %v", err)
+	}
+}
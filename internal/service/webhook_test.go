@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matteo-hertel/tmux-super-powers/config"
+)
+
+func TestWebhookMatch(t *testing.T) {
+	w, err := NewWebhook(config.WebhookConfig{
+		Name:     "chat",
+		URL:      "https://example.com/hook",
+		Events:   []string{"pr.detected", "ci.status.changed"},
+		Sessions: []string{"api-*"},
+	}, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		e    Event
+		want bool
+	}{
+		{PRDetectedEvent{Session: "api-auth"}, true},
+		{CIStatusChangedEvent{Session: "api-auth"}, true},
+		{CIStatusChangedEvent{Session: "web-auth"}, false},
+		{AgentWaitingEvent{Session: "api-auth"}, false},
+	}
+	for _, tt := range tests {
+		if got := w.Match(tt.e); got != tt.want {
+			t.Errorf("Match(%T %s) = %v, want %v", tt.e, EventSession(tt.e), got, tt.want)
+		}
+	}
+
+	if _, err := NewWebhook(config.WebhookConfig{Name: "x", URL: "ftp://example.com"}, "", nil); err == nil {
+		t.Error("expected an error for a non-HTTP url")
+	}
+	if _, err := NewWebhook(config.WebhookConfig{Name: "x", URL: "https://a", Secret: "$TSP_TEST_UNSET_SECRET"}, "", nil); err == nil {
+		t.Error("expected an error for a secret naming an unset variable")
+	}
+	if _, err := NewWebhooks([]config.WebhookConfig{
+		{Name: "x", URL: "https://a"}, {Name: "x", URL: "https://b"},
+	}, "", nil); err == nil {
+		t.Error("expected an error for duplicate names")
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	var got WebhookEnvelope
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		sig := r.Header.Get(WebhookSignatureHeader)
+		ts, _, _ := strings.Cut(strings.TrimPrefix(sig, "t="), ",")
+		unix, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil || time.Since(time.Unix(unix, 0)) > time.Minute || sig != SignWebhook("s3cret", time.Unix(unix, 0), body) {
+			t.Errorf("signature = %q", sig)
+		}
+		if r.Header.Get("X-Tsp-Event") != "pr.detected" {
+			t.Errorf("X-Tsp-Event = %q", r.Header.Get("X-Tsp-Event"))
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	t.Setenv("TSP_TEST_WEBHOOK_SECRET", "s3cret")
+	w, err := NewWebhook(config.WebhookConfig{Name: "chat", URL: srv.URL, Secret: "$TSP_TEST_WEBHOOK_SECRET"}, filepath.Join(t.TempDir(), "dead.jsonl"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.backoff = time.Millisecond
+
+	env, _ := NewWebhookEnvelope("chat", PRDetectedEvent{Session: "api", PRNumber: 7}, time.Now())
+	if err := w.Deliver(context.Background(), env); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3 (two retries)", calls)
+	}
+	var pr PRDetectedEvent
+	json.Unmarshal(got.Data, &pr)
+	if got.ID != env.ID || got.Type != "pr.detected" || got.Session != "api" || pr.PRNumber != 7 {
+		t.Errorf("envelope = %+v, data %+v", got, pr)
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound) // not retried
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	dead := filepath.Join(t.TempDir(), "webhooks", "dead.jsonl")
+	for _, p := range []string{"/down", "/gone"} {
+		w, _ := NewWebhook(config.WebhookConfig{Name: p[1:], URL: srv.URL + p, MaxAttempts: 3}, dead, nil)
+		w.backoff = time.Millisecond
+		env, _ := NewWebhookEnvelope(w.Name(), CleanupCompletedEvent{Session: "api"}, time.Now())
+		if err := w.Deliver(context.Background(), env); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+	if calls["/down"] != 3 || calls["/gone"] != 1 {
+		t.Errorf("calls = %v, want 3 to /down and 1 to /gone", calls)
+	}
+
+	f, err := os.Open(dead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var letters []DeadLetter
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var d DeadLetter
+		json.Unmarshal(sc.Bytes(), &d)
+		letters = append(letters, d)
+	}
+	if len(letters) != 2 || letters[0].Webhook != "down" || letters[0].Attempts != 3 || letters[1].Attempts != 1 {
+		t.Errorf("dead letters = %+v", letters)
+	}
+	if letters[0].Envelope.Type != "cleanup.completed" {
+		t.Errorf("dead letter envelope = %+v", letters[0].Envelope)
+	}
+}
@@ -0,0 +1,311 @@
+package tmux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrControlClosed is returned by ControlClient.Command once the control-mode
+// connection has exited.
+var ErrControlClosed = errors.New("tmux control client closed")
+
+// controlCommandTimeout bounds how long Command waits for a %end/%error reply.
+const controlCommandTimeout = 5 * time.Second
+
+// ControlNotification is an asynchronous message sent by tmux to a control-mode
+// client, e.g. "%output %3 hello" or "%session-changed $1 work".
+type ControlNotification struct {
+	Type   string   // notification name without the leading %, e.g. "output"
+	Args   []string // whitespace-separated arguments (for %output only the pane ID)
+	PaneID string   // pane ID for %output notifications (e.g. "%3")
+	Data   string   // decoded payload for %output notifications
+}
+
+// ControlClient is a persistent tmux control-mode (tmux -C) connection.
+// Commands are written to the client's stdin and their replies are read from
+// the %begin/%end blocks on stdout, so a single tmux process serves every
+// query. Notifications (%output, %session-changed, %window-add, ...) are
+// delivered on the Notifications channel; if the consumer falls behind, the
+// ones that don't fit are dropped and Overflow says so.
+type ControlClient struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	mu      sync.Mutex
+	pending []chan controlReply // FIFO of callers waiting for a reply block
+	closed  bool
+
+	notify   chan ControlNotification
+	overflow chan struct{}
+	done     chan struct{}
+}
+
+type controlReply struct {
+	output string
+	err    error
+}
+
+// StartControlClient opens a control-mode connection to the default server.
+func StartControlClient(session string) (*ControlClient, error) {
+	return defaultServer.StartControlClient(session)
+}
+
+// StartControlClient opens a control-mode connection attached to the given
+// session. tmux only sends %output for panes in the attached session; the
+// connection can still run commands against any session on the server.
+func (s Server) StartControlClient(session string) (*ControlClient, error) {
+	cmd := s.Command("-C", "attach-session", "-t", session)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("control client stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("control client stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start control client: %w", err)
+	}
+	c := &ControlClient{
+		cmd:      cmd,
+		stdin:    stdin,
+		notify:   make(chan ControlNotification, 256),
+		overflow: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go c.readLoop(stdout)
+	return c, nil
+}
+
+// Notifications returns the channel on which asynchronous notifications are
+// delivered. The channel is closed when the connection exits. Notifications
+// are dropped rather than blocking the reader if the consumer falls behind;
+// Overflow reports when that happened.
+func (c *ControlClient) Notifications() <-chan ControlNotification {
+	return c.notify
+}
+
+// Overflow receives a value after notifications were dropped because the
+// Notifications channel was full. Drops are coalesced until it is read. The
+// consumer can no longer know which panes had output and should treat them
+// all as changed.
+func (c *ControlClient) Overflow() <-chan struct{} {
+	return c.overflow
+}
+
+// Done is closed when the control-mode connection exits.
+func (c *ControlClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Command runs a tmux command over the control connection and returns its
+// output. Arguments are quoted for the tmux command parser, so formats and
+// targets can be passed exactly as they would be to exec.Command.
+func (c *ControlClient) Command(args ...string) (string, error) {
+	line, err := BuildControlCommand(args)
+	if err != nil {
+		return "", err
+	}
+
+	ch := make(chan controlReply, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return "", ErrControlClosed
+	}
+	c.pending = append(c.pending, ch)
+	if _, err := io.WriteString(c.stdin, line+"\n"); err != nil {
+		c.pending = c.pending[:len(c.pending)-1]
+		c.mu.Unlock()
+		return "", fmt.Errorf("control write: %w", err)
+	}
+	c.mu.Unlock()
+
+	select {
+	case r := <-ch:
+		return r.output, r.err
+	case <-c.done:
+		return "", ErrControlClosed
+	case <-time.After(controlCommandTimeout):
+		return "", fmt.Errorf("control command %q timed out", args[0])
+	}
+}
+
+// Close detaches the control client and waits for it to exit.
+func (c *ControlClient) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+	// An empty line tells tmux to detach the control client.
+	io.WriteString(c.stdin, "\n")
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+	return nil
+}
+
+func (c *ControlClient) readLoop(r io.Reader) {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		for _, ch := range c.pending {
+			ch <- controlReply{err: ErrControlClosed}
+		}
+		c.pending = nil
+		c.mu.Unlock()
+		close(c.notify)
+		close(c.done)
+		c.cmd.Wait()
+	}()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var (
+		inBlock bool
+		ours    bool
+		body    []string
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inBlock {
+			if strings.HasPrefix(line, "%end ") || strings.HasPrefix(line, "%error ") {
+				inBlock = false
+				if ours {
+					reply := controlReply{output: strings.Join(body, "\n")}
+					if strings.HasPrefix(line, "%error ") {
+						reply.err = fmt.Errorf("tmux: %s", strings.TrimSpace(reply.output))
+					}
+					c.deliver(reply)
+				}
+				body = nil
+				continue
+			}
+			body = append(body, line)
+			continue
+		}
+		if strings.HasPrefix(line, "%begin ") {
+			inBlock = true
+			ours = controlBlockFromClient(line)
+			continue
+		}
+		n, ok := ParseControlNotification(line)
+		if !ok {
+			continue
+		}
+		if n.Type == "exit" {
+			return
+		}
+		select {
+		case c.notify <- n:
+		default:
+			select {
+			case c.overflow <- struct{}{}:
+			default: // already signalled and not yet read
+			}
+		}
+	}
+}
+
+// deliver hands a reply block to the oldest waiting caller.
+func (c *ControlClient) deliver(r controlReply) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return
+	}
+	ch := c.pending[0]
+	c.pending = c.pending[1:]
+	ch <- r
+}
+
+// controlBlockFromClient reports whether a "%begin <time> <number> <flags>"
+// guard belongs to a command written by this client. tmux sets flags to 1 for
+// commands received over the control connection; the implicit attach-session
+// reply sent on connect has flags 0.
+func controlBlockFromClient(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) >= 4 && fields[3] == "1"
+}
+
+// ParseControlNotification parses a single %-prefixed control-mode line.
+// Returns false for lines that are not notifications.
+func ParseControlNotification(line string) (ControlNotification, bool) {
+	if !strings.HasPrefix(line, "%") || len(line) < 2 {
+		return ControlNotification{}, false
+	}
+	name, rest, _ := strings.Cut(line[1:], " ")
+	n := ControlNotification{Type: name}
+	if name == "output" {
+		paneID, data, _ := strings.Cut(rest, " ")
+		n.PaneID = paneID
+		n.Args = []string{paneID}
+		n.Data = DecodeControlOutput(data)
+		return n, true
+	}
+	if rest != "" {
+		n.Args = strings.Fields(rest)
+	}
+	return n, true
+}
+
+// DecodeControlOutput reverses the octal escaping tmux applies to %output
+// payloads (characters below 32 and backslash are sent as \ooo).
+func DecodeControlOutput(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool { return c >= '0' && c <= '7' }
+
+// BuildControlCommand renders args as a single tmux command line, quoting each
+// argument so the tmux parser passes it through verbatim. Control mode reads
+// one command per line, so arguments containing newlines are rejected.
+func BuildControlCommand(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("empty tmux command")
+	}
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if strings.ContainsAny(a, "\r\n") {
+			return "", fmt.Errorf("control command argument contains newline: %q", a)
+		}
+		quoted[i] = quoteControlArg(a)
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// quoteControlArg single-quotes an argument for the tmux command parser.
+// Embedded single quotes are closed, emitted in double quotes, and reopened.
+func quoteControlArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t'\"\\;#$~{}") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,119 @@
+package tmux
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestParseControlNotification_Output(t *testing.T) {
+	n, ok := ParseControlNotification(`%output %12 hello\015\012world \134o/`)
+	if !ok {
+		t.Fatal("expected notification")
+	}
+	if n.Type != "output" {
+		t.Errorf("Type = %q, want output", n.Type)
+	}
+	if n.PaneID != "%12" {
+		t.Errorf("PaneID = %q, want %%12", n.PaneID)
+	}
+	if want := "hello\r\nworld \\o/"; n.Data != want {
+		t.Errorf("Data = %q, want %q", n.Data, want)
+	}
+}
+
+func TestParseControlNotification_SessionChanged(t *testing.T) {
+	n, ok := ParseControlNotification("%session-changed $3 my-session")
+	if !ok {
+		t.Fatal("expected notification")
+	}
+	if n.Type != "session-changed" {
+		t.Errorf("Type = %q, want session-changed", n.Type)
+	}
+	if len(n.Args) != 2 || n.Args[0] != "$3" || n.Args[1] != "my-session" {
+		t.Errorf("Args = %v, want [$3 my-session]", n.Args)
+	}
+}
+
+func TestParseControlNotification_WindowAdd(t *testing.T) {
+	n, ok := ParseControlNotification("%window-add @7")
+	if !ok || n.Type != "window-add" || len(n.Args) != 1 || n.Args[0] != "@7" {
+		t.Errorf("unexpected notification: %+v (ok=%v)", n, ok)
+	}
+}
+
+func TestParseControlNotification_NotNotification(t *testing.T) {
+	if _, ok := ParseControlNotification("plain output line"); ok {
+		t.Error("expected plain line to be rejected")
+	}
+	if _, ok := ParseControlNotification("%"); ok {
+		t.Error("expected bare % to be rejected")
+	}
+}
+
+func TestDecodeControlOutput_IncompleteEscape(t *testing.T) {
+	got := DecodeControlOutput(`trailing \01`)
+	if got != `trailing \01` {
+		t.Errorf("DecodeControlOutput = %q, want input unchanged", got)
+	}
+}
+
+func TestControlBlockFromClient(t *testing.T) {
+	if !controlBlockFromClient("%begin 1700000000 42 1") {
+		t.Error("expected flags=1 block to belong to client")
+	}
+	if controlBlockFromClient("%begin 1700000000 1 0") {
+		t.Error("expected flags=0 block to be ignored")
+	}
+}
+
+func TestBuildControlCommand(t *testing.T) {
+	got, err := BuildControlCommand([]string{"list-panes", "-t", "%3", "-F", "#{pane_id} #{pane_index}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `list-panes -t %3 -F '#{pane_id} #{pane_index}'`
+	if got != want {
+		t.Errorf("BuildControlCommand = %q, want %q", got, want)
+	}
+}
+
+func TestBuildControlCommand_SingleQuote(t *testing.T) {
+	got, err := BuildControlCommand([]string{"send-keys", "-l", "it's"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `send-keys -l 'it'"'"'s'`
+	if got != want {
+		t.Errorf("BuildControlCommand = %q, want %q", got, want)
+	}
+}
+
+func TestBuildControlCommand_RejectsNewline(t *testing.T) {
+	if _, err := BuildControlCommand([]string{"send-keys", "a\nb"}); err == nil {
+		t.Error("expected error for argument containing newline")
+	}
+}
+
+func TestControlReadLoopOverflow(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := cmd.Start(); err != nil {
+		t.Skip("no true command:", err)
+	}
+	c := &ControlClient{
+		cmd:      cmd,
+		notify:   make(chan ControlNotification, 1),
+		overflow: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	c.readLoop(strings.NewReader("%output %1 a\n%output %2 b\n%output %3 c\n"))
+
+	if n := <-c.Notifications(); n.PaneID != "%1" {
+		t.Errorf("first notification = %+v", n)
+	}
+	select {
+	case <-c.Overflow():
+	default:
+		t.Error("expected an overflow signal for the dropped notifications")
+	}
+}
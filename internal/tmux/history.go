@@ -0,0 +1,153 @@
+package tmux
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxHistoryAttempts bounds how often CaptureHistory retries when output
+// scrolls into history between sizing the range and capturing it.
+const maxHistoryAttempts = 3
+
+// History is a range of lines from a pane's scrollback plus its visible
+// screen. Lines are numbered from the oldest line tmux still holds, so a
+// line keeps its number as new output arrives while the history grows.
+// Once it reaches history-limit, tmux drops its oldest tenth at a time and
+// every number shifts down with it; tmux doesn't report how many lines it
+// dropped, so Drifting warns that numbers from earlier pages may now point
+// at later lines.
+type History struct {
+	From     int      `json:"from"`  // number of the first line returned
+	Lines    []string `json:"lines"` // without trailing newlines
+	Total    int      `json:"total"` // history plus visible lines
+	Size     int      `json:"historySize"`
+	Limit    int      `json:"historyLimit"`
+	Height   int      `json:"height"`
+	Drifting bool     `json:"drifting"` // history is full, so numbers shift as output arrives
+}
+
+// historyFull reports whether a history of size lines has reached the point
+// where tmux trims it: it fills up to limit, then drops a tenth of it.
+func historyFull(size, limit int) bool {
+	return limit > 0 && size >= limit-limit/10
+}
+
+// noCaptureMode holds the labels of servers that reject capture-pane -M
+// (tmux before 3.5), so they are only asked once.
+var noCaptureMode sync.Map
+
+// historyFormat is the display-message format that sizes a pane's history.
+const historyFormat = "#{history_size} #{pane_height} #{history_limit}"
+
+// BuildCaptureRangeArgs builds capture-pane args for tmux lines start..end,
+// where 0 is the first visible line and negative lines are history. -N keeps
+// trailing spaces so lines come back as tmux stores them; escapes adds -e for
+// colours and attributes, and mode adds -M so a pane in copy mode is
+// captured as its history rather than the copy-mode view.
+func BuildCaptureRangeArgs(target string, start, end int, escapes, mode bool) []string {
+	args := []string{"capture-pane", "-p", "-N", "-t", target, "-S", strconv.Itoa(start), "-E", strconv.Itoa(end)}
+	if escapes {
+		args = append(args, "-e")
+	}
+	if mode {
+		args = append(args, "-M")
+	}
+	return args
+}
+
+// historyRange clamps a request for count lines starting at line from to the
+// lines that exist, and maps it to capture-pane's numbering, where 0 is the
+// first visible line and history lines are negative. A negative from requests
+// the last count lines. end < start when the range is empty.
+func historyRange(size, height, from, count int) (first, start, end int) {
+	total := size + height
+	if count <= 0 || count > total {
+		count = total
+	}
+	if from < 0 {
+		from = total - count
+	}
+	if from > total {
+		from = total
+	}
+	last := min(from+count, total) - 1
+	return from, from - size, last - size
+}
+
+// parseHistoryInfo parses output produced by historyFormat.
+func parseHistoryInfo(line string) (size, height, limit int, err error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return 0, 0, 0, fmt.Errorf("unexpected history info %q", line)
+	}
+	size, err1 := strconv.Atoi(fields[0])
+	height, err2 := strconv.Atoi(fields[1])
+	limit, err3 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, 0, 0, fmt.Errorf("unexpected history info %q", line)
+	}
+	return size, height, limit, nil
+}
+
+// CaptureHistory captures count lines of a pane's scrollback starting at
+// line from (see History for numbering); a negative from captures the last
+// count lines. escapes keeps ANSI colour sequences.
+//
+// Wrapped lines are not joined (no -J) so every screen row keeps its number.
+// -M is passed unless the server is too old to know it.
+func (s Server) CaptureHistory(target string, from, count int, escapes bool) (*History, error) {
+	out, err := s.Command("display-message", "-p", "-t", target, historyFormat).Output()
+	if err != nil {
+		return nil, fmt.Errorf("display-message: %w", err)
+	}
+	size, height, limit, err := parseHistoryInfo(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		first, start, end := historyRange(size, height, from, count)
+		h := &History{From: first, Lines: []string{}, Total: size + height, Size: size, Limit: limit, Height: height, Drifting: historyFull(size, limit)}
+		if end < start {
+			return h, nil
+		}
+		_, noMode := noCaptureMode.Load(s.Label())
+
+		// Re-read the history size in the same command sequence as the
+		// capture. tmux doesn't process pane output between the two, so if
+		// the size still matches, the range was computed against the
+		// history that was captured.
+		args := append([]string{"display-message", "-p", "-t", target, historyFormat, ";"},
+			BuildCaptureRangeArgs(target, start, end, escapes, !noMode)...)
+		out, err := s.Command(args...).Output()
+		var exitErr *exec.ExitError
+		if !noMode && errors.As(err, &exitErr) && strings.Contains(string(exitErr.Stderr), "unknown flag -M") {
+			noCaptureMode.Store(s.Label(), true)
+			attempt--
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("capture-pane: %w", err)
+		}
+		info, body, _ := strings.Cut(string(out), "\n")
+		nsize, nheight, nlimit, err := parseHistoryInfo(info)
+		if err != nil {
+			return nil, err
+		}
+		if (nsize != size || nheight != height) && attempt < maxHistoryAttempts {
+			size, height, limit = nsize, nheight, nlimit
+			continue
+		}
+		h.Lines = strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+		return h, nil
+	}
+}
+
+// CaptureHistory captures a range of a pane's scrollback on the default server.
+func CaptureHistory(target string, from, count int, escapes bool) (*History, error) {
+	return defaultServer.CaptureHistory(target, from, count, escapes)
+}
@@ -0,0 +1,106 @@
+package tmux
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildCaptureRangeArgs(t *testing.T) {
+	got := strings.Join(BuildCaptureRangeArgs("%3", -20, 4, false, false), " ")
+	want := "capture-pane -p -N -t %3 -S -20 -E 4"
+	if got != want {
+		t.Errorf("args = %q, want %q", got, want)
+	}
+	got = strings.Join(BuildCaptureRangeArgs("%3", 0, 0, true, true), " ")
+	want = "capture-pane -p -N -t %3 -S 0 -E 0 -e -M"
+	if got != want {
+		t.Errorf("escapes and mode args = %q, want %q", got, want)
+	}
+}
+
+func TestHistoryFull(t *testing.T) {
+	tests := []struct {
+		size, limit int
+		want        bool
+	}{
+		{0, 2000, false},
+		{1799, 2000, false},
+		{1800, 2000, true}, // what's left after tmux trims a full history
+		{2000, 2000, true},
+		{0, 0, false},
+	}
+	for _, tt := range tests {
+		if got := historyFull(tt.size, tt.limit); got != tt.want {
+			t.Errorf("historyFull(%d, %d) = %v, want %v", tt.size, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestCaptureHistory(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	srv := Server{Name: "test", SocketPath: filepath.Join(t.TempDir(), "tmux")}
+	if out, err := srv.Command("new-session", "-d", "-s", "h", "-x", "80", "-y", "5", "seq 1 20; sleep 60").CombinedOutput(); err != nil {
+		t.Skipf("can't start tmux: %v: %s", err, out)
+	}
+	defer srv.Command("kill-server").Run()
+
+	var h *History
+	for range 50 {
+		var err error
+		if h, err = srv.CaptureHistory("h", 0, 3, false); err != nil {
+			t.Fatal(err)
+		}
+		if h.Total >= 20 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if want := []string{"1", "2", "3"}; strings.Join(h.Lines, ",") != strings.Join(want, ",") {
+		t.Errorf("lines = %q, want %q", h.Lines, want)
+	}
+	if h.Drifting {
+		t.Error("a history far from its limit shouldn't drift")
+	}
+}
+
+func TestHistoryRange(t *testing.T) {
+	// 26 history lines above a 5-line screen: lines 0..30, tmux -26..4.
+	tests := []struct {
+		name              string
+		from, count       int
+		first, start, end int
+	}{
+		{"tail", -1, 7, 24, -2, 4},
+		{"head", 0, 3, 0, -26, -24},
+		{"middle", 10, 5, 10, -16, -12},
+		{"past end", 29, 10, 29, 3, 4},
+		{"beyond end", 40, 3, 31, 5, 4},
+		{"all", -1, 0, 0, -26, 4},
+		{"too many", -1, 1000, 0, -26, 4},
+	}
+	for _, tt := range tests {
+		first, start, end := historyRange(26, 5, tt.from, tt.count)
+		if first != tt.first || start != tt.start || end != tt.end {
+			t.Errorf("%s: historyRange = (%d, %d, %d), want (%d, %d, %d)",
+				tt.name, first, start, end, tt.first, tt.start, tt.end)
+		}
+	}
+}
+
+func TestParseHistoryInfo(t *testing.T) {
+	size, height, limit, err := parseHistoryInfo("17 5 2000")
+	if err != nil || size != 17 || height != 5 || limit != 2000 {
+		t.Errorf("parseHistoryInfo = (%d, %d, %d, %v)", size, height, limit, err)
+	}
+	if _, _, _, err := parseHistoryInfo("17 5"); err == nil {
+		t.Error("expected error for short input")
+	}
+	if _, _, _, err := parseHistoryInfo("a b c"); err == nil {
+		t.Error("expected error for non-numeric input")
+	}
+}
@@ -0,0 +1,229 @@
+package tmux
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Layout describes the windows and panes of a session to create.
+type Layout struct {
+	Windows []LayoutWindow
+}
+
+// LayoutWindow is one window of a Layout.
+type LayoutWindow struct {
+	Name string
+	// Arrange is an optional tmux layout applied once all panes exist
+	// (e.g. "main-vertical", "tiled", "even-horizontal").
+	Arrange string
+	Panes   []LayoutPane
+}
+
+// LayoutPane is one pane of a window. The first pane of a window is the
+// window itself; every later pane is split off the pane before it.
+type LayoutPane struct {
+	Command string
+	Split   string // "h" (side by side, the default) or "v" (stacked)
+	Size    string // split-window -l value, e.g. "30%" or "20" (cells)
+	Agent   bool
+	Dir     string // working directory, overriding the session's
+}
+
+// TwoPaneLayout is the classic layout: leftCmd on the left and rightCmd on
+// the right, with the right pane marked as the agent.
+func TwoPaneLayout(leftCmd, rightCmd string) Layout {
+	return Layout{Windows: []LayoutWindow{{
+		Panes: []LayoutPane{
+			{Command: leftCmd},
+			{Command: rightCmd, Split: "h", Agent: true},
+		},
+	}}}
+}
+
+// Validate checks that the layout can be built: at least one window, no
+// empty windows, known arrangements, split directions and sizes, and at most
+// one agent pane.
+func (l Layout) Validate() error {
+	if len(l.Windows) == 0 {
+		return fmt.Errorf("layout has no windows")
+	}
+	agents := 0
+	for wi, w := range l.Windows {
+		if len(w.Panes) == 0 {
+			return fmt.Errorf("window %d has no panes", wi)
+		}
+		if !validArrange(w.Arrange) {
+			return fmt.Errorf("window %d: unknown arrangement %q (want a tmux layout name such as tiled or main-vertical, or a layout string)", wi, w.Arrange)
+		}
+		for pi, p := range w.Panes {
+			if _, ok := splitFlag(p.Split); !ok {
+				return fmt.Errorf("window %d pane %d: unknown split %q (want h or v)", wi, pi, p.Split)
+			}
+			if !validSize(p.Size) {
+				return fmt.Errorf("window %d pane %d: invalid size %q (want cells, e.g. 20, or a percentage, e.g. 30%%)", wi, pi, p.Size)
+			}
+			if p.Agent {
+				agents++
+			}
+		}
+	}
+	if agents > 1 {
+		return fmt.Errorf("layout has %d agent panes, want at most one", agents)
+	}
+	return nil
+}
+
+// HasAgent reports whether one of the layout's panes is marked as the agent.
+func (l Layout) HasAgent() bool {
+	for _, w := range l.Windows {
+		for _, p := range w.Panes {
+			if p.Agent {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// arrangeNames are tmux's preset layouts, as taken by select-layout.
+var arrangeNames = []string{
+	"even-horizontal", "even-vertical",
+	"main-horizontal", "main-horizontal-mirrored",
+	"main-vertical", "main-vertical-mirrored",
+	"tiled",
+}
+
+// layoutStringPattern matches the start of a tmux layout string, as printed
+// by #{window_layout}: a checksum and the window's size and position.
+var layoutStringPattern = regexp.MustCompile(`^[0-9a-f]{4},\d+x\d+,\d+,\d+`)
+
+// validArrange reports whether arrange is empty, a preset layout name or a
+// layout string.
+func validArrange(arrange string) bool {
+	if arrange == "" || layoutStringPattern.MatchString(arrange) {
+		return true
+	}
+	for _, name := range arrangeNames {
+		if arrange == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sizePattern matches a split-window -l value: a number of cells or a
+// percentage.
+var sizePattern = regexp.MustCompile(`^[1-9][0-9]*%?$`)
+
+// validSize reports whether size is empty or a valid split size.
+func validSize(size string) bool {
+	return size == "" || sizePattern.MatchString(size)
+}
+
+// splitFlag maps a split direction to the split-window flag.
+func splitFlag(split string) (string, bool) {
+	switch strings.ToLower(split) {
+	case "", "h", "horizontal":
+		return "-h", true
+	case "v", "vertical":
+		return "-v", true
+	}
+	return "", false
+}
+
+// BuildLayoutWindowArgs builds the args that create window wi of a layout:
+// new-session for the first window, new-window for the rest.
+func BuildLayoutWindowArgs(session, dir string, wi int, w LayoutWindow) []string {
+	if p := w.Panes[0]; p.Dir != "" {
+		dir = p.Dir
+	}
+	var args []string
+	if wi == 0 {
+		args = []string{"new-session", "-d", "-s", session, "-c", dir}
+	} else {
+		args = []string{"new-window", "-d", "-t", session + ":", "-c", dir}
+	}
+	if w.Name != "" {
+		args = append(args, "-n", w.Name)
+	}
+	if cmd := w.Panes[0].Command; cmd != "" {
+		args = append(args, cmd)
+	}
+	return args
+}
+
+// BuildLayoutSplitArgs builds the split-window args for a non-first pane,
+// splitting it off target.
+func BuildLayoutSplitArgs(target, dir string, p LayoutPane) []string {
+	flag, _ := splitFlag(p.Split)
+	if p.Dir != "" {
+		dir = p.Dir
+	}
+	args := []string{"split-window", flag, "-t", target, "-c", dir}
+	if p.Size != "" {
+		args = append(args, "-l", p.Size)
+	}
+	if p.Command != "" {
+		args = append(args, p.Command)
+	}
+	return args
+}
+
+// CreateLayoutSession creates a session on the default server laid out as
+// described. See Server.CreateLayoutSession.
+func CreateLayoutSession(name, dir string, layout Layout) (panes []string, agentPane string, err error) {
+	return defaultServer.CreateLayoutSession(name, dir, layout)
+}
+
+// CreateLayoutSession creates a tmux session laid out as described and
+// returns the pane IDs in layout order (window by window) along with the
+// agent pane's ID, which is empty if the layout has no agent pane. The first
+// pane of the first window is left selected. If any window, pane or
+// arrangement fails, the partly built session is killed and the error
+// returned.
+func (s Server) CreateLayoutSession(name, dir string, layout Layout) (panes []string, agentPane string, err error) {
+	if err := layout.Validate(); err != nil {
+		return nil, "", err
+	}
+	for wi, w := range layout.Windows {
+		out, err := s.Command(withPrintPaneID(BuildLayoutWindowArgs(name, dir, wi, w))...).Output()
+		if err != nil {
+			if wi == 0 {
+				return nil, "", fmt.Errorf("failed to create session: %w", err)
+			}
+			return nil, "", s.abandonSession(name, fmt.Errorf("failed to create window %d: %w", wi, err))
+		}
+		prev := strings.TrimSpace(string(out))
+		first := prev
+		for pi, p := range w.Panes {
+			if pi > 0 {
+				out, err := s.Command(withPrintPaneID(BuildLayoutSplitArgs(prev, dir, p))...).Output()
+				if err != nil {
+					return nil, "", s.abandonSession(name, fmt.Errorf("failed to split window %d: %w", wi, err))
+				}
+				prev = strings.TrimSpace(string(out))
+			}
+			panes = append(panes, prev)
+			if p.Agent {
+				agentPane = prev
+			}
+		}
+		if w.Arrange != "" {
+			if out, err := s.Command("select-layout", "-t", first, w.Arrange).CombinedOutput(); err != nil {
+				return nil, "", s.abandonSession(name, fmt.Errorf("failed to arrange window %d as %q: %w: %s", wi, w.Arrange, err, strings.TrimSpace(string(out))))
+			}
+		}
+	}
+
+	s.Command("select-window", "-t", panes[0]).Run()
+	s.Command("select-pane", "-t", panes[0]).Run()
+	return panes, agentPane, nil
+}
+
+// abandonSession kills a session whose creation failed part way, so no
+// half-built session is left behind, and returns err.
+func (s Server) abandonSession(name string, err error) error {
+	s.KillSession("=" + name)
+	return err
+}
@@ -0,0 +1,115 @@
+package tmux
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildLayoutWindowArgs(t *testing.T) {
+	w := LayoutWindow{Name: "dev", Panes: []LayoutPane{{Command: "npm run dev"}}}
+
+	got := strings.Join(BuildLayoutWindowArgs("myapp", "/src", 0, w), " ")
+	want := "new-session -d -s myapp -c /src -n dev npm run dev"
+	if got != want {
+		t.Errorf("first window args = %q, want %q", got, want)
+	}
+
+	got = strings.Join(BuildLayoutWindowArgs("myapp", "/src", 1, w), " ")
+	want = "new-window -d -t myapp: -c /src -n dev npm run dev"
+	if got != want {
+		t.Errorf("second window args = %q, want %q", got, want)
+	}
+
+	bare := LayoutWindow{Panes: []LayoutPane{{Dir: "/src/web"}}}
+	got = strings.Join(BuildLayoutWindowArgs("myapp", "/src", 0, bare), " ")
+	want = "new-session -d -s myapp -c /src/web"
+	if got != want {
+		t.Errorf("bare window args = %q, want %q", got, want)
+	}
+}
+
+func TestBuildLayoutSplitArgs(t *testing.T) {
+	tests := []struct {
+		pane LayoutPane
+		want string
+	}{
+		{LayoutPane{}, "split-window -h -t %1 -c /src"},
+		{LayoutPane{Split: "v", Size: "30%", Command: "npm test -- --watch"}, "split-window -v -t %1 -c /src -l 30% npm test -- --watch"},
+		{LayoutPane{Split: "horizontal", Size: "80", Command: "claude"}, "split-window -h -t %1 -c /src -l 80 claude"},
+		{LayoutPane{Dir: "/src/web"}, "split-window -h -t %1 -c /src/web"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(BuildLayoutSplitArgs("%1", "/src", tt.pane), " ")
+		if got != tt.want {
+			t.Errorf("BuildLayoutSplitArgs(%+v) = %q, want %q", tt.pane, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutValidate(t *testing.T) {
+	if err := TwoPaneLayout("nvim", "claude").Validate(); err != nil {
+		t.Errorf("two-pane layout: unexpected error: %v", err)
+	}
+	if !TwoPaneLayout("nvim", "claude").HasAgent() {
+		t.Error("two-pane layout should have an agent pane")
+	}
+
+	bad := []Layout{
+		{},
+		{Windows: []LayoutWindow{{Name: "empty"}}},
+		{Windows: []LayoutWindow{{Panes: []LayoutPane{{}, {Split: "diagonal"}}}}},
+		{Windows: []LayoutWindow{{Arrange: "spiral", Panes: []LayoutPane{{}}}}},
+		{Windows: []LayoutWindow{{Panes: []LayoutPane{{}, {Size: "half"}}}}},
+		{Windows: []LayoutWindow{{Panes: []LayoutPane{{}, {Size: "0"}}}}},
+		{Windows: []LayoutWindow{
+			{Panes: []LayoutPane{{Agent: true}}},
+			{Panes: []LayoutPane{{Agent: true}}},
+		}},
+	}
+	for _, l := range bad {
+		if err := l.Validate(); err == nil {
+			t.Errorf("expected error for layout %+v", l)
+		}
+	}
+
+	good := Layout{Windows: []LayoutWindow{
+		{Arrange: "main-vertical", Panes: []LayoutPane{{}, {Size: "30%"}, {Size: "20"}}},
+		{Arrange: "b25d,80x24,0,0{40x24,0,0,1,39x24,41,0,2}", Panes: []LayoutPane{{}, {}}},
+	}}
+	if err := good.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCreateLayoutSessionCleansUp(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	srv := Server{Name: "test", SocketPath: filepath.Join(t.TempDir(), "tmux")}
+	if out, err := srv.Command("new-session", "-d", "-s", "keep").CombinedOutput(); err != nil {
+		t.Skipf("can't start tmux: %v: %s", err, out)
+	}
+	t.Cleanup(func() { srv.Command("kill-server").Run() })
+
+	// The second window's layout string passes validation but has a bad
+	// checksum, so tmux rejects it once the first window is built.
+	layout := Layout{Windows: []LayoutWindow{
+		{Panes: []LayoutPane{{Command: "sleep 60"}, {Command: "sleep 60"}}},
+		{Arrange: "0000,80x24,0,0,1", Panes: []LayoutPane{{Command: "sleep 60"}}},
+	}}
+	panes, agent, err := srv.CreateLayoutSession("broken", t.TempDir(), layout)
+	if err == nil || panes != nil || agent != "" {
+		t.Fatalf("CreateLayoutSession = %v, %q, %v, want an error", panes, agent, err)
+	}
+	if !strings.Contains(err.Error(), "arrange window 1") {
+		t.Errorf("err = %v, want the arrangement failure", err)
+	}
+	if ok, err := srv.HasSession("broken"); err != nil || ok {
+		t.Errorf("HasSession(broken) = %v, %v, want the partial session killed", ok, err)
+	}
+	if ok, _ := srv.HasSession("keep"); !ok {
+		t.Error("other sessions should be left alone")
+	}
+}
@@ -0,0 +1,177 @@
+package tmux
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PaneInfo is one pane from a server-wide list-panes -a snapshot.
+type PaneInfo struct {
+	Session        string
+	WindowIndex    int
+	WindowName     string
+	WindowActive   bool
+	PaneIndex      int
+	PaneID         string // stable pane ID, e.g. "%12"
+	PID            int
+	Command        string // pane_current_command
+	Cwd            string // pane_current_path
+	Width          int
+	Height         int
+	Activity       time.Time // last output in the pane's window (second resolution)
+	PaneActive     bool
+	WindowLayout   string    // tmux layout string, e.g. "b25d,80x24,0,0{40x24,0,0,1,39x24,41,0,2}"
+	StartCommand   string    // command the pane was created with, empty for a plain shell
+	Piped          bool      // output is being piped somewhere with pipe-pane
+	Recording      string    // log file tsp started piping the pane to (RecordingOption)
+	SessionCreated time.Time // when the pane's session was created
+}
+
+// paneListFields are the list-panes format variables, in PaneInfo order.
+// Fields are tab-separated so session names and paths may contain spaces;
+// the free-form window name comes last so it may contain anything.
+var paneListFields = []string{
+	"#{session_name}",
+	"#{window_index}",
+	"#{window_active}",
+	"#{pane_index}",
+	"#{pane_id}",
+	"#{pane_pid}",
+	"#{pane_current_command}",
+	"#{pane_current_path}",
+	"#{pane_width}",
+	"#{pane_height}",
+	"#{window_activity}",
+	"#{pane_active}",
+	"#{window_layout}",
+	"#{pane_start_command}",
+	"#{pane_pipe}",
+	"#{session_created}",
+	"#{" + RecordingOption + "}",
+	"#{window_name}",
+}
+
+// BuildListAllPanesArgs builds the args for a single list-panes query that
+// returns every pane of every session with its metadata.
+func BuildListAllPanesArgs() []string {
+	return []string{"list-panes", "-a", "-F", strings.Join(paneListFields, "\t")}
+}
+
+// BuildListSessionPanesArgs builds the args for listing every pane in every
+// window of one session, in the same format as BuildListAllPanesArgs.
+func BuildListSessionPanesArgs(session string) []string {
+	return []string{"list-panes", "-s", "-t", session, "-F", strings.Join(paneListFields, "\t")}
+}
+
+// ParsePaneList parses output produced by BuildListAllPanesArgs.
+// Malformed lines are skipped.
+func ParsePaneList(out string) []PaneInfo {
+	var panes []PaneInfo
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", len(paneListFields))
+		if len(fields) != len(paneListFields) {
+			continue
+		}
+		windowIndex, err1 := strconv.Atoi(fields[1])
+		paneIndex, err2 := strconv.Atoi(fields[3])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		pid, _ := strconv.Atoi(fields[5])
+		width, _ := strconv.Atoi(fields[8])
+		height, _ := strconv.Atoi(fields[9])
+		p := PaneInfo{
+			Session:      fields[0],
+			WindowIndex:  windowIndex,
+			WindowName:   fields[17],
+			WindowActive: fields[2] == "1",
+			PaneIndex:    paneIndex,
+			PaneID:       fields[4],
+			PID:          pid,
+			Command:      fields[6],
+			Cwd:          fields[7],
+			Width:        width,
+			Height:       height,
+			PaneActive:   fields[11] == "1",
+			WindowLayout: fields[12],
+			StartCommand: unescapeStartCommand(fields[13]),
+			Piped:        fields[14] == "1",
+			Recording:    fields[16],
+		}
+		if secs, err := strconv.ParseInt(fields[10], 10, 64); err == nil {
+			p.Activity = time.Unix(secs, 0)
+		}
+		if secs, err := strconv.ParseInt(fields[15], 10, 64); err == nil {
+			p.SessionCreated = time.Unix(secs, 0)
+		}
+		panes = append(panes, p)
+	}
+	return panes
+}
+
+// unescapeStartCommand undoes the quoting tmux applies to pane_start_command:
+// a single-argument command containing spaces or shell characters is wrapped
+// in double quotes with ", $ and \ backslash-escaped. The result is the shell
+// command the pane was started with.
+func unescapeStartCommand(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// ListAllPanes returns every pane on the default tmux server in a single
+// tmux call. Returns nil (not an error) if the tmux server is not running.
+func ListAllPanes() ([]PaneInfo, error) {
+	return defaultServer.ListAllPanes()
+}
+
+// ListAllPanes returns every pane on this server in a single tmux call.
+// Returns nil (not an error) if the server is not running.
+func (s Server) ListAllPanes() ([]PaneInfo, error) {
+	out, err := s.Command(BuildListAllPanesArgs()...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			stderr := string(ee.Stderr)
+			if strings.Contains(stderr, "no server running") || strings.Contains(stderr, "no current") {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("list-panes: %w", err)
+	}
+	return ParsePaneList(string(out)), nil
+}
+
+// ListSessionPanes returns every pane of a session across all its windows.
+func ListSessionPanes(session string) ([]PaneInfo, error) {
+	out, err := Command(BuildListSessionPanesArgs(session)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("list-panes: %w", err)
+	}
+	return ParsePaneList(string(out)), nil
+}
+
+// GroupPanesBySession groups panes by session, returning session names in
+// first-seen order alongside the per-session pane lists.
+func GroupPanesBySession(panes []PaneInfo) ([]string, map[string][]PaneInfo) {
+	var names []string
+	bySession := make(map[string][]PaneInfo)
+	for _, p := range panes {
+		if _, ok := bySession[p.Session]; !ok {
+			names = append(names, p.Session)
+		}
+		bySession[p.Session] = append(bySession[p.Session], p)
+	}
+	return names, bySession
+}
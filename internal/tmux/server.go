@@ -0,0 +1,80 @@
+package tmux
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Server identifies a tmux server by its socket. The zero value is the server
+// tmux picks on its own ($TMUX inside tmux, otherwise the "default" socket).
+type Server struct {
+	Name       string `json:"name"`
+	SocketName string `json:"socketName,omitempty"` // tmux -L
+	SocketPath string `json:"socketPath,omitempty"` // tmux -S; takes precedence over SocketName
+}
+
+// defaultServer is the server used by the package-level helpers. It is set
+// once at startup from config and the --socket flag.
+var defaultServer Server
+
+// SetDefaultServer sets the server used by the package-level helpers.
+func SetDefaultServer(s Server) {
+	defaultServer = s.Named()
+}
+
+// DefaultServer returns the server used by the package-level helpers.
+func DefaultServer() Server {
+	return defaultServer.Named()
+}
+
+// ParseSocket interprets a --socket value: anything containing a path
+// separator is a socket path (tmux -S), otherwise a socket name (tmux -L).
+func ParseSocket(socket string) Server {
+	if strings.ContainsRune(socket, filepath.Separator) {
+		return Server{SocketPath: socket}.Named()
+	}
+	return Server{SocketName: socket}.Named()
+}
+
+// Label returns a short human-readable name for the server.
+func (s Server) Label() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.SocketPath != "":
+		return filepath.Base(s.SocketPath)
+	case s.SocketName != "":
+		return s.SocketName
+	}
+	return "default"
+}
+
+// Named returns a copy of the server with Name filled in from Label.
+func (s Server) Named() Server {
+	s.Name = s.Label()
+	return s
+}
+
+// Args prefixes tmux args with the socket selection flags for this server.
+func (s Server) Args(args ...string) []string {
+	switch {
+	case s.SocketPath != "":
+		return append([]string{"-S", s.SocketPath}, args...)
+	case s.SocketName != "":
+		return append([]string{"-L", s.SocketName}, args...)
+	}
+	return args
+}
+
+// Command builds a tmux command that runs against this server.
+func (s Server) Command(args ...string) *exec.Cmd {
+	return exec.Command("tmux", s.Args(args...)...)
+}
+
+// Command builds a tmux command that runs against the default server.
+// Every tmux invocation goes through here or Server.Command so the
+// configured socket is always honoured.
+func Command(args ...string) *exec.Cmd {
+	return defaultServer.Command(args...)
+}
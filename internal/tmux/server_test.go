@@ -0,0 +1,55 @@
+package tmux
+
+import "testing"
+
+func TestServerArgs(t *testing.T) {
+	tests := []struct {
+		srv  Server
+		want []string
+	}{
+		{Server{}, []string{"list-sessions"}},
+		{Server{SocketName: "agents"}, []string{"-L", "agents", "list-sessions"}},
+		{Server{SocketPath: "/tmp/tmux.sock"}, []string{"-S", "/tmp/tmux.sock", "list-sessions"}},
+		{Server{SocketName: "agents", SocketPath: "/tmp/tmux.sock"}, []string{"-S", "/tmp/tmux.sock", "list-sessions"}},
+	}
+	for _, tt := range tests {
+		got := tt.srv.Args("list-sessions")
+		if len(got) != len(tt.want) {
+			t.Errorf("%+v.Args() = %v, want %v", tt.srv, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%+v.Args() = %v, want %v", tt.srv, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseSocket(t *testing.T) {
+	if s := ParseSocket("agents"); s.SocketName != "agents" || s.SocketPath != "" || s.Name != "agents" {
+		t.Errorf("ParseSocket(agents) = %+v", s)
+	}
+	if s := ParseSocket("/tmp/tsp/agents.sock"); s.SocketPath != "/tmp/tsp/agents.sock" || s.SocketName != "" || s.Name != "agents.sock" {
+		t.Errorf("ParseSocket(path) = %+v", s)
+	}
+}
+
+func TestServerLabel(t *testing.T) {
+	if got := (Server{}).Label(); got != "default" {
+		t.Errorf("Label() = %q, want default", got)
+	}
+	if got := (Server{Name: "work", SocketName: "agents"}).Label(); got != "work" {
+		t.Errorf("Label() = %q, want explicit name", got)
+	}
+}
+
+func TestSetDefaultServer(t *testing.T) {
+	defer SetDefaultServer(Server{})
+	SetDefaultServer(Server{SocketName: "agents"})
+	cmd := Command("list-sessions")
+	if len(cmd.Args) != 4 || cmd.Args[1] != "-L" || cmd.Args[2] != "agents" {
+		t.Errorf("Command args = %v, want tmux -L agents list-sessions", cmd.Args)
+	}
+}
@@ -3,8 +3,8 @@ package tmux
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -21,22 +21,43 @@ func IsInsideTmux() bool {
 
 // SessionExists checks if a tmux session with the given name exists.
 func SessionExists(name string) bool {
-	cmd := exec.Command("tmux", "has-session", "-t", name)
+	cmd := Command("has-session", "-t", name)
 	return cmd.Run() == nil
 }
 
+// HasSession reports whether this server has a session with exactly the
+// given name. It fails when the server can't be reached, so a crashed or
+// restarting server isn't mistaken for one without the session.
+func (s Server) HasSession(name string) (bool, error) {
+	out, err := s.Command("list-sessions", "-F", "#{session_name}").Output()
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // KillSession kills a tmux session by name.
 func KillSession(name string) error {
-	return exec.Command("tmux", "kill-session", "-t", name).Run()
+	return defaultServer.KillSession(name)
+}
+
+// KillSession kills a tmux session by name on this server.
+func (s Server) KillSession(name string) error {
+	return s.Command("kill-session", "-t", name).Run()
 }
 
 // AttachOrSwitch attaches to or switches to a tmux session.
 // Uses switch-client when inside tmux, attach-session when outside.
 func AttachOrSwitch(name string) error {
 	if IsInsideTmux() {
-		return exec.Command("tmux", "switch-client", "-t", name).Run()
+		return Command("switch-client", "-t", name).Run()
 	}
-	cmd := exec.Command("tmux", "attach-session", "-t", name)
+	cmd := Command("attach-session", "-t", name)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -67,7 +88,7 @@ func BuildPopupArgs(command string, width, height int) []string {
 // If detach is true, the popup is launched in the background and control returns immediately.
 func RunPopup(command string, width, height int, detach bool) error {
 	args := BuildPopupArgs(command, width, height)
-	cmd := exec.Command("tmux", args...)
+	cmd := Command(args...)
 	if detach {
 		return cmd.Start()
 	}
@@ -77,13 +98,18 @@ func RunPopup(command string, width, height int, detach bool) error {
 	return cmd.Run()
 }
 
+// SendKeys sends literal text to a pane on the default server and presses Enter.
+func SendKeys(target, text string) error {
+	return defaultServer.SendKeys(target, text)
+}
+
 // SendKeys sends literal text to a tmux pane target and presses Enter.
 // Sends text in chunks via send-keys -l to avoid tmux argument-length
 // limits with long strings (e.g. URLs). A short delay separates the
 // literal text from the Enter key to avoid a race condition — each
 // exec.Command spawns a separate tmux client and the server does not
 // guarantee ordering across clients.
-func SendKeys(target, text string) error {
+func (s Server) SendKeys(target, text string) error {
 	// Collapse newlines to spaces so the input stays single-line and
 	// Enter submits rather than inserting a blank line.
 	text = strings.ReplaceAll(text, "\r\n", " ")
@@ -97,7 +123,7 @@ func SendKeys(target, text string) error {
 			chunk = text[:maxChunk]
 		}
 		text = text[len(chunk):]
-		if err := exec.Command("tmux", "send-keys", "-t", target, "-l", chunk).Run(); err != nil {
+		if err := s.Command("send-keys", "-t", target, "-l", chunk).Run(); err != nil {
 			return fmt.Errorf("send-keys: %w", err)
 		}
 	}
@@ -105,27 +131,104 @@ func SendKeys(target, text string) error {
 	// text before we send Enter from a new client connection.
 	time.Sleep(50 * time.Millisecond)
 	// Press Enter.
-	return exec.Command("tmux", "send-keys", "-t", target, "Enter").Run()
+	return s.Command("send-keys", "-t", target, "Enter").Run()
+}
+
+// pasteBufferSeq makes paste buffer names unique within the process so
+// concurrent sends never paste each other's text.
+var pasteBufferSeq atomic.Uint64
+
+// BuildPasteBufferArgs builds one tmux command sequence that loads stdin into
+// a named buffer and pastes it into target as a bracketed paste (-p),
+// deleting the buffer afterwards (-d).
+func BuildPasteBufferArgs(buffer, target string) []string {
+	return []string{
+		"load-buffer", "-b", buffer, "-", ";",
+		"paste-buffer", "-d", "-p", "-b", buffer, "-t", target,
+	}
+}
+
+// PasteText delivers text to a pane on the default server via a tmux buffer
+// and presses Enter.
+func PasteText(target, text string) error {
+	return defaultServer.PasteText(target, text)
+}
+
+// PasteText delivers text to a pane via load-buffer/paste-buffer and presses
+// Enter. Unlike SendKeys, newlines are preserved: with bracketed paste the
+// receiving app (e.g. Claude Code) sees the whole text as one paste instead
+// of a series of submitted lines, and it arrives in a single tmux call
+// however long it is.
+func (s Server) PasteText(target, text string) error {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimRight(text, "\r\n")
+	if text != "" {
+		buffer := fmt.Sprintf("tsp-%d-%d", os.Getpid(), pasteBufferSeq.Add(1))
+		cmd := s.Command(BuildPasteBufferArgs(buffer, target)...)
+		cmd.Stdin = strings.NewReader(text)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("paste-buffer: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+		// Give the app a moment to finish handling the paste so Enter
+		// submits it rather than landing inside the pasted block.
+		time.Sleep(50 * time.Millisecond)
+	}
+	return s.Command("send-keys", "-t", target, "Enter").Run()
 }
 
 // SendRawKey sends a single non-literal key (e.g. "Down", "Enter", "End") to a pane.
 func SendRawKey(target, key string) error {
-	return exec.Command("tmux", "send-keys", "-t", target, key).Run()
+	return defaultServer.SendRawKey(target, key)
+}
+
+// SendRawKey sends a single non-literal key to a pane on this server.
+func (s Server) SendRawKey(target, key string) error {
+	return s.Command("send-keys", "-t", target, key).Run()
 }
 
 // AnswerPromptFreeText selects "Other" in a Claude Code AskUserQuestion prompt
 // by sending its option number, then types the given text.
 // "Other" is always the last item: optionCount + 1.
 func AnswerPromptFreeText(target string, optionCount int, text string) error {
+	return defaultServer.AnswerPromptFreeText(target, optionCount, text)
+}
+
+// AnswerPromptFreeText answers a prompt in a pane on this server.
+func (s Server) AnswerPromptFreeText(target string, optionCount int, text string) error {
 	// Select "Other" by its number (last entry = optionCount + 1)
 	otherNum := fmt.Sprintf("%d", optionCount+1)
-	if err := SendKeys(target, otherNum); err != nil {
+	if err := s.SendKeys(target, otherNum); err != nil {
 		return fmt.Errorf("select other: %w", err)
 	}
 	// Wait for the free-text input prompt to appear
 	time.Sleep(500 * time.Millisecond)
 	// Type the answer and press Enter
-	return SendKeys(target, text)
+	return s.SendKeys(target, text)
+}
+
+// RecordingOption is the pane user option tsp sets to the log file it pipes
+// a pane to, so its own recordings can be told apart from pipes started by
+// anything else.
+const RecordingOption = "@tsp-recording"
+
+// BuildRecordPaneArgs builds one tmux command sequence that pipes a pane's
+// output into command, unless the pane is already piped (-o), and records
+// path in the pane's RecordingOption.
+func BuildRecordPaneArgs(target, command, path string) []string {
+	return []string{
+		"pipe-pane", "-o", "-t", target, command, ";",
+		"set-option", "-p", "-t", target, RecordingOption, path,
+	}
+}
+
+// RecordPane starts piping a pane's output into a shell command that records
+// it to path on this server. tmux expands formats in the command, so any
+// literal '#' must be doubled.
+func (s Server) RecordPane(target, command, path string) error {
+	if out, err := s.Command(BuildRecordPaneArgs(target, command, path)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("pipe-pane: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
 }
 
 // BuildListSessionsArgs builds tmux list-sessions args.
@@ -138,35 +241,31 @@ func BuildCapturePaneArgs(target string) []string {
 	return []string{"capture-pane", "-t", target, "-p", "-e"}
 }
 
-// CreateTwoPaneSession creates a tmux session with a left and right pane.
+// CreateTwoPaneSession creates a tmux session with a left and right pane and
+// returns their stable pane IDs (e.g. "%12"), so callers can target them
+// regardless of base-index or pane-base-index settings.
 // Uses -c flag for directory — no shell injection via send-keys.
-func CreateTwoPaneSession(name, dir, leftCmd, rightCmd string) error {
-	args := BuildNewSessionArgs(name, dir, leftCmd)
-	if err := exec.Command("tmux", args...).Run(); err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
-	}
-
-	splitArgs := []string{"split-window", "-h", "-t", name, "-c", dir}
-	if rightCmd != "" {
-		splitArgs = append(splitArgs, rightCmd)
+func CreateTwoPaneSession(name, dir, leftCmd, rightCmd string) (leftPane, rightPane string, err error) {
+	panes, _, err := CreateLayoutSession(name, dir, TwoPaneLayout(leftCmd, rightCmd))
+	if len(panes) > 0 {
+		leftPane = panes[0]
 	}
-	if err := exec.Command("tmux", splitArgs...).Run(); err != nil {
-		return fmt.Errorf("failed to split window: %w", err)
+	if len(panes) > 1 {
+		rightPane = panes[1]
 	}
-
-	exec.Command("tmux", "select-pane", "-t", name+":0.0").Run()
-	return nil
+	return leftPane, rightPane, err
 }
 
-// GetPaneCwd returns the current working directory of a session's first pane.
-func GetPaneCwd(session string) string {
-	return GetPaneCwdByIndex(session, 0)
+// withPrintPaneID adds -P -F '#{pane_id}' after the subcommand so
+// new-session/new-window/split-window print the ID of the pane they create.
+func withPrintPaneID(args []string) []string {
+	return append([]string{args[0], "-P", "-F", "#{pane_id}"}, args[1:]...)
 }
 
-// GetPaneCwdByIndex returns the current working directory of a specific pane.
-func GetPaneCwdByIndex(session string, pane int) string {
-	target := fmt.Sprintf("%s:0.%d", session, pane)
-	cmd := exec.Command("tmux", "display-message", "-t", target, "-p", "#{pane_current_path}")
+// GetPaneCwd returns the current working directory of a pane. The target may
+// be a pane ID or a session name, which resolves to the session's active pane.
+func GetPaneCwd(target string) string {
+	cmd := Command("display-message", "-t", target, "-p", "#{pane_current_path}")
 	out, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -133,3 +133,121 @@ func TestBuildCapturePaneArgs(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildListAllPanesArgs(t *testing.T) {
+	args := BuildListAllPanesArgs()
+	if len(args) != 4 || args[0] != "list-panes" || args[1] != "-a" || args[2] != "-F" {
+		t.Fatalf("unexpected args: %v", args)
+	}
+}
+
+func TestParsePaneList(t *testing.T) {
+	out := "my app\t0\t1\t1\t%12\t4242\tnvim\t/home/me/my app\t120\t40\t1700000000\t1\tb25d,120x40,0,0,12\tnvim\t1\t1699990000\t/rec/%12.log\teditor\n" +
+		"other\t2\t0\t0\t%3\t99\tzsh\t/tmp\t80\t24\t1700000100\t0\tc3e1,80x24,0,0,3\t\t0\t1699990000\t\tlogs\n" +
+		"garbage line\n"
+	panes := ParsePaneList(out)
+	if len(panes) != 2 {
+		t.Fatalf("expected 2 panes, got %d", len(panes))
+	}
+	p := panes[0]
+	if p.Session != "my app" || p.WindowIndex != 0 || !p.WindowActive || p.PaneIndex != 1 ||
+		p.PaneID != "%12" || p.PID != 4242 || p.Command != "nvim" || p.Cwd != "/home/me/my app" ||
+		p.Width != 120 || p.Height != 40 || p.Activity.Unix() != 1700000000 || p.SessionCreated.Unix() != 1699990000 {
+		t.Errorf("unexpected first pane: %+v", p)
+	}
+	if p.WindowName != "editor" {
+		t.Errorf("WindowName = %q, want editor", p.WindowName)
+	}
+	if !p.PaneActive || p.WindowLayout != "b25d,120x40,0,0,12" || p.StartCommand != "nvim" || !p.Piped || p.Recording != "/rec/%12.log" {
+		t.Errorf("unexpected snapshot fields: %+v", p)
+	}
+	if panes[1].WindowActive || panes[1].WindowIndex != 2 || panes[1].WindowName != "logs" ||
+		panes[1].PaneActive || panes[1].StartCommand != "" || panes[1].Piped || panes[1].Recording != "" {
+		t.Errorf("unexpected second pane: %+v", panes[1])
+	}
+}
+
+func TestUnescapeStartCommand(t *testing.T) {
+	tests := map[string]string{
+		"":                                "",
+		"sleep 100":                       "sleep 100",
+		`"sleep 100"`:                     "sleep 100",
+		`"claude 'fix the \"auth\" bug'"`: `claude 'fix the "auth" bug'`,
+		`"echo \$HOME; ls"`:               "echo $HOME; ls",
+	}
+	for in, want := range tests {
+		if got := unescapeStartCommand(in); got != want {
+			t.Errorf("unescapeStartCommand(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGroupPanesBySession(t *testing.T) {
+	panes := []PaneInfo{
+		{Session: "b", PaneID: "%1"},
+		{Session: "a", PaneID: "%2"},
+		{Session: "b", PaneID: "%3"},
+	}
+	names, bySession := GroupPanesBySession(panes)
+	if len(names) != 2 || names[0] != "b" || names[1] != "a" {
+		t.Errorf("names = %v, want [b a]", names)
+	}
+	if len(bySession["b"]) != 2 || len(bySession["a"]) != 1 {
+		t.Errorf("unexpected grouping: %v", bySession)
+	}
+}
+
+func TestBuildListSessionPanesArgs(t *testing.T) {
+	args := BuildListSessionPanesArgs("work")
+	if len(args) != 6 || args[1] != "-s" || args[2] != "-t" || args[3] != "work" || args[4] != "-F" {
+		t.Fatalf("unexpected args: %v", args)
+	}
+	if args[5] != BuildListAllPanesArgs()[3] {
+		t.Errorf("session format %q differs from list-all format", args[5])
+	}
+}
+
+func TestWithPrintPaneID(t *testing.T) {
+	args := withPrintPaneID(BuildNewSessionArgs("s", "/tmp", "nvim"))
+	expected := []string{"new-session", "-P", "-F", "#{pane_id}", "-d", "-s", "s", "-c", "/tmp", "nvim"}
+	if len(args) != len(expected) {
+		t.Fatalf("withPrintPaneID length = %d, want %d: %v", len(args), len(expected), args)
+	}
+	for i, a := range args {
+		if a != expected[i] {
+			t.Errorf("arg[%d] = %q, want %q", i, a, expected[i])
+		}
+	}
+}
+
+func TestBuildPasteBufferArgs(t *testing.T) {
+	args := BuildPasteBufferArgs("tsp-1-1", "%4")
+	expected := []string{
+		"load-buffer", "-b", "tsp-1-1", "-", ";",
+		"paste-buffer", "-d", "-p", "-b", "tsp-1-1", "-t", "%4",
+	}
+	if len(args) != len(expected) {
+		t.Fatalf("BuildPasteBufferArgs length = %d, want %d", len(args), len(expected))
+	}
+	for i, a := range args {
+		if a != expected[i] {
+			t.Errorf("arg[%d] = %q, want %q", i, a, expected[i])
+		}
+	}
+}
+
+func TestBuildRecordPaneArgs(t *testing.T) {
+	args := BuildRecordPaneArgs("%4", "exec tsp record-pane /rec/x.log", "/rec/x.log")
+	expected := []string{
+		"pipe-pane", "-o", "-t", "%4", "exec tsp record-pane /rec/x.log", ";",
+		"set-option", "-p", "-t", "%4", "@tsp-recording", "/rec/x.log",
+	}
+	if len(args) != len(expected) {
+		t.Fatalf("BuildRecordPaneArgs length = %d, want %d", len(args), len(expected))
+	}
+	for i, a := range args {
+		if a != expected[i] {
+			t.Errorf("arg[%d] = %q, want %q", i, a, expected[i])
+		}
+	}
+}